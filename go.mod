@@ -1,6 +1,6 @@
 module golearn
 
-go 1.23.1
+go 1.24.0
 
 require (
 	github.com/go-chi/chi v1.5.5
@@ -1,17 +1,400 @@
 package api
 
 import (
-	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
+// DefaultWallet is the wallet every account has, holding its base points.
+const DefaultWallet = "points"
+
+// PointBalanceParams select the wallet to report; an empty Wallet means
+// every wallet of the account.
 type PointBalanceParams struct {
 	Username string
+	Wallet   string
+}
+
+// WalletBalance is one wallet of an account. Balance and amounts count minor
+// units of Currency: at Precision 2, a Balance of 1050 is 10.50. Balance is
+// the ledger balance; Available leaves out what active holds reserve.
+type WalletBalance struct {
+	Name      string
+	Currency  string
+	Precision int
+	Balance   int64
+	Available int64
+}
+
+// Amount formats the balance in major units, such as "10.50".
+func (w WalletBalance) Amount() string {
+	return FormatAmount(w.Balance, w.Precision)
 }
 
+// PointBalanceResponse lists the wallets of an account, the default wallet
+// first, or only the requested or updated one.
 type PointBalanceResponse struct {
+	Code     int
+	UserID   string
+	Username string
+	Wallets  []WalletBalance
+}
+
+// PointUpdateParams credit or debit Amount minor units to Wallet, the
+// default wallet if empty.
+type PointUpdateParams struct {
+	Username string
+	Wallet   string
+	Amount   int64
+}
+
+// BatchBalanceParams look up the given wallet of each user, or all of their
+// wallets if Wallet is empty.
+type BatchBalanceParams struct {
+	Usernames []string
+	Wallet    string `json:",omitempty"`
+}
+
+// BatchBalanceResult is the outcome for one user of a batch lookup.
+// Username is the ID or username as requested. Code is the HTTP status the
+// equivalent single lookup would have returned.
+type BatchBalanceResult struct {
+	Username string
+	UserID   string `json:",omitempty"`
+	Code     int
+	Wallets  []WalletBalance `json:",omitempty"`
+	Error    string          `json:",omitempty"`
+}
+
+type BatchBalanceResponse struct {
 	Code    int
-	Balance int64
+	Results []BatchBalanceResult
+}
+
+// AccountRename is one change of username. At is an RFC 3339 timestamp.
+type AccountRename struct {
+	From string
+	To   string
+	At   string
+}
+
+// AccountResponse describes an account. UserID never changes; Renames lists
+// its earlier usernames, oldest first.
+type AccountResponse struct {
+	Code     int
+	UserID   string
+	Username string
+	Name     string
+	Roles    []string
+	Wallets  []WalletBalance
+	Renames  []AccountRename
+}
+
+type RenameAccountParams struct {
+	NewUsername string
+}
+
+// OpenWalletParams name a new, empty wallet and its currency.
+type OpenWalletParams struct {
+	Name      string
+	Currency  string
+	Precision int
+}
+
+// Statuses of a hold.
+const (
+	HoldActive   = "active"
+	HoldCaptured = "captured"
+	HoldReleased = "released"
+)
+
+// HoldParams reserve Amount minor units of Wallet, the default wallet if
+// empty, for TTL, a duration such as "30m". The server picks the TTL when it
+// is empty.
+type HoldParams struct {
+	Username string
+	Wallet   string
+	Amount   int64
+	TTL      string
+}
+
+// CaptureHoldParams take Amount minor units from a hold, or everything it
+// still reserves if Amount is zero.
+type CaptureHoldParams struct {
+	Username string
+	Amount   int64
+}
+
+// HoldInfo is one hold. Amount is what it still reserves, or what was
+// released, and Captured what has been taken from the wallet so far.
+// CreatedAt and ExpiresAt are RFC 3339 timestamps.
+type HoldInfo struct {
+	ID        string
+	Wallet    string
+	Amount    int64
+	Captured  int64
+	Status    string
+	CreatedAt string
+	ExpiresAt string
+}
+
+// HoldResponse is a hold after a change, with the wallet it is on.
+type HoldResponse struct {
+	Code     int
+	UserID   string
+	Username string
+	Hold     HoldInfo
+	Wallet   WalletBalance
+}
+
+// HoldListResponse lists the active holds of an account, oldest first.
+type HoldListResponse struct {
+	Code     int
+	UserID   string
+	Username string
+	Holds    []HoldInfo
+}
+
+type AuditLogParams struct {
+	User   string
+	Action string
+	Since  string
+	Until  string
+	Limit  int
+}
+
+// AuditDetails are the free-form details of an audit entry.
+type AuditDetails map[string]string
+
+type AuditEntry struct {
+	Seq       uint64
+	Time      string
+	Tenant    string `json:",omitempty"`
+	Action    string
+	Actor     string
+	Subject   string
+	Outcome   string
+	IP        string
+	RequestID string
+	Details   AuditDetails `json:",omitempty"`
+	PrevHash  string
+	Hash      string
+}
+
+// AuditLogResponse lists matching entries newest first. ChainValid reports
+// whether the whole log still verifies; ChainError says where it breaks.
+type AuditLogResponse struct {
+	Code       int
+	ChainValid bool
+	ChainError string `json:",omitempty"`
+	Entries    []AuditEntry
+}
+
+// ImportParams are the query parameters of an account import. Format is csv
+// or ndjson; DryRun validates the rows without writing them.
+type ImportParams struct {
+	Format string
+	DryRun bool `schema:"dry_run"`
+}
+
+type ImportRowError struct {
+	Line     int
+	Username string `json:",omitempty"`
+	Error    string
+}
+
+// ImportResponse summarises an import. Errors holds the first rejected rows
+// and ErrorsTruncated is set when there were more than it can hold.
+type ImportResponse struct {
+	Code            int
+	DryRun          bool
+	Rows            int
+	Imported        int
+	Failed          int
+	Errors          []ImportRowError
+	ErrorsTruncated bool `json:",omitempty"`
+}
+
+type ExportParams struct {
+	Format string
+}
+
+// SnapshotInfo describes one archive. Error is set when its header could
+// not be read.
+type SnapshotInfo struct {
+	Name      string
+	CreatedAt string `json:",omitempty"`
+	Label     string `json:",omitempty"`
+	Version   int    `json:",omitempty"`
+	Tenants   int
+	Accounts  int
+	SizeBytes int64
+	Error     string `json:",omitempty"`
+}
+
+type SnapshotListResponse struct {
+	Code      int
+	Snapshots []SnapshotInfo
+}
+
+type CreateSnapshotParams struct {
+	Label string
+}
+
+type SnapshotResponse struct {
+	Code     int
+	Snapshot SnapshotInfo
+}
+
+// RestoreResponse names the restored archive and the snapshot of the data
+// it replaced.
+type RestoreResponse struct {
+	Code     int
+	Restored SnapshotInfo
+	Backup   SnapshotInfo
+}
+
+// JobRun is one run of a background job. Trigger is "schedule" or
+// "manual" and Outcome "success", "failure" or "skipped", for a scheduled
+// run that came due while the previous one was still going.
+type JobRun struct {
+	Trigger   string
+	StartedAt string
+	Duration  string
+	Outcome   string
+	Error     string `json:",omitempty"`
+}
+
+// JobInfo describes a background job. NextRun is empty while it is paused.
+type JobInfo struct {
+	Name     string
+	Schedule string
+	Paused   bool
+	Running  bool
+	NextRun  string  `json:",omitempty"`
+	LastRun  *JobRun `json:",omitempty"`
+}
+
+type JobListResponse struct {
+	Code int
+	Jobs []JobInfo
+}
+
+// JobResponse describes a job and lists its recent runs, newest first.
+type JobResponse struct {
+	Code    int
+	Job     JobInfo
+	History []JobRun
+}
+
+// StatementParams are the query parameters of a statement download.
+// Format is html, text or json.
+type StatementParams struct {
+	Format string
+}
+
+// StatementEntry is one change to a wallet within a statement period.
+// Amount is signed and Balance is the balance after it.
+type StatementEntry struct {
+	At        string
+	Kind      string
+	Amount    int64
+	Balance   int64
+	Reference string `json:",omitempty"`
+}
+
+// StatementWallet summarises one wallet over a statement period, in minor
+// units. Redemptions and Expiries count what left the wallet and
+// Adjustments is signed, so Opening + Earnings - Redemptions - Expiries +
+// Adjustments is Closing.
+type StatementWallet struct {
+	Name        string
+	Currency    string
+	Precision   int
+	Opening     int64
+	Earnings    int64
+	Redemptions int64
+	Expiries    int64
+	Adjustments int64
+	Closing     int64
+	Entries     []StatementEntry
+}
+
+// Statement covers one account over a calendar month in UTC, Period
+// "2006-01", from From up to but not including To. The statement of the
+// current month stops at GeneratedAt.
+type Statement struct {
+	UserID      string
+	Username    string
+	Name        string
+	Period      string
+	From        string
+	To          string
+	GeneratedAt string
+	Wallets     []StatementWallet
+}
+
+type StatementResponse struct {
+	Code      int
+	Statement Statement
+}
+
+// StatementListResponse holds the statements of every account of the
+// tenant for one period, in username order.
+type StatementListResponse struct {
+	Code       int
+	Period     string
+	Statements []Statement
+}
+
+// FormatAmount formats amount minor units at precision as a decimal.
+func FormatAmount(amount int64, precision int) string {
+	var digits = strconv.FormatInt(amount, 10)
+	if precision <= 0 {
+		return digits
+	}
+	var sign string
+	if amount < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	if len(digits) <= precision {
+		digits = strings.Repeat("0", precision-len(digits)+1) + digits
+	}
+	var point = len(digits) - precision
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+// FormatSignedAmount is FormatAmount with a plus sign on positive amounts,
+// for changes to a balance.
+func FormatSignedAmount(amount int64, precision int) string {
+	if amount > 0 {
+		return "+" + FormatAmount(amount, precision)
+	}
+	return FormatAmount(amount, precision)
+}
+
+// ParseAmount reads a decimal written in major units, such as "-10.5", as
+// minor units at precision. It rejects more decimal places than precision.
+func ParseAmount(s string, precision int) (int64, error) {
+	var text = strings.TrimSpace(s)
+	var sign string
+	if rest, ok := strings.CutPrefix(text, "-"); ok {
+		sign, text = "-", rest
+	} else {
+		text = strings.TrimPrefix(text, "+")
+	}
+	whole, fraction, _ := strings.Cut(text, ".")
+	if whole == "" || len(fraction) > max(precision, 0) || strings.ContainsAny(whole+fraction, "+-") {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	fraction += strings.Repeat("0", max(precision, 0)-len(fraction))
+	amount, err := strconv.ParseInt(sign+whole+fraction, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	return amount, nil
 }
 
 type Error struct {
@@ -19,24 +402,59 @@ type Error struct {
 	Message string
 }
 
-func writeError(w http.ResponseWriter, code int, message string) {
+func (e Error) Error() string {
+	return http.StatusText(e.Code) + ": " + e.Message
+}
+
+func writeError(w http.ResponseWriter, format string, code int, message string) {
 	resp := Error{
 		Code:    code,
 		Message: message,
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(code)
-
-	json.NewEncoder(w).Encode(resp)
+	Write(w, format, code, resp)
+}
 
+// errorFormat is the format negotiated for r, or JSON when none is
+// acceptable: an error is better answered in JSON than not at all.
+func errorFormat(r *http.Request) string {
+	format, err := Negotiate(r)
+	if err != nil {
+		return FormatJSON
+	}
+	return format
 }
 
 var (
-	RequestErrorHandler = func(w http.ResponseWriter, err error) {
-		writeError(w, http.StatusNotFound, "Invalid Request")
+	RequestErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		writeError(w, errorFormat(r), http.StatusNotFound, "Invalid Request")
+	}
+	BadRequestErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		writeError(w, errorFormat(r), http.StatusBadRequest, err.Error())
 	}
-	InternalErrorHandler = func(w http.ResponseWriter) {
-		writeError(w, http.StatusInternalServerError, "Internal Server Error")
+	ForbiddenErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		writeError(w, errorFormat(r), http.StatusForbidden, err.Error())
+	}
+	NotAcceptableErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		writeError(w, FormatJSON, http.StatusNotAcceptable, err.Error())
+	}
+	ConflictErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		writeError(w, errorFormat(r), http.StatusConflict, err.Error())
+	}
+	InternalErrorHandler = func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, errorFormat(r), http.StatusInternalServerError, "Internal Server Error")
 	}
 )
+
+type HealthCheck struct {
+	Name      string
+	Status    string
+	LatencyMs float64
+	Error     string `json:",omitempty"`
+}
+
+type HealthResponse struct {
+	Code   int
+	Status string
+	Checks []HealthCheck
+}
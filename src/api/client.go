@@ -0,0 +1,430 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TenantHeader is the default header naming the tenant of a request.
+const TenantHeader = "X-Tenant-ID"
+
+// Client talks to a running golearn API server on behalf of a single account.
+// Username may also be the account's user ID. Tenant, when set, is sent in
+// the TenantHeader; servers that derive the tenant from the host do not need
+// it.
+type Client struct {
+	BaseURL    string
+	Tenant     string
+	Username   string
+	Token      string
+	HTTPClient *http.Client
+}
+
+func NewClient(baseURL string, username string, token string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		Username:   username,
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// GetPointBalance fetches the current balance of a wallet of the client's
+// account, or of every wallet if wallet is empty.
+func (c *Client) GetPointBalance(ctx context.Context, wallet string) (*PointBalanceResponse, error) {
+	var query url.Values
+	if wallet != "" {
+		query = url.Values{"wallet": {wallet}}
+	}
+
+	var response = PointBalanceResponse{}
+	var err = c.do(ctx, http.MethodGet, "/api/account/balance", query, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Credit adds amount minor units to a wallet of the client's account and
+// returns its new balance. Only admins may credit.
+func (c *Client) Credit(ctx context.Context, wallet string, amount int64) (*PointBalanceResponse, error) {
+	return c.update(ctx, "/api/account/credit", wallet, amount)
+}
+
+// Debit removes amount minor units from a wallet of the client's account and
+// returns its new balance.
+func (c *Client) Debit(ctx context.Context, wallet string, amount int64) (*PointBalanceResponse, error) {
+	return c.update(ctx, "/api/account/debit", wallet, amount)
+}
+
+func (c *Client) update(ctx context.Context, path string, wallet string, amount int64) (*PointBalanceResponse, error) {
+	var form = url.Values{}
+	form.Set("Wallet", wallet)
+	form.Set("Amount", strconv.FormatInt(amount, 10))
+
+	var response = PointBalanceResponse{}
+	var err = c.do(ctx, http.MethodPost, path, form, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// do sends an authenticated request with an optional form and decodes the
+// JSON response into out. The form is the body, or the query of a GET
+// request. Non-2xx responses are returned as an Error.
+// PlaceHold reserves amount minor units of a wallet of the client's account
+// for ttl, or for the server's default TTL if ttl is zero.
+func (c *Client) PlaceHold(ctx context.Context, wallet string, amount int64, ttl time.Duration) (*HoldResponse, error) {
+	var form = url.Values{}
+	form.Set("Wallet", wallet)
+	form.Set("Amount", strconv.FormatInt(amount, 10))
+	if ttl != 0 {
+		form.Set("TTL", ttl.String())
+	}
+
+	var response = HoldResponse{}
+	var err = c.do(ctx, http.MethodPost, "/api/account/holds", form, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// ListHolds lists the active holds of the client's account.
+func (c *Client) ListHolds(ctx context.Context) (*HoldListResponse, error) {
+	var response = HoldListResponse{}
+	var err = c.do(ctx, http.MethodGet, "/api/account/holds", nil, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// CaptureHold debits amount minor units of a hold, or everything it still
+// reserves if amount is zero.
+func (c *Client) CaptureHold(ctx context.Context, hold string, amount int64) (*HoldResponse, error) {
+	var form = url.Values{}
+	if amount != 0 {
+		form.Set("Amount", strconv.FormatInt(amount, 10))
+	}
+
+	var response = HoldResponse{}
+	var err = c.do(ctx, http.MethodPost, "/api/account/holds/"+url.PathEscape(hold)+"/capture", form, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// ReleaseHold ends a hold without debiting anything.
+func (c *Client) ReleaseHold(ctx context.Context, hold string) (*HoldResponse, error) {
+	var response = HoldResponse{}
+	var err = c.do(ctx, http.MethodPost, "/api/account/holds/"+url.PathEscape(hold)+"/release", nil, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// GetStatement returns the client's statement for period, a month written
+// as YYYY-MM.
+func (c *Client) GetStatement(ctx context.Context, period string) (*StatementResponse, error) {
+	var query = url.Values{}
+	query.Set("format", "json")
+
+	var response = StatementResponse{}
+	var err = c.do(ctx, http.MethodGet, "/api/account/statements/"+url.PathEscape(period), query, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// DownloadStatement writes the client's statement for period to w, rendered
+// in format: html or text.
+func (c *Client) DownloadStatement(ctx context.Context, period string, format string, w io.Writer) error {
+	var query = url.Values{}
+	query.Set("format", format)
+
+	resp, err := c.open(ctx, http.MethodGet, "/api/account/statements/"+url.PathEscape(period), query, "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+func (c *Client) do(ctx context.Context, method string, path string, form url.Values, out any) error {
+	if form == nil {
+		return c.send(ctx, method, path, nil, "", nil, out)
+	}
+	if method == http.MethodGet {
+		return c.send(ctx, method, path, form, "", nil, out)
+	}
+	return c.send(ctx, method, path, nil, "application/x-www-form-urlencoded", strings.NewReader(form.Encode()), out)
+}
+
+// doJSON is do with a JSON request body.
+func (c *Client) doJSON(ctx context.Context, method string, path string, body []byte, out any) error {
+	return c.send(ctx, method, path, nil, "application/json", bytes.NewReader(body), out)
+}
+
+func (c *Client) send(ctx context.Context, method string, path string, query url.Values, contentType string, body io.Reader, out any) error {
+	resp, err := c.open(ctx, method, path, query, contentType, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err = json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	return nil
+}
+
+// open sends an authenticated request and returns the response of a 2xx
+// status, leaving the caller to read and close the body. Other statuses are
+// returned as an Error.
+func (c *Client) open(ctx context.Context, method string, path string, query url.Values, contentType string, body io.Reader) (*http.Response, error) {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("username", c.Username)
+
+	var target = c.BaseURL + path + "?" + query.Encode()
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", c.Token)
+	if c.Tenant != "" {
+		req.Header.Set(TenantHeader, c.Tenant)
+	}
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		var apiErr = Error{}
+		if json.NewDecoder(resp.Body).Decode(&apiErr) != nil || apiErr.Code == 0 {
+			return nil, Error{Code: resp.StatusCode, Message: resp.Status}
+		}
+		return nil, apiErr
+	}
+	return resp, nil
+}
+
+// GetAccount describes the client's own account.
+func (c *Client) GetAccount(ctx context.Context) (*AccountResponse, error) {
+	var response = AccountResponse{}
+	var err = c.do(ctx, http.MethodGet, "/api/account", nil, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// GetUserAccount describes the account with the given ID or username. The
+// client's account must have the admin role.
+func (c *Client) GetUserAccount(ctx context.Context, user string) (*AccountResponse, error) {
+	var response = AccountResponse{}
+	var err = c.do(ctx, http.MethodGet, "/api/accounts/"+url.PathEscape(user), nil, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// RenameAccount changes the username of the account with the given ID or
+// username. The client's account must have the admin role.
+func (c *Client) RenameAccount(ctx context.Context, user string, newUsername string) (*AccountResponse, error) {
+	var form = url.Values{}
+	form.Set("NewUsername", newUsername)
+
+	var response = AccountResponse{}
+	var err = c.do(ctx, http.MethodPost, "/api/accounts/"+url.PathEscape(user)+"/rename", form, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// OpenWallet adds an empty wallet to the account with the given ID or
+// username. The client's account must have the admin role.
+func (c *Client) OpenWallet(ctx context.Context, user string, name string, currency string, precision int) (*AccountResponse, error) {
+	var form = url.Values{}
+	form.Set("Name", name)
+	form.Set("Currency", currency)
+	form.Set("Precision", strconv.Itoa(precision))
+
+	var response = AccountResponse{}
+	var err = c.do(ctx, http.MethodPost, "/api/accounts/"+url.PathEscape(user)+"/wallets", form, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// GetPointBalances fetches the balances of several accounts in one request,
+// of the given wallet or of every wallet if it is empty. The client's
+// account must have the admin role.
+func (c *Client) GetPointBalances(ctx context.Context, usernames []string, wallet string) (*BatchBalanceResponse, error) {
+	body, err := json.Marshal(BatchBalanceParams{Usernames: usernames, Wallet: wallet})
+	if err != nil {
+		return nil, err
+	}
+
+	var response = BatchBalanceResponse{}
+	err = c.doJSON(ctx, http.MethodPost, "/api/accounts/balances", body, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// ImportAccounts streams body, CSV or NDJSON accounts, to the server. With
+// dryRun the rows are only validated. The client's account must have the
+// admin role.
+func (c *Client) ImportAccounts(ctx context.Context, format string, dryRun bool, body io.Reader) (*ImportResponse, error) {
+	var query = url.Values{}
+	query.Set("format", format)
+	query.Set("dry_run", strconv.FormatBool(dryRun))
+
+	resp, err := c.open(ctx, http.MethodPost, "/api/accounts/import", query, "", body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var response = ImportResponse{}
+	if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+	return &response, nil
+}
+
+// ExportAccounts copies every account of the client's tenant to w in format.
+// The client's account must have the admin role.
+func (c *Client) ExportAccounts(ctx context.Context, format string, w io.Writer) error {
+	var query = url.Values{}
+	query.Set("format", format)
+
+	resp, err := c.open(ctx, http.MethodGet, "/api/accounts/export", query, "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// ListStatements returns the statement of every account of the tenant for
+// period. The client's account must have the admin role.
+func (c *Client) ListStatements(ctx context.Context, period string) (*StatementListResponse, error) {
+	var response = StatementListResponse{}
+	var err = c.do(ctx, http.MethodGet, "/api/accounts/statements/"+url.PathEscape(period), nil, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// ListSnapshots lists the server's store snapshots, newest first. The
+// client's account must be an admin of the operator tenant.
+func (c *Client) ListSnapshots(ctx context.Context) (*SnapshotListResponse, error) {
+	var response = SnapshotListResponse{}
+	var err = c.do(ctx, http.MethodGet, "/api/snapshots", nil, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// CreateSnapshot snapshots every tenant's accounts on the server.
+func (c *Client) CreateSnapshot(ctx context.Context, label string) (*SnapshotResponse, error) {
+	var query = url.Values{}
+	if label != "" {
+		query.Set("label", label)
+	}
+
+	resp, err := c.open(ctx, http.MethodPost, "/api/snapshots", query, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var response = SnapshotResponse{}
+	if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+	return &response, nil
+}
+
+// RestoreSnapshot replaces the server's data with the named snapshot.
+func (c *Client) RestoreSnapshot(ctx context.Context, name string) (*RestoreResponse, error) {
+	var response = RestoreResponse{}
+	var err = c.do(ctx, http.MethodPost, "/api/snapshots/"+url.PathEscape(name)+"/restore", nil, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// ListJobs describes the server's background jobs. The client's account
+// must be an admin of the operator tenant.
+func (c *Client) ListJobs(ctx context.Context) (*JobListResponse, error) {
+	var response = JobListResponse{}
+	var err = c.do(ctx, http.MethodGet, "/api/jobs", nil, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// GetJob describes a background job with its recent runs.
+func (c *Client) GetJob(ctx context.Context, name string) (*JobResponse, error) {
+	return c.job(ctx, http.MethodGet, name, "")
+}
+
+// RunJob starts a background job now. It returns once the run has started.
+func (c *Client) RunJob(ctx context.Context, name string) (*JobResponse, error) {
+	return c.job(ctx, http.MethodPost, name, "/run")
+}
+
+// PauseJob stops the scheduled runs of a background job.
+func (c *Client) PauseJob(ctx context.Context, name string) (*JobResponse, error) {
+	return c.job(ctx, http.MethodPost, name, "/pause")
+}
+
+// ResumeJob schedules a paused background job again.
+func (c *Client) ResumeJob(ctx context.Context, name string) (*JobResponse, error) {
+	return c.job(ctx, http.MethodPost, name, "/resume")
+}
+
+func (c *Client) job(ctx context.Context, method string, name string, action string) (*JobResponse, error) {
+	var response = JobResponse{}
+	var err = c.do(ctx, method, "/api/jobs/"+url.PathEscape(name)+action, nil, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
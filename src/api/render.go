@@ -0,0 +1,210 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// The formats responses can be rendered in.
+const (
+	FormatJSON = "json"
+	FormatXML  = "xml"
+	FormatCSV  = "csv"
+)
+
+// Formats lists the response formats in order of preference.
+var Formats = []string{FormatJSON, FormatXML, FormatCSV}
+
+var ErrNotAcceptable = errors.New("no acceptable response format; supported formats are json, xml and csv")
+
+var mediaTypes = map[string][]string{
+	FormatJSON: {"application/json"},
+	FormatXML:  {"application/xml", "text/xml"},
+	FormatCSV:  {"text/csv"},
+}
+
+// ParseFormat reads a format name such as "xml".
+func ParseFormat(name string) (string, error) {
+	var format = strings.ToLower(strings.TrimSpace(name))
+	if _, ok := mediaTypes[format]; !ok {
+		return "", fmt.Errorf("%w: format %q", ErrNotAcceptable, name)
+	}
+	return format, nil
+}
+
+// ContentType is the media type responses in format are sent with.
+func ContentType(format string) string {
+	switch format {
+	case FormatXML:
+		return "application/xml; charset=utf-8"
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	}
+	return "application/json"
+}
+
+type acceptOnlyKey struct{}
+
+// AcceptOnly makes the routes it wraps negotiate their response format from
+// the Accept header alone, for routes whose format parameter already means
+// something else, such as the format of an import.
+func AcceptOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), acceptOnlyKey{}, true)))
+	})
+}
+
+// Negotiate picks the format of the response to r: the format query
+// parameter when set, otherwise the best match for the Accept header. A
+// request with neither gets JSON.
+func Negotiate(r *http.Request) (string, error) {
+	if r.Context().Value(acceptOnlyKey{}) == nil {
+		if name := r.URL.Query().Get("format"); name != "" {
+			return ParseFormat(name)
+		}
+	}
+	return negotiateAccept(r.Header.Values("Accept"))
+}
+
+type mediaRange struct {
+	mediaType string
+	q         float64
+}
+
+// negotiateAccept picks the format with the highest quality in the Accept
+// header, preferring earlier Formats on a tie. Ranges that cannot be parsed
+// are ignored.
+func negotiateAccept(accept []string) (string, error) {
+	var ranges []mediaRange
+	for _, value := range accept {
+		for _, part := range strings.Split(value, ",") {
+			mediaType, params, err := mime.ParseMediaType(part)
+			if err != nil {
+				continue
+			}
+			var q = 1.0
+			if text, ok := params["q"]; ok {
+				if q, err = strconv.ParseFloat(text, 64); err != nil {
+					continue
+				}
+			}
+			ranges = append(ranges, mediaRange{mediaType: mediaType, q: q})
+		}
+	}
+	if len(ranges) == 0 {
+		return FormatJSON, nil
+	}
+
+	var best string
+	var bestQ float64
+	for _, format := range Formats {
+		for _, mediaType := range mediaTypes[format] {
+			if q := quality(ranges, mediaType); q > bestQ {
+				best, bestQ = format, q
+			}
+		}
+	}
+	if best == "" {
+		return "", fmt.Errorf("%w: Accept %q", ErrNotAcceptable, strings.Join(accept, ", "))
+	}
+	return best, nil
+}
+
+// quality is the q of the most specific range that matches mediaType, or 0
+// when none does.
+func quality(ranges []mediaRange, mediaType string) float64 {
+	var q float64
+	var specificity = -1
+	var kind, _, _ = strings.Cut(mediaType, "/")
+	for _, accepted := range ranges {
+		var s int
+		switch accepted.mediaType {
+		case mediaType:
+			s = 2
+		case kind + "/*":
+			s = 1
+		case "*/*":
+			s = 0
+		default:
+			continue
+		}
+		if s > specificity {
+			q, specificity = accepted.q, s
+		}
+	}
+	return q
+}
+
+// Render writes response with the status code in the format negotiated for
+// r, or answers 406 when none is acceptable. If the response cannot be
+// encoded it answers 500 and returns the error.
+func Render(w http.ResponseWriter, r *http.Request, code int, response any) error {
+	format, err := Negotiate(r)
+	if err != nil {
+		NotAcceptableErrorHandler(w, r, err)
+		return nil
+	}
+	return Write(w, format, code, response)
+}
+
+// Write writes response with the status code in format. If the response
+// cannot be encoded it answers 500 and returns the error.
+func Write(w http.ResponseWriter, format string, code int, response any) error {
+	var body bytes.Buffer
+	if err := Encode(&body, format, response); err != nil {
+		writeError(w, FormatJSON, http.StatusInternalServerError, "Internal Server Error")
+		return err
+	}
+	w.Header().Set("Content-Type", ContentType(format))
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(code)
+	_, err := w.Write(body.Bytes())
+	return err
+}
+
+// Encode writes response to w in format.
+func Encode(w io.Writer, format string, response any) error {
+	switch format {
+	case FormatXML:
+		if _, err := io.WriteString(w, xml.Header); err != nil {
+			return err
+		}
+		if err := xml.NewEncoder(w).Encode(response); err != nil {
+			return err
+		}
+		_, err := io.WriteString(w, "\n")
+		return err
+	case FormatCSV:
+		return encodeCSV(w, response)
+	}
+	return json.NewEncoder(w).Encode(response)
+}
+
+// MarshalXML writes the details as Detail elements with a Key attribute,
+// in key order, since encoding/xml cannot encode maps.
+func (d AuditDetails) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, key := range slices.Sorted(maps.Keys(d)) {
+		var detail = xml.StartElement{
+			Name: xml.Name{Local: "Detail"},
+			Attr: []xml.Attr{{Name: xml.Name{Local: "Key"}, Value: key}},
+		}
+		if err := e.EncodeElement(d[key], detail); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
@@ -0,0 +1,224 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	var tests = []struct {
+		name       string
+		query      string
+		accept     []string
+		acceptOnly bool
+		want       string
+	}{
+		{name: "nothing", want: FormatJSON},
+		{name: "format parameter", query: "format=xml", want: FormatXML},
+		{name: "format parameter case", query: "format=CSV", want: FormatCSV},
+		{name: "format parameter wins", query: "format=csv", accept: []string{"application/xml"}, want: FormatCSV},
+		{name: "accept only", query: "format=csv", accept: []string{"application/xml"}, acceptOnly: true, want: FormatXML},
+		{name: "accept only without accept", query: "format=csv", acceptOnly: true, want: FormatJSON},
+		{name: "json", accept: []string{"application/json"}, want: FormatJSON},
+		{name: "text xml", accept: []string{"text/xml"}, want: FormatXML},
+		{name: "csv", accept: []string{"text/csv; charset=utf-8"}, want: FormatCSV},
+		{name: "any", accept: []string{"*/*"}, want: FormatJSON},
+		{name: "text wildcard", accept: []string{"text/*"}, want: FormatXML},
+		{name: "quality", accept: []string{"application/json;q=0.5, text/csv"}, want: FormatCSV},
+		{name: "several headers", accept: []string{"application/json;q=0.2", "application/xml;q=0.9"}, want: FormatXML},
+		{name: "tie keeps preference", accept: []string{"text/csv, application/xml"}, want: FormatXML},
+		{name: "specific range wins", accept: []string{"*/*;q=0.9, application/json;q=0.1"}, want: FormatXML},
+		{name: "browser", accept: []string{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"}, want: FormatXML},
+		{name: "unparsable ranges ignored", accept: []string{"/, text/csv;q=abc, text/csv;q=0.3"}, want: FormatCSV},
+		{name: "only unparsable", accept: []string{"///"}, want: FormatJSON},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var r = httptest.NewRequest(http.MethodGet, "/?"+test.query, nil)
+			for _, accept := range test.accept {
+				r.Header.Add("Accept", accept)
+			}
+			if test.acceptOnly {
+				AcceptOnly(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) { r = req })).ServeHTTP(nil, r)
+			}
+
+			format, err := Negotiate(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if format != test.want {
+				t.Fatalf("Negotiate = %s, want %s", format, test.want)
+			}
+		})
+	}
+}
+
+func TestNegotiateNotAcceptable(t *testing.T) {
+	var tests = []struct {
+		name   string
+		query  string
+		accept string
+	}{
+		{name: "unknown format", query: "format=yaml"},
+		{name: "unsupported type", accept: "text/html"},
+		{name: "refused", accept: "application/json;q=0, application/xml;q=0, text/csv;q=0"},
+		{name: "wildcard refused", accept: "*/*;q=0"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var r = httptest.NewRequest(http.MethodGet, "/?"+test.query, nil)
+			if test.accept != "" {
+				r.Header.Set("Accept", test.accept)
+			}
+			if _, err := Negotiate(r); !errors.Is(err, ErrNotAcceptable) {
+				t.Fatalf("Negotiate = %v, want ErrNotAcceptable", err)
+			}
+
+			var w = httptest.NewRecorder()
+			if err := Render(w, r, http.StatusOK, Error{Code: http.StatusOK}); err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != http.StatusNotAcceptable {
+				t.Fatalf("Render status = %d, want 406", w.Code)
+			}
+		})
+	}
+}
+
+type csvJob struct {
+	Name    string
+	LastRun *csvRun
+	Tags    []string
+	Labels  map[string]string
+}
+
+type csvRun struct {
+	Outcome  string
+	Duration float64
+}
+
+type csvJobs struct {
+	Code int
+	Jobs []csvJob
+}
+
+type csvMulti struct {
+	Code    int
+	Wallets []WalletBalance
+	Holds   []HoldInfo
+}
+
+func TestEncodeCSV(t *testing.T) {
+	var tests = []struct {
+		name     string
+		response any
+		want     string
+	}{
+		{
+			name:     "flat",
+			response: Error{Code: 404, Message: "Invalid Request"},
+			want:     "Code,Message\n404,Invalid Request\n",
+		},
+		{
+			name: "list of structs",
+			response: &PointBalanceResponse{Code: 200, UserID: "usr_1", Username: "damien", Wallets: []WalletBalance{
+				{Name: "points", Currency: "PTS", Balance: 10, Available: 8},
+				{Name: "eur", Currency: "EUR", Precision: 2, Balance: 1050, Available: 1050},
+			}},
+			want: "Code,UserID,Username,Wallets.Name,Wallets.Currency,Wallets.Precision,Wallets.Balance,Wallets.Available\n" +
+				"200,usr_1,damien,points,PTS,0,10,8\n" +
+				"200,usr_1,damien,eur,EUR,2,1050,1050\n",
+		},
+		{
+			name:     "empty list",
+			response: PointBalanceResponse{Code: 200, UserID: "usr_1"},
+			want:     "Code,UserID,Username,Wallets.Name,Wallets.Currency,Wallets.Precision,Wallets.Balance,Wallets.Available\n200,usr_1,,,,,,\n",
+		},
+		{
+			name: "nested, nil, lists and maps",
+			response: csvJobs{Code: 200, Jobs: []csvJob{
+				{Name: "a", LastRun: &csvRun{Outcome: "success", Duration: 1.5}, Tags: []string{"x", "y"}, Labels: map[string]string{"b": "2", "a": "1 1"}},
+				{Name: "b"},
+			}},
+			want: "Code,Jobs.Name,Jobs.LastRun.Outcome,Jobs.LastRun.Duration,Jobs.Tags,Jobs.Labels\n" +
+				"200,a,success,1.5,x;y,a=1+1&b=2\n" +
+				"200,b,,,,\n",
+		},
+		{
+			name: "several lists",
+			response: csvMulti{
+				Code:    200,
+				Wallets: []WalletBalance{{Name: "points", Currency: "PTS", Balance: 10}},
+				Holds:   []HoldInfo{{ID: "hld_1", Wallet: "points", Amount: 2}},
+			},
+			want: "Code,Wallets.Name,Wallets.Currency,Wallets.Precision,Wallets.Balance,Wallets.Available," +
+				"Holds.ID,Holds.Wallet,Holds.Amount,Holds.Captured,Holds.Status,Holds.CreatedAt,Holds.ExpiresAt\n" +
+				"200,points,PTS,0,10,0,,,,,,,\n" +
+				"200,,,,,,hld_1,points,2,0,,,\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := Encode(&out, FormatCSV, test.response); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != test.want {
+				t.Fatalf("got\n%s\nwant\n%s", out.String(), test.want)
+			}
+		})
+	}
+
+	if err := Encode(&bytes.Buffer{}, FormatCSV, []string{"a"}); err == nil {
+		t.Error("encoded a list that is not in a struct")
+	}
+}
+
+func TestEncodeXML(t *testing.T) {
+	var out bytes.Buffer
+	var entry = AuditEntry{Seq: 1, Action: "balance.read", Details: AuditDetails{"wallet": "points", "batch": "true"}}
+	if err := Encode(&out, FormatXML, entry); err != nil {
+		t.Fatal(err)
+	}
+	var want = `<Details><Detail Key="batch">true</Detail><Detail Key="wallet">points</Detail></Details>`
+	if !strings.HasPrefix(out.String(), `<?xml`) || !strings.Contains(out.String(), want) {
+		t.Fatalf("got %s, want details as %s", out.String(), want)
+	}
+}
+
+func TestRender(t *testing.T) {
+	var tests = []struct {
+		format      string
+		contentType string
+		body        string
+	}{
+		{FormatJSON, "application/json", `{"Code":409,"Message":"no"}`},
+		{FormatXML, "application/xml; charset=utf-8", `<Error><Code>409</Code><Message>no</Message></Error>`},
+		{FormatCSV, "text/csv; charset=utf-8", "Code,Message\n409,no\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			var r = httptest.NewRequest(http.MethodGet, "/?format="+test.format, nil)
+			var w = httptest.NewRecorder()
+			if err := Render(w, r, http.StatusConflict, Error{Code: http.StatusConflict, Message: "no"}); err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != http.StatusConflict {
+				t.Errorf("status = %d, want 409", w.Code)
+			}
+			if got := w.Header().Get("Content-Type"); got != test.contentType {
+				t.Errorf("Content-Type = %s, want %s", got, test.contentType)
+			}
+			if got := w.Header().Get("Vary"); got != "Accept" {
+				t.Errorf("Vary = %s, want Accept", got)
+			}
+			if !strings.Contains(w.Body.String(), test.body) {
+				t.Errorf("body = %s, want it to contain %s", w.Body.String(), test.body)
+			}
+		})
+	}
+}
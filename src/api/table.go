@@ -0,0 +1,159 @@
+package api
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// encodeCSV writes response as a table with a header row. Nested structs
+// become columns named by their path, such as Job.LastRun.Outcome, and each
+// element of a list of structs becomes a row that repeats the fields around
+// it; a response with several lists has the rows of each in turn. The
+// columns depend only on the type, so every response of a type has the same
+// header. Maps are written as query strings and other lists joined with
+// semicolons.
+func encodeCSV(w io.Writer, response any) error {
+	var value = reflect.ValueOf(response)
+	for value.Kind() == reflect.Pointer {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return fmt.Errorf("csv: cannot encode %T", response)
+	}
+
+	var cw = csv.NewWriter(w)
+	if err := cw.Write(csvColumns(value.Type(), "")); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(csvRows(value.Type(), value)); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// csvNested reports whether t is spread over several columns and, for a
+// list of structs, over several rows, returning the struct type.
+func csvNested(t reflect.Type) (reflect.Type, bool, bool) {
+	var list = t.Kind() == reflect.Slice || t.Kind() == reflect.Array
+	if list {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t, t.Kind() == reflect.Struct, list
+}
+
+func csvColumns(t reflect.Type, prefix string) []string {
+	var columns []string
+	for _, field := range reflect.VisibleFields(t) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+		if elem, nested, _ := csvNested(field.Type); nested {
+			columns = append(columns, csvColumns(elem, prefix+field.Name+".")...)
+			continue
+		}
+		columns = append(columns, prefix+field.Name)
+	}
+	return columns
+}
+
+// csvRows returns the rows of the struct value of type t, which is invalid
+// when the struct is absent, such as behind a nil pointer.
+func csvRows(t reflect.Type, value reflect.Value) [][]string {
+	var base = make([]string, len(csvColumns(t, "")))
+	if !value.IsValid() {
+		return [][]string{base}
+	}
+
+	type block struct {
+		offset int
+		rows   [][]string
+	}
+	var blocks []block
+	var offset int
+	for _, field := range reflect.VisibleFields(t) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+		var fieldValue = value.FieldByIndex(field.Index)
+		elem, nested, list := csvNested(field.Type)
+		switch {
+		case nested && list:
+			var rows [][]string
+			for i := 0; i < fieldValue.Len(); i++ {
+				rows = append(rows, csvRows(elem, indirect(fieldValue.Index(i)))...)
+			}
+			if len(rows) > 0 {
+				blocks = append(blocks, block{offset: offset, rows: rows})
+			}
+			offset += len(csvColumns(elem, ""))
+		case nested:
+			var rows = csvRows(elem, indirect(fieldValue))
+			if len(rows) == 1 {
+				copy(base[offset:], rows[0])
+			} else {
+				blocks = append(blocks, block{offset: offset, rows: rows})
+			}
+			offset += len(csvColumns(elem, ""))
+		default:
+			base[offset] = csvCell(fieldValue)
+			offset++
+		}
+	}
+
+	if len(blocks) == 0 {
+		return [][]string{base}
+	}
+	var rows [][]string
+	for _, b := range blocks {
+		for _, cells := range b.rows {
+			var row = slices.Clone(base)
+			copy(row[b.offset:], cells)
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// indirect follows pointers, returning an invalid value for nil.
+func indirect(value reflect.Value) reflect.Value {
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return reflect.Value{}
+		}
+		value = value.Elem()
+	}
+	return value
+}
+
+func csvCell(value reflect.Value) string {
+	value = indirect(value)
+	if !value.IsValid() {
+		return ""
+	}
+	switch value.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(value.Float(), 'f', -1, value.Type().Bits())
+	case reflect.Map:
+		var query = url.Values{}
+		for _, key := range value.MapKeys() {
+			query.Set(fmt.Sprint(key.Interface()), csvCell(value.MapIndex(key)))
+		}
+		return query.Encode()
+	case reflect.Slice, reflect.Array:
+		var items = make([]string, value.Len())
+		for i := range items {
+			items[i] = csvCell(value.Index(i))
+		}
+		return strings.Join(items, ";")
+	}
+	return fmt.Sprint(value.Interface())
+}
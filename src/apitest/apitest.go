@@ -0,0 +1,390 @@
+// Package apitest runs the golearn API in process for end-to-end tests. A
+// Server serves the real router from an httptest.Server, backed by an
+// in-memory store and a Clock the test controls:
+//
+//	var srv = apitest.New(t, apitest.Options{})
+//	var user = srv.CreateUser(apitest.User{Username: "alice", Balance: 50})
+//	_, err := srv.Client(user).Debit(ctx, "points", 80)
+//	apitest.AssertError(t, err, http.StatusConflict)
+package apitest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"golearn/src/api"
+	"golearn/src/internal/audit"
+	"golearn/src/internal/config"
+	"golearn/src/internal/handlers"
+	"golearn/src/internal/health"
+	"golearn/src/internal/jobs"
+	"golearn/src/internal/scheduler"
+	"golearn/src/internal/snapshot"
+	"golearn/src/internal/tenant"
+	"golearn/src/internal/tools"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+// Epoch is the time a Clock starts at unless Options.Now says otherwise.
+var Epoch = time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Aliases of the internal types that Options and Server take, so that tests
+// in other modules, which cannot import golearn/src/internal, can name them.
+type (
+	// Config is the server configuration, as in the config file.
+	Config = config.Config
+	// Duration is a time.Duration field of Config or Latency.
+	Duration = config.Duration
+	// MockScenario, Faults and Latency describe store latency and failures
+	// as in a mock database scenario file.
+	MockScenario = tools.MockScenario
+	Faults       = tools.Faults
+	Latency      = tools.Latency
+	// AuditFilter selects the entries AuditEntries returns.
+	AuditFilter = audit.Filter
+	AuditEntry  = audit.Entry
+)
+
+// Options configures a Server. The zero value serves the built-in sample
+// accounts with the default configuration.
+type Options struct {
+	// Accounts seeds the store, with their defaults filled in as by
+	// CreateUser. Nil means the built-in sample accounts; use an empty slice
+	// to start with none.
+	Accounts []User
+	// Now is the starting time of the Clock.
+	Now time.Time
+	// Configure adjusts the default configuration before the server starts.
+	// The tenancy, api, audit, cache, snapshot, holds, scheduler, dashboard
+	// and database.coalesce settings apply; metrics, tracing and access
+	// logging are always off.
+	Configure func(cfg *Config)
+}
+
+// Server is the API under test. Its store starts without latency or
+// failures; SetScenario adds some.
+type Server struct {
+	*httptest.Server
+	Config Config
+	Clock  *Clock
+
+	database tools.DatabaseInterface
+	mock     tools.MockController
+	audit    *audit.Log
+	jobs     *scheduler.Scheduler
+	t        testing.TB
+}
+
+// New starts a Server and closes it when the test ends.
+func New(t testing.TB, opts Options) *Server {
+	t.Helper()
+
+	var cfg = config.Default()
+	cfg.Snapshot.Dir = t.TempDir()
+	if opts.Configure != nil {
+		opts.Configure(&cfg)
+	}
+
+	var data = tools.Dataset{}
+	if opts.Accounts == nil {
+		var err error
+		data, err = tools.LoadFixtures("")
+		if err != nil {
+			t.Fatalf("apitest: %v", err)
+		}
+	}
+	var now = opts.Now
+	if now.IsZero() {
+		now = Epoch
+	}
+	var clock = NewClock(now)
+
+	database, err := tools.NewMockDatabase(data, tools.MockScenario{})
+	if err != nil {
+		t.Fatalf("apitest: %v", err)
+	}
+	database.(tools.MockController).SetClock(clock.Now)
+	var store = database
+	if cfg.Database.Coalesce {
+		store = tools.NewCoalescedDatabase(store)
+	}
+	if cfg.Cache.Enabled {
+		var cache = tools.NewCachedDatabase(store, cfg.Cache)
+		cache.SetClock(clock.Now)
+		store = cache
+	}
+
+	var auditLog *audit.Log
+	if cfg.Audit.Enabled {
+		cfg.Audit.File = ""
+		auditLog, err = audit.Open(cfg.Audit)
+		if err != nil {
+			t.Fatalf("apitest: %v", err)
+		}
+		auditLog.SetClock(clock.Now)
+	}
+
+	resolver, err := tenant.NewResolver(cfg.Tenancy)
+	if err != nil {
+		t.Fatalf("apitest: %v", err)
+	}
+
+	var snapshots = snapshot.NewStore(cfg.Snapshot.Dir)
+	snapshots.SetClock(clock.Now)
+
+	var jobScheduler *scheduler.Scheduler
+	var stopJobs = func() {}
+	if cfg.Scheduler.Enabled {
+		jobScheduler = scheduler.New(cfg.Scheduler.History)
+		jobScheduler.SetClock(clock.Now)
+		if err := jobs.Register(jobScheduler, cfg.Scheduler, store); err != nil {
+			t.Fatalf("apitest: %v", err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		var done = make(chan struct{})
+		go func() {
+			defer close(done)
+			jobScheduler.Run(ctx)
+		}()
+		stopJobs = func() {
+			cancel()
+			<-done
+		}
+	}
+
+	var checker = health.NewChecker(cfg.Health.Timeout.Duration)
+	checker.Register("database", store.Ping)
+	checker.MarkReady()
+
+	var router = chi.NewRouter()
+	handlers.Handler(router, handlers.Dependencies{
+		Database:  store,
+		Tenants:   resolver,
+		Snapshots: snapshots,
+		Snapshot:  cfg.Snapshot,
+		Jobs:      jobScheduler,
+		Scheduler: cfg.Scheduler,
+		Audit:     auditLog,
+		Health:    checker,
+		API:       cfg.API,
+		Holds:     cfg.Holds,
+		Dashboard: cfg.Dashboard,
+		Now:       clock.Now,
+	})
+
+	var s = &Server{
+		Server:   httptest.NewServer(router),
+		Config:   cfg,
+		Clock:    clock,
+		database: store,
+		mock:     database.(tools.MockController),
+		audit:    auditLog,
+		jobs:     jobScheduler,
+		t:        t,
+	}
+	t.Cleanup(func() {
+		s.Close()
+		stopJobs()
+		auditLog.Close()
+	})
+	for _, user := range opts.Accounts {
+		s.CreateUser(user)
+	}
+	return s
+}
+
+// User is an account of the server under test. An empty Tenant means the
+// default tenant. Balance is that of the default wallet; Wallets lists any
+// others.
+type User struct {
+	Tenant   string
+	ID       string
+	Username string
+	Name     string
+	Token    string
+	Roles    []string
+	Balance  int64
+	Wallets  []Wallet
+}
+
+// Wallet is a balance in one currency. Balance counts the smallest unit,
+// Precision being the number of decimal places of the currency.
+type Wallet struct {
+	Name      string
+	Currency  string
+	Precision int
+	Balance   int64
+}
+
+// CreateUser adds or replaces an account and returns it with its defaults
+// filled in: the default tenant, a new ID, the username as name and a
+// random token.
+func (s *Server) CreateUser(user User) User {
+	s.t.Helper()
+
+	if user.Tenant == "" {
+		user.Tenant = s.Config.Tenancy.DefaultTenant
+	}
+	if user.ID == "" {
+		user.ID = tools.NewUserID()
+	}
+	if user.Name == "" {
+		user.Name = user.Username
+	}
+	if user.Token == "" {
+		var raw = make([]byte, 12)
+		rand.Read(raw)
+		user.Token = hex.EncodeToString(raw)
+	}
+
+	var wallets = []tools.Wallet{tools.PointsWallet(user.Balance)}
+	for _, wallet := range user.Wallets {
+		wallets = append(wallets, tools.Wallet(wallet))
+	}
+	var err = s.database.PutAccount(s.context(user.Tenant), tools.Account{
+		ID:        user.ID,
+		Username:  user.Username,
+		Name:      user.Name,
+		AuthToken: user.Token,
+		Roles:     user.Roles,
+		Wallets:   wallets,
+	})
+	if err != nil {
+		s.t.Fatalf("apitest: creating %s/%s: %v", user.Tenant, user.Username, err)
+	}
+	return user
+}
+
+// CreateAdmin is CreateUser with the admin role.
+func (s *Server) CreateAdmin(user User) User {
+	s.t.Helper()
+	user.Roles = append(user.Roles, tools.RoleAdmin)
+	return s.CreateUser(user)
+}
+
+// Token returns the auth token of an existing account, such as one of the
+// sample accounts. An empty tenantID means the default tenant.
+func (s *Server) Token(tenantID string, user string) string {
+	s.t.Helper()
+	return s.Login(tenantID, user).Token
+}
+
+// Login returns an existing account, found by ID or username, as a User
+// ready for Client or Do.
+func (s *Server) Login(tenantID string, user string) User {
+	s.t.Helper()
+
+	if tenantID == "" {
+		tenantID = s.Config.Tenancy.DefaultTenant
+	}
+	details, err := s.database.GetUserLoginDetails(s.context(tenantID), user)
+	if err != nil {
+		s.t.Fatalf("apitest: logging in %s/%s: %v", tenantID, user, err)
+	}
+	return User{
+		Tenant:   tenantID,
+		ID:       details.UserID,
+		Username: details.Username,
+		Name:     details.Name,
+		Token:    details.AuthToken,
+		Roles:    details.Roles,
+	}
+}
+
+// Client returns an API client acting as user.
+func (s *Server) Client(user User) *api.Client {
+	var client = api.NewClient(s.URL, user.Username, user.Token)
+	client.Tenant = user.Tenant
+	client.HTTPClient = s.Server.Client()
+	return client
+}
+
+// Do sends a request as user, for routes the client does not cover or
+// requests it cannot make. query may be nil. The response body is closed
+// when the test ends.
+func (s *Server) Do(user User, method string, path string, query url.Values, body io.Reader) *http.Response {
+	s.t.Helper()
+
+	if query == nil {
+		query = url.Values{}
+	}
+	if user.Username != "" {
+		query.Set("username", user.Username)
+	}
+	req, err := http.NewRequest(method, s.URL+path+"?"+query.Encode(), body)
+	if err != nil {
+		s.t.Fatalf("apitest: %v", err)
+	}
+	if user.Token != "" {
+		req.Header.Set("Authorization", user.Token)
+	}
+	if user.Tenant != "" {
+		req.Header.Set(s.Config.Tenancy.Header, user.Tenant)
+	}
+
+	resp, err := s.Server.Client().Do(req)
+	if err != nil {
+		s.t.Fatalf("apitest: %s %s: %v", method, path, err)
+	}
+	s.t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// SetScenario replaces the latency and failures of the store, as a scenario
+// file would for the mock database.
+func (s *Server) SetScenario(scenario MockScenario) {
+	s.t.Helper()
+	if err := s.mock.SetScenario(scenario); err != nil {
+		s.t.Fatalf("apitest: %v", err)
+	}
+}
+
+// AuditEntries returns the audit entries matching filter, newest first. It
+// fails the test if the audit log is disabled or its chain does not verify.
+func (s *Server) AuditEntries(filter AuditFilter) []AuditEntry {
+	s.t.Helper()
+	if s.audit == nil {
+		s.t.Fatalf("apitest: the audit log is disabled")
+	}
+	if err := s.audit.Verify(); err != nil {
+		s.t.Fatalf("apitest: %v", err)
+	}
+	return s.audit.Query(filter)
+}
+
+// RunJob triggers the named job and waits for it to finish, returning the
+// error it failed with. Jobs only run on their schedules in real time, so
+// tests trigger them instead.
+func (s *Server) RunJob(name string) error {
+	s.t.Helper()
+	if s.jobs == nil {
+		s.t.Fatalf("apitest: the scheduler is disabled")
+	}
+	if err := s.jobs.Trigger(name); err != nil {
+		s.t.Fatalf("apitest: running %s: %v", name, err)
+	}
+
+	// The job counts as running from Trigger until its run is recorded.
+	var deadline = time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		status, runs, _ := s.jobs.Job(name)
+		if !status.Running {
+			return runs[0].Err
+		}
+		time.Sleep(time.Millisecond)
+	}
+	s.t.Fatalf("apitest: %s did not finish", name)
+	return nil
+}
+
+// context carries tenantID the way the tenant middleware would.
+func (s *Server) context(tenantID string) context.Context {
+	return tenant.NewContext(context.Background(), tenantID)
+}
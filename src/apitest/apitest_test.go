@@ -0,0 +1,100 @@
+package apitest_test
+
+import (
+	"context"
+	"golearn/src/apitest"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestDebitBeyondBalance(t *testing.T) {
+	var srv = apitest.New(t, apitest.Options{})
+	var user = srv.CreateUser(apitest.User{Username: "alice", Balance: 50})
+	var client = srv.Client(user)
+	var ctx = context.Background()
+
+	_, err := client.Debit(ctx, "points", 80)
+	apitest.AssertError(t, err, http.StatusConflict)
+
+	balance, err := client.GetPointBalance(ctx, "points")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := balance.Wallets[0].Balance; got != 50 {
+		t.Errorf("balance after the rejected debit = %d, want 50", got)
+	}
+
+	var entries = srv.AuditEntries(apitest.AuditFilter{User: user.ID, Action: "balance.debit"})
+	if len(entries) != 1 || entries[0].Outcome != "failure" {
+		t.Errorf("debit audit entries = %+v, want one failure", entries)
+	}
+}
+
+func TestAccounts(t *testing.T) {
+	var srv = apitest.New(t, apitest.Options{
+		Accounts: []apitest.User{{
+			Username: "bob",
+			Balance:  5,
+			Wallets:  []apitest.Wallet{{Name: "eur", Currency: "EUR", Precision: 2, Balance: 1050}},
+		}},
+		Configure: func(cfg *apitest.Config) {
+			cfg.Cache.Enabled = false
+		},
+	})
+	var user = srv.Login("", "bob")
+
+	balance, err := srv.Client(user).GetPointBalance(context.Background(), "eur")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := balance.Wallets[0].Amount(); got != "10.50" {
+		t.Errorf("eur balance = %s, want 10.50", got)
+	}
+
+	// Accounts replaces the sample accounts.
+	var sample = apitest.User{Username: "damien", Token: "ABC123"}
+	var resp = srv.Do(sample, http.MethodGet, "/api/account/balance", nil, nil)
+	apitest.AssertErrorResponse(t, resp, http.StatusNotFound)
+}
+
+func TestHoldExpiry(t *testing.T) {
+	var srv = apitest.New(t, apitest.Options{})
+	var user = srv.CreateUser(apitest.User{Username: "carol", Balance: 50})
+	var client = srv.Client(user)
+	var ctx = context.Background()
+
+	if _, err := client.PlaceHold(ctx, "points", 40, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	_, err := client.Debit(ctx, "points", 20)
+	apitest.AssertError(t, err, http.StatusConflict)
+
+	// An expired hold stops reserving funds before the sweep deletes it.
+	srv.Clock.Advance(2 * time.Minute)
+	if _, err := client.Debit(ctx, "points", 20); err != nil {
+		t.Fatalf("debit after the hold expired: %v", err)
+	}
+	if err := srv.RunJob("expire-holds"); err != nil {
+		t.Fatal(err)
+	}
+	holds, err := client.ListHolds(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(holds.Holds) != 0 {
+		t.Errorf("holds after the sweep = %+v, want none", holds.Holds)
+	}
+}
+
+func TestSetScenario(t *testing.T) {
+	var srv = apitest.New(t, apitest.Options{})
+	var user = srv.CreateUser(apitest.User{Username: "dave", Balance: 50})
+	var always = 1.0
+	srv.SetScenario(apitest.MockScenario{
+		Methods: map[string]apitest.Faults{"GetUserPointDetails": {ErrorRate: &always}},
+	})
+
+	_, err := srv.Client(user).GetPointBalance(context.Background(), "points")
+	apitest.AssertError(t, err, http.StatusInternalServerError)
+}
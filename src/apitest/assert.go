@@ -0,0 +1,56 @@
+package apitest
+
+import (
+	"encoding/json"
+	"errors"
+	"golearn/src/api"
+	"net/http"
+	"testing"
+)
+
+// AssertError fails the test unless err is an api.Error with code, as the
+// client returns for error responses. It returns the error for further
+// checks.
+func AssertError(t testing.TB, err error, code int) api.Error {
+	t.Helper()
+
+	var apiErr api.Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected an api.Error with code %d, got %v", code, err)
+	}
+	if apiErr.Code != code {
+		t.Fatalf("expected an api.Error with code %d, got %d: %s", code, apiErr.Code, apiErr.Message)
+	}
+	return apiErr
+}
+
+// AssertErrorResponse fails the test unless resp has status code and an
+// api.Error body with the same code. It consumes the body.
+func AssertErrorResponse(t testing.TB, resp *http.Response, code int) api.Error {
+	t.Helper()
+
+	if resp.StatusCode != code {
+		t.Fatalf("expected status %d, got %s", code, resp.Status)
+	}
+	var apiErr api.Error
+	if err := json.NewDecoder(resp.Body).Decode(&apiErr); err != nil {
+		t.Fatalf("decoding api.Error: %v", err)
+	}
+	if apiErr.Code != code {
+		t.Fatalf("expected an api.Error with code %d, got %d: %s", code, apiErr.Code, apiErr.Message)
+	}
+	return apiErr
+}
+
+// DecodeJSON fails the test unless resp has status code, then decodes its
+// body into out.
+func DecodeJSON(t testing.TB, resp *http.Response, code int, out any) {
+	t.Helper()
+
+	if resp.StatusCode != code {
+		t.Fatalf("expected status %d, got %s", code, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+}
@@ -0,0 +1,384 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golearn/src/internal/config"
+	"hash"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Actions recorded by the API.
+const (
+	ActionAuthSuccess     = "auth.success"
+	ActionAuthFailure     = "auth.failure"
+	ActionAuthForbidden   = "auth.forbidden"
+	ActionBalanceRead     = "balance.read"
+	ActionBalanceCredit   = "balance.credit"
+	ActionBalanceDebit    = "balance.debit"
+	ActionBalanceAdjust   = "balance.adjust"
+	ActionAuditQuery      = "audit.query"
+	ActionAccountRead     = "account.read"
+	ActionAccountRename   = "account.rename"
+	ActionWalletOpen      = "wallet.open"
+	ActionHoldPlace       = "hold.place"
+	ActionHoldCapture     = "hold.capture"
+	ActionHoldRelease     = "hold.release"
+	ActionStatementRead   = "statement.read"
+	ActionAccountImport   = "account.import"
+	ActionAccountExport   = "account.export"
+	ActionSnapshotCreate  = "snapshot.create"
+	ActionSnapshotRestore = "snapshot.restore"
+	ActionJobRun          = "job.run"
+	ActionJobPause        = "job.pause"
+	ActionJobResume       = "job.resume"
+)
+
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// OutcomeOf is the outcome of an action that returned err.
+func OutcomeOf(err error) string {
+	if err != nil {
+		return OutcomeFailure
+	}
+	return OutcomeSuccess
+}
+
+// genesisHash is the PrevHash of the first entry.
+var genesisHash = strings.Repeat("0", 64)
+
+// Event describes something worth auditing. Actor is the authenticated user
+// and Subject the account acted upon, both within Tenant. Both are user IDs,
+// which survive renames, except for a claimed username that matched no
+// account.
+type Event struct {
+	Tenant    string
+	Action    string
+	Actor     string
+	Subject   string
+	Outcome   string
+	IP        string
+	RequestID string
+	Details   map[string]string
+}
+
+// Entry is a recorded Event. Hash covers every other field, including the
+// previous entry's hash, so editing, removing or reordering entries breaks
+// the chain.
+type Entry struct {
+	Seq       uint64            `json:"seq"`
+	Time      time.Time         `json:"time"`
+	Tenant    string            `json:"tenant,omitempty"`
+	Action    string            `json:"action"`
+	Actor     string            `json:"actor,omitempty"`
+	Subject   string            `json:"subject,omitempty"`
+	Outcome   string            `json:"outcome"`
+	IP        string            `json:"ip,omitempty"`
+	RequestID string            `json:"request_id,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+	PrevHash  string            `json:"prev_hash"`
+	Hash      string            `json:"hash"`
+}
+
+// ChainError reports the first entry whose hash does not verify.
+type ChainError struct {
+	Seq    uint64
+	Reason string
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("audit chain broken at entry %d: %s", e.Seq, e.Reason)
+}
+
+// Log is an append-only, hash-chained audit log kept in memory and, when a
+// file is configured, appended to it as JSON lines. Memory holds at most the
+// newest maxEntries entries; older ones are only in the file. All methods are
+// safe on a nil Log, which records nothing.
+//
+// Without a key the hashes are plain SHA-256, which anyone can recompute
+// after editing the file: the chain then catches accidental damage, not
+// tampering.
+type Log struct {
+	mu         sync.RWMutex
+	entries    []Entry
+	maxEntries int
+	// baseSeq and baseHash are the sequence and hash of the newest entry
+	// dropped from memory, or zero and genesisHash if none was.
+	baseSeq  uint64
+	baseHash string
+	file     *os.File
+	key      []byte
+	now      func() time.Time
+}
+
+// Open loads and verifies the existing log file, if any, and prepares to
+// append to it. A broken chain is reported but does not prevent opening, so
+// that new events are still recorded.
+func Open(cfg config.Audit) (*Log, error) {
+	var l = &Log{maxEntries: cfg.MaxEntries, baseHash: genesisHash, now: time.Now}
+	if cfg.Key != "" {
+		l.key = []byte(cfg.Key)
+	}
+	if cfg.File == "" {
+		return l, nil
+	}
+
+	chainErr, err := l.load(cfg.File)
+	if err != nil {
+		return nil, err
+	}
+	if chainErr != nil {
+		log.Errorf("audit log %s failed verification: %v", cfg.File, chainErr)
+	}
+
+	file, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("opening audit log: %w", err)
+	}
+	l.file = file
+	return l, nil
+}
+
+// SetClock makes the log timestamp new entries with now instead of the
+// system clock.
+func (l *Log) SetClock(now func() time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.now = now
+}
+
+// load reads the log file, verifying the whole chain as it goes since older
+// entries are dropped from memory, and returns the first *ChainError.
+func (l *Log) load(path string) (*ChainError, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading audit log: %w", err)
+	}
+	defer file.Close()
+
+	var chainErr *ChainError
+	var seq, prev = l.baseSeq, l.baseHash
+	var scanner = bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("audit log %s line %d: %w", path, line, err)
+		}
+		if err := l.check(entry, seq, prev); err != nil && chainErr == nil {
+			chainErr = err
+		}
+		seq, prev = entry.Seq, entry.Hash
+		l.append(entry)
+	}
+	return chainErr, scanner.Err()
+}
+
+// append adds entry and, once memory holds more than maxEntries, drops the
+// oldest tenth of them at once so that appending stays cheap.
+func (l *Log) append(entry Entry) {
+	l.entries = append(l.entries, entry)
+	if l.maxEntries <= 0 || len(l.entries) <= l.maxEntries {
+		return
+	}
+	var drop = len(l.entries) - l.maxEntries + l.maxEntries/10
+	l.baseSeq, l.baseHash = l.entries[drop-1].Seq, l.entries[drop-1].Hash
+	l.entries = append([]Entry(nil), l.entries[drop:]...)
+}
+
+// Record appends ev to the log. Failures to persist are logged rather than
+// returned so that auditing never fails the request being audited.
+func (l *Log) Record(ev Event) {
+	if l == nil {
+		return
+	}
+	if ev.Outcome == "" {
+		ev.Outcome = OutcomeSuccess
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var entry = Entry{
+		Seq:       l.baseSeq + uint64(len(l.entries)) + 1,
+		Time:      l.now().UTC(),
+		Tenant:    ev.Tenant,
+		Action:    ev.Action,
+		Actor:     ev.Actor,
+		Subject:   ev.Subject,
+		Outcome:   ev.Outcome,
+		IP:        ev.IP,
+		RequestID: ev.RequestID,
+		Details:   ev.Details,
+		PrevHash:  l.baseHash,
+	}
+	if len(l.entries) > 0 {
+		entry.PrevHash = l.entries[len(l.entries)-1].Hash
+	}
+	entry.Hash = l.hash(entry)
+	l.append(entry)
+
+	if l.file != nil {
+		data, err := json.Marshal(entry)
+		if err == nil {
+			_, err = l.file.Write(append(data, '\n'))
+		}
+		if err != nil {
+			log.Errorf("writing audit entry %d: %v", entry.Seq, err)
+		}
+	}
+}
+
+// Verify recomputes the chain of the entries held in memory, starting from
+// the newest one dropped, and returns a *ChainError for the first entry that
+// does not match. Open verifies the whole file.
+func (l *Log) Verify() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	var seq, prev = l.baseSeq, l.baseHash
+	for _, entry := range l.entries {
+		if err := l.check(entry, seq, prev); err != nil {
+			return err
+		}
+		seq, prev = entry.Seq, entry.Hash
+	}
+	return nil
+}
+
+// check verifies that entry follows the entry with sequence seq and hash
+// prev.
+func (l *Log) check(entry Entry, seq uint64, prev string) *ChainError {
+	if entry.Seq != seq+1 {
+		return &ChainError{Seq: entry.Seq, Reason: fmt.Sprintf("expected sequence %d", seq+1)}
+	}
+	if entry.PrevHash != prev {
+		return &ChainError{Seq: entry.Seq, Reason: "previous hash does not match"}
+	}
+	if !hmac.Equal([]byte(entry.Hash), []byte(l.hash(entry))) {
+		return &ChainError{Seq: entry.Seq, Reason: "entry hash does not match its contents"}
+	}
+	return nil
+}
+
+// Filter selects entries in Query. Empty fields match everything; User
+// matches either the actor or the subject and an Action ending in "*" is a
+// prefix.
+type Filter struct {
+	Tenant string
+	User   string
+	Action string
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+}
+
+// Query returns the newest entries matching f, newest first, among those
+// held in memory.
+func (l *Log) Query(f Filter) []Entry {
+	if l == nil {
+		return []Entry{}
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	var matched = []Entry{}
+	for i := len(l.entries) - 1; i >= 0; i-- {
+		if f.Limit > 0 && len(matched) >= f.Limit {
+			break
+		}
+		var entry = l.entries[i]
+		if f.matches(entry) {
+			matched = append(matched, entry)
+		}
+	}
+	return matched
+}
+
+func (f Filter) matches(entry Entry) bool {
+	if f.Tenant != "" && entry.Tenant != f.Tenant {
+		return false
+	}
+	if f.User != "" && entry.Actor != f.User && entry.Subject != f.User {
+		return false
+	}
+	if prefix, ok := strings.CutSuffix(f.Action, "*"); ok {
+		if !strings.HasPrefix(entry.Action, prefix) {
+			return false
+		}
+	} else if f.Action != "" && entry.Action != f.Action {
+		return false
+	}
+	if !f.Since.IsZero() && entry.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !entry.Time.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+func (l *Log) Close() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var err = l.file.Close()
+	l.file = nil
+	return err
+}
+
+// hash is SHA-256, or HMAC-SHA256 when a key is configured, over a canonical
+// encoding of every field except Hash.
+func (l *Log) hash(entry Entry) string {
+	var h hash.Hash
+	if l.key != nil {
+		h = hmac.New(sha256.New, l.key)
+	} else {
+		h = sha256.New()
+	}
+
+	var keys = make([]string, 0, len(entry.Details))
+	for key := range entry.Details {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var fields = []string{
+		fmt.Sprint(entry.Seq),
+		entry.Time.UTC().Format(time.RFC3339Nano),
+		entry.Tenant, entry.Action, entry.Actor, entry.Subject, entry.Outcome,
+		entry.IP, entry.RequestID,
+	}
+	for _, key := range keys {
+		fields = append(fields, key+"="+entry.Details[key])
+	}
+	fields = append(fields, entry.PrevHash)
+
+	// Length-prefix every field so that no two entries encode identically.
+	for _, field := range fields {
+		fmt.Fprintf(h, "%d:%s;", len(field), field)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
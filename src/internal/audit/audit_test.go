@@ -0,0 +1,216 @@
+package audit
+
+import (
+	"errors"
+	"golearn/src/internal/config"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// record writes n balance reads to l, one second apart.
+func record(l *Log, n int) {
+	var now = time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+	l.SetClock(func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	})
+	for i := 0; i < n; i++ {
+		l.Record(Event{Tenant: "default", Action: ActionBalanceRead, Actor: "usr_1", Details: map[string]string{"wallet": "points"}})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	var tests = []struct {
+		name    string
+		key     string
+		tamper  func(entries []Entry) []Entry
+		wantSeq uint64
+	}{
+		{name: "intact", tamper: func(entries []Entry) []Entry { return entries }},
+		{name: "intact with key", key: "secret", tamper: func(entries []Entry) []Entry { return entries }},
+		{name: "edited outcome", tamper: func(entries []Entry) []Entry {
+			entries[2].Outcome = OutcomeFailure
+			return entries
+		}, wantSeq: 3},
+		{name: "edited details", key: "secret", tamper: func(entries []Entry) []Entry {
+			entries[1].Details = map[string]string{"wallet": "eur"}
+			return entries
+		}, wantSeq: 2},
+		{name: "removed entry", tamper: func(entries []Entry) []Entry {
+			return append(entries[:1], entries[2:]...)
+		}, wantSeq: 3},
+		{name: "reordered entries", tamper: func(entries []Entry) []Entry {
+			entries[1], entries[2] = entries[2], entries[1]
+			return entries
+		}, wantSeq: 3},
+		{name: "rehashed edit", tamper: func(entries []Entry) []Entry {
+			// Without a key anyone can recompute the hash of an edited
+			// entry, but the next entry still points at the old one.
+			var l = &Log{}
+			entries[0].Actor = "usr_2"
+			entries[0].Hash = l.hash(entries[0])
+			return entries
+		}, wantSeq: 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l, err := Open(config.Audit{Enabled: true, Key: test.key})
+			if err != nil {
+				t.Fatal(err)
+			}
+			record(l, 4)
+			l.entries = test.tamper(l.entries)
+
+			err = l.Verify()
+			if test.wantSeq == 0 {
+				if err != nil {
+					t.Fatalf("Verify = %v, want nil", err)
+				}
+				return
+			}
+			var chainErr *ChainError
+			if !errors.As(err, &chainErr) || chainErr.Seq != test.wantSeq {
+				t.Fatalf("Verify = %v, want a ChainError at entry %d", err, test.wantSeq)
+			}
+		})
+	}
+}
+
+func TestVerifyFile(t *testing.T) {
+	var path = filepath.Join(t.TempDir(), "audit.log")
+	l, err := Open(config.Audit{Enabled: true, File: path, Key: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	record(l, 3)
+	l.Close()
+
+	var tests = []struct {
+		name string
+		key  string
+		edit func(data string) string
+		ok   bool
+	}{
+		{name: "unchanged", key: "secret", edit: func(data string) string { return data }, ok: true},
+		{name: "other key", key: "other", edit: func(data string) string { return data }},
+		{name: "no key", edit: func(data string) string { return data }},
+		{name: "edited actor", key: "secret", edit: func(data string) string {
+			return strings.Replace(data, `"actor":"usr_1"`, `"actor":"usr_2"`, 1)
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var copied = filepath.Join(t.TempDir(), "audit.log")
+			if err := os.WriteFile(copied, []byte(test.edit(string(data))), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			l, err := Open(config.Audit{Enabled: true, File: copied, Key: test.key})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer l.Close()
+			if err := l.Verify(); (err == nil) != test.ok {
+				t.Fatalf("Verify = %v, want ok %v", err, test.ok)
+			}
+		})
+	}
+}
+
+func TestMaxEntries(t *testing.T) {
+	var path = filepath.Join(t.TempDir(), "audit.log")
+	var cfg = config.Audit{Enabled: true, File: path, MaxEntries: 10}
+	l, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record(l, 25)
+
+	var entries = l.Query(Filter{})
+	if len(entries) > 10 || entries[0].Seq != 25 {
+		t.Fatalf("got %d entries, newest %d; want at most 10, newest 25", len(entries), entries[0].Seq)
+	}
+	if err := l.Verify(); err != nil {
+		t.Fatalf("Verify after trimming: %v", err)
+	}
+	l.Close()
+
+	// Reopening verifies the whole file but keeps only the newest entries,
+	// and the chain carries on from the last one.
+	l, err = Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	record(l, 1)
+	entries = l.Query(Filter{})
+	if len(entries) > 10 || entries[0].Seq != 26 {
+		t.Fatalf("got %d entries, newest %d; want at most 10, newest 26", len(entries), entries[0].Seq)
+	}
+	if err := l.Verify(); err != nil {
+		t.Fatalf("Verify after reopening: %v", err)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	l, err := Open(config.Audit{Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var now = time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+	l.SetClock(func() time.Time { return now })
+	for _, ev := range []Event{
+		{Tenant: "default", Action: ActionBalanceRead, Actor: "usr_1"},
+		{Tenant: "default", Action: ActionBalanceDebit, Actor: "usr_1", Subject: "usr_2"},
+		{Tenant: "acme", Action: ActionBalanceCredit, Actor: "usr_3"},
+		{Tenant: "default", Action: ActionAccountRead, Actor: "usr_2"},
+	} {
+		l.Record(ev)
+		now = now.Add(time.Minute)
+	}
+
+	var tests = []struct {
+		name   string
+		filter Filter
+		want   []uint64
+	}{
+		{"all", Filter{}, []uint64{4, 3, 2, 1}},
+		{"limit", Filter{Limit: 2}, []uint64{4, 3}},
+		{"tenant", Filter{Tenant: "acme"}, []uint64{3}},
+		{"actor or subject", Filter{User: "usr_2"}, []uint64{4, 2}},
+		{"action", Filter{Action: ActionBalanceRead}, []uint64{1}},
+		{"action prefix", Filter{Action: "balance.*"}, []uint64{3, 2, 1}},
+		{"since", Filter{Since: now.Add(-2 * time.Minute)}, []uint64{4, 3}},
+		{"until", Filter{Until: now.Add(-3 * time.Minute)}, []uint64{1}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []uint64
+			for _, entry := range l.Query(test.filter) {
+				got = append(got, entry.Seq)
+			}
+			if !slices.Equal(got, test.want) {
+				t.Fatalf("Query = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestNilLog(t *testing.T) {
+	var l *Log
+	l.Record(Event{Action: ActionBalanceRead})
+	if err := l.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	if entries := l.Query(Filter{}); len(entries) != 0 {
+		t.Fatalf("Query on a nil Log = %v", entries)
+	}
+}
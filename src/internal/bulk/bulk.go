@@ -0,0 +1,190 @@
+package bulk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golearn/src/internal/tenant"
+	"golearn/src/internal/tools"
+	"io"
+	"strings"
+)
+
+// knownRoles are the roles an imported account may carry.
+var knownRoles = map[string]bool{tools.RoleAdmin: true}
+
+// Validate checks a single account before it is written and reports every
+// problem with it in one error.
+func Validate(account tools.Account) error {
+	var errs []error
+	if account.ID != "" && !tools.IsUserID(account.ID) {
+		errs = append(errs, fmt.Errorf("%w, got %q", tools.ErrInvalidUserID, account.ID))
+	}
+	if err := tools.ValidateUsername(account.Username); err != nil {
+		errs = append(errs, err)
+	}
+	if account.AuthToken == "" {
+		errs = append(errs, errors.New("token must not be empty"))
+	} else if strings.ContainsAny(account.AuthToken, " \t\r\n") {
+		errs = append(errs, errors.New("token must not contain whitespace"))
+	}
+	if err := tools.ValidateWallets(account.Wallets); err != nil {
+		errs = append(errs, err)
+	}
+	if err := tools.ValidateHolds(account.Wallets, account.Holds); err != nil {
+		errs = append(errs, err)
+	}
+	for _, role := range account.Roles {
+		if !knownRoles[role] {
+			errs = append(errs, fmt.Errorf("unknown role %q", role))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	var messages = make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return errors.New(strings.Join(messages, "; "))
+}
+
+type ImportOptions struct {
+	// DryRun imports into a copy of the tenant's accounts, so that it
+	// rejects the same rows a real import would, and writes nothing.
+	DryRun bool
+	// OnError is called for every rejected row, in input order.
+	OnError func(*RowError)
+}
+
+type ImportResult struct {
+	Rows     int
+	Imported int
+	Failed   int
+}
+
+// importBatchSize is how many rows Import writes to the store at once.
+const importBatchSize = 500
+
+// Import writes every valid row of r to database, or to a scratch copy of
+// the tenant's accounts in a dry run. Rows are written in batches as they are read, so a failed
+// import may have applied the batches before the failure; invalid rows are
+// skipped and reported through opts.OnError. A row with an ID replaces the
+// account with that ID, renaming it if the username differs; a row without
+// one replaces the account with its username. Later rows for the same
+// account replace earlier ones.
+func Import(ctx context.Context, database tools.DatabaseInterface, r *Reader, opts ImportOptions) (ImportResult, error) {
+	var result = ImportResult{}
+	if opts.DryRun {
+		scratch, err := scratchCopy(ctx, database)
+		if err != nil {
+			return result, err
+		}
+		database = scratch
+	}
+	var reject = func(rowErr *RowError) {
+		result.Failed++
+		if opts.OnError != nil {
+			opts.OnError(rowErr)
+		}
+	}
+
+	// rows holds the batch being read, with the rows that failed to parse
+	// kept in place so that errors are reported in input order.
+	type row struct {
+		account tools.Account
+		line    int
+		err     *RowError
+	}
+	var rows []row
+	var flush = func() error {
+		var accounts []tools.Account
+		for _, row := range rows {
+			if row.err == nil {
+				accounts = append(accounts, row.account)
+			}
+		}
+		var errs = make([]error, len(accounts))
+		if len(accounts) > 0 {
+			var err error
+			if errs, err = database.PutAccounts(ctx, accounts); err != nil {
+				return err
+			}
+		}
+		var i int
+		for _, row := range rows {
+			if row.err != nil {
+				reject(row.err)
+				continue
+			}
+			if errs[i] != nil {
+				reject(&RowError{Line: row.line, Username: row.account.Username, Err: errs[i]})
+			} else {
+				result.Imported++
+			}
+			i++
+		}
+		rows = rows[:0]
+		return nil
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		account, line, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return result, flush()
+		}
+		var rowErr *RowError
+		if errors.As(err, &rowErr) {
+			result.Rows++
+			rows = append(rows, row{err: rowErr})
+			continue
+		}
+		if err != nil {
+			return result, err
+		}
+
+		result.Rows++
+		rows = append(rows, row{account: account, line: line})
+		if len(rows) == importBatchSize {
+			if err = flush(); err != nil {
+				return result, err
+			}
+		}
+	}
+}
+
+// scratchCopy copies the accounts of the context's tenant into an in-memory
+// store.
+func scratchCopy(ctx context.Context, database tools.DatabaseInterface) (tools.DatabaseInterface, error) {
+	tenantID, err := tenant.MustFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var accounts []tools.Account
+	err = database.ListAccounts(ctx, func(account tools.Account) error {
+		accounts = append(accounts, account)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tools.NewMockDatabase(tools.Dataset{tenantID: accounts}, tools.MockScenario{})
+}
+
+// Export writes every account of the context's tenant to w and returns how
+// many were written.
+func Export(ctx context.Context, database tools.DatabaseInterface, w *Writer) (int, error) {
+	var count int
+	var err = database.ListAccounts(ctx, func(account tools.Account) error {
+		count++
+		return w.Write(account)
+	})
+	if err != nil {
+		return count, err
+	}
+	return count, w.Flush()
+}
@@ -0,0 +1,68 @@
+package bulk
+
+import (
+	"context"
+	"errors"
+	"golearn/src/internal/tenant"
+	"golearn/src/internal/tools"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// importFile has a new account, a row taking its username under another ID,
+// a row without a token, a row taking an existing username and a rename of
+// the existing account.
+const importFile = `id,username,token,balance
+,bob,b,10
+usr_00000000000000b2,bob,b2,20
+,carol,,30
+usr_00000000000000b3,alice,a2,40
+usr_00000000000000a1,alicia,a,50
+`
+
+func TestImportDryRun(t *testing.T) {
+	var ctx = tenant.NewContext(context.Background(), "default")
+	var run = func(database tools.DatabaseInterface, dryRun bool) (ImportResult, []int) {
+		t.Helper()
+		reader, err := NewReader(strings.NewReader(importFile), FormatCSV)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var lines []int
+		result, err := Import(ctx, database, reader, ImportOptions{
+			DryRun:  dryRun,
+			OnError: func(rowErr *RowError) { lines = append(lines, rowErr.Line) },
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result, lines
+	}
+	var newStore = func() tools.DatabaseInterface {
+		database, err := tools.NewMockDatabase(tools.Dataset{"default": {{
+			ID: "usr_00000000000000a1", Username: "alice", AuthToken: "a",
+		}}}, tools.MockScenario{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return database
+	}
+
+	var database = newStore()
+	dryResult, dryLines := run(database, true)
+	if _, err := database.GetUserLoginDetails(ctx, "bob"); !errors.Is(err, tools.ErrUserNotFound) {
+		t.Fatalf("the dry run wrote bob: %v", err)
+	}
+
+	result, lines := run(newStore(), false)
+	if dryResult != result || !slices.Equal(dryLines, lines) {
+		t.Fatalf("dry run got %+v rejecting lines %v, the import %+v rejecting %v", dryResult, dryLines, result, lines)
+	}
+	if want := (ImportResult{Rows: 5, Imported: 2, Failed: 3}); result != want {
+		t.Fatalf("import = %+v, want %+v", result, want)
+	}
+	if want := []int{3, 4, 5}; !slices.Equal(lines, want) {
+		t.Fatalf("rejected lines %v, want %v", lines, want)
+	}
+}
@@ -0,0 +1,347 @@
+package bulk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golearn/src/internal/tools"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Formats understood by Reader and Writer.
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// csvColumns is the header written by Writer. Readers accept the columns in
+// any order; only username and token are required.
+var csvColumns = []string{"id", "username", "name", "token", "roles", "balance", "wallets"}
+
+// ParseFormat checks that format is supported.
+func ParseFormat(format string) (string, error) {
+	switch format = strings.ToLower(format); format {
+	case FormatCSV, FormatNDJSON:
+		return format, nil
+	case "jsonl":
+		return FormatNDJSON, nil
+	}
+	return "", fmt.Errorf("unsupported format %q, expected csv or ndjson", format)
+}
+
+// FormatFromPath guesses the format from a file extension.
+func FormatFromPath(path string) (string, error) {
+	return ParseFormat(strings.TrimPrefix(filepath.Ext(path), "."))
+}
+
+// ContentType is the media type of format.
+func ContentType(format string) string {
+	if format == FormatCSV {
+		return "text/csv"
+	}
+	return "application/x-ndjson"
+}
+
+// row is one account as it appears in NDJSON. Balance is that of the
+// default wallet and Wallets lists the others. CSV rows hold the same
+// fields, with roles separated by ";" and wallets written as
+// name:currency:precision:balance, also separated by ";".
+type row struct {
+	ID       string      `json:"id,omitempty"`
+	Username string      `json:"username"`
+	Name     string      `json:"name,omitempty"`
+	Token    string      `json:"token"`
+	Roles    []string    `json:"roles,omitempty"`
+	Balance  int64       `json:"balance"`
+	Wallets  []walletRow `json:"wallets,omitempty"`
+}
+
+type walletRow struct {
+	Name      string `json:"name"`
+	Currency  string `json:"currency"`
+	Precision int    `json:"precision"`
+	Balance   int64  `json:"balance"`
+}
+
+// RowError is a problem with a single row. Import skips the row and carries
+// on.
+type RowError struct {
+	Line     int
+	Username string
+	Err      error
+}
+
+func (e *RowError) Error() string {
+	if e.Username == "" {
+		return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+	}
+	return fmt.Sprintf("line %d (%s): %v", e.Line, e.Username, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// Reader decodes accounts one row at a time, so inputs of any size are read
+// in constant memory.
+type Reader struct {
+	next func() (tools.Account, int, error)
+}
+
+// NewReader returns a Reader for format. For CSV the header row is read
+// straight away.
+func NewReader(r io.Reader, format string) (*Reader, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVReader(r)
+	case FormatNDJSON:
+		return newNDJSONReader(r), nil
+	}
+	return nil, fmt.Errorf("unsupported format %q", format)
+}
+
+// Next returns the next account and its line number. Problems with the row
+// itself are returned as a *RowError and reading may continue; any other
+// error, including io.EOF, ends the input.
+func (r *Reader) Next() (tools.Account, int, error) {
+	return r.next()
+}
+
+func newCSVReader(r io.Reader) (*Reader, error) {
+	var cr = csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("csv: missing header row")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("csv header: %w", err)
+	}
+
+	var columns = map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, dup := columns[name]; dup {
+			return nil, fmt.Errorf("csv header: duplicate column %q", name)
+		}
+		columns[name] = i
+	}
+	for _, required := range []string{"username", "token"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("csv header: missing column %q", required)
+		}
+	}
+
+	var field = func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	return &Reader{next: func() (tools.Account, int, error) {
+		record, err := cr.Read()
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return tools.Account{}, parseErr.Line, &RowError{Line: parseErr.Line, Err: parseErr.Err}
+		}
+		if err != nil {
+			return tools.Account{}, 0, err
+		}
+		var line, _ = cr.FieldPos(0)
+		if len(record) != len(header) {
+			return tools.Account{}, line, &RowError{Line: line, Username: field(record, "username"),
+				Err: fmt.Errorf("expected %d fields, got %d", len(header), len(record))}
+		}
+
+		var parsed = row{
+			ID:       field(record, "id"),
+			Username: field(record, "username"),
+			Name:     field(record, "name"),
+			Token:    field(record, "token"),
+		}
+		if roles := field(record, "roles"); roles != "" {
+			parsed.Roles = strings.Split(roles, ";")
+		}
+		if balance := field(record, "balance"); balance != "" {
+			parsed.Balance, err = strconv.ParseInt(balance, 10, 64)
+			if err != nil {
+				return tools.Account{}, line, &RowError{Line: line, Username: parsed.Username,
+					Err: fmt.Errorf("balance %q is not an integer", balance)}
+			}
+		}
+		if wallets := field(record, "wallets"); wallets != "" {
+			parsed.Wallets, err = parseWallets(wallets)
+			if err != nil {
+				return tools.Account{}, line, &RowError{Line: line, Username: parsed.Username, Err: err}
+			}
+		}
+		return toAccount(parsed, line)
+	}}, nil
+}
+
+func newNDJSONReader(r io.Reader) *Reader {
+	var scanner = bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var line int
+
+	return &Reader{next: func() (tools.Account, int, error) {
+		for scanner.Scan() {
+			line++
+			var data = scanner.Bytes()
+			if len(bytes.TrimSpace(data)) == 0 {
+				continue
+			}
+
+			var parsed row
+			var decoder = json.NewDecoder(bytes.NewReader(data))
+			decoder.DisallowUnknownFields()
+			if err := decoder.Decode(&parsed); err != nil {
+				return tools.Account{}, line, &RowError{Line: line, Err: err}
+			}
+			if decoder.More() {
+				return tools.Account{}, line, &RowError{Line: line, Username: parsed.Username, Err: errors.New("unexpected data after the JSON object")}
+			}
+			return toAccount(parsed, line)
+		}
+		if err := scanner.Err(); err != nil {
+			return tools.Account{}, line, fmt.Errorf("line %d: %w", line+1, err)
+		}
+		return tools.Account{}, line, io.EOF
+	}}
+}
+
+// parseWallets decodes the wallets column of a CSV row.
+func parseWallets(value string) ([]walletRow, error) {
+	var wallets []walletRow
+	for _, entry := range strings.Split(value, ";") {
+		var parts = strings.Split(entry, ":")
+		if len(parts) != 4 {
+			return nil, fmt.Errorf("wallet %q must be name:currency:precision:balance", entry)
+		}
+		precision, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("wallet %q: precision is not an integer", entry)
+		}
+		balance, err := strconv.ParseInt(parts[3], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("wallet %q: balance is not an integer", entry)
+		}
+		wallets = append(wallets, walletRow{Name: parts[0], Currency: parts[1], Precision: precision, Balance: balance})
+	}
+	return wallets, nil
+}
+
+func formatWallets(wallets []walletRow) string {
+	var entries = make([]string, len(wallets))
+	for i, wallet := range wallets {
+		entries[i] = fmt.Sprintf("%s:%s:%d:%d", wallet.Name, wallet.Currency, wallet.Precision, wallet.Balance)
+	}
+	return strings.Join(entries, ";")
+}
+
+func toAccount(parsed row, line int) (tools.Account, int, error) {
+	var account = tools.Account{
+		ID:        parsed.ID,
+		Username:  parsed.Username,
+		Name:      parsed.Name,
+		AuthToken: parsed.Token,
+		Roles:     parsed.Roles,
+		Wallets:   []tools.Wallet{tools.PointsWallet(parsed.Balance)},
+	}
+	if account.Name == "" {
+		account.Name = account.Username
+	}
+	for _, wallet := range parsed.Wallets {
+		if wallet.Name == tools.DefaultWallet {
+			return account, line, &RowError{Line: line, Username: account.Username,
+				Err: fmt.Errorf("the %s wallet is set by balance", tools.DefaultWallet)}
+		}
+		account.Wallets = append(account.Wallets, tools.Wallet(wallet))
+	}
+	if err := Validate(account); err != nil {
+		return account, line, &RowError{Line: line, Username: account.Username, Err: err}
+	}
+	return account, line, nil
+}
+
+// Writer encodes accounts one row at a time.
+type Writer struct {
+	write func(tools.Account) error
+	flush func() error
+}
+
+// NewWriter returns a Writer for format. For CSV the header row is written
+// straight away.
+func NewWriter(w io.Writer, format string) (*Writer, error) {
+	switch format {
+	case FormatCSV:
+		var cw = csv.NewWriter(w)
+		if err := cw.Write(csvColumns); err != nil {
+			return nil, err
+		}
+		return &Writer{
+			write: func(account tools.Account) error {
+				var exported = toRow(account)
+				return cw.Write([]string{
+					exported.ID,
+					exported.Username,
+					exported.Name,
+					exported.Token,
+					strings.Join(exported.Roles, ";"),
+					strconv.FormatInt(exported.Balance, 10),
+					formatWallets(exported.Wallets),
+				})
+			},
+			flush: func() error {
+				cw.Flush()
+				return cw.Error()
+			},
+		}, nil
+	case FormatNDJSON:
+		var buffered = bufio.NewWriter(w)
+		var encoder = json.NewEncoder(buffered)
+		return &Writer{
+			write: func(account tools.Account) error {
+				return encoder.Encode(toRow(account))
+			},
+			flush: buffered.Flush,
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported format %q", format)
+}
+
+func toRow(account tools.Account) row {
+	var exported = row{
+		ID:       account.ID,
+		Username: account.Username,
+		Name:     account.Name,
+		Token:    account.AuthToken,
+		Roles:    account.Roles,
+	}
+	for _, wallet := range account.Wallets {
+		if wallet.Name == tools.DefaultWallet {
+			exported.Balance = wallet.Balance
+		} else {
+			exported.Wallets = append(exported.Wallets, walletRow(wallet))
+		}
+	}
+	return exported
+}
+
+func (w *Writer) Write(account tools.Account) error {
+	return w.write(account)
+}
+
+// Flush writes any buffered rows. Call it once all accounts are written.
+func (w *Writer) Flush() error {
+	return w.flush()
+}
@@ -0,0 +1,546 @@
+package cli
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"golearn/src/api"
+	"golearn/src/internal/bulk"
+	"golearn/src/internal/config"
+	"golearn/src/internal/statement"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const accountUsage = `account [flags] <action> [arguments]
+
+Actions:
+  balance [wallet] Print the balance of every wallet, or of one wallet
+  credit <amount> [wallet]
+                   Add amount minor units to a wallet, by default points
+                   (admin only)
+  debit <amount> [wallet]
+                   Remove amount minor units from a wallet
+  hold [--ttl duration] <amount> [wallet]
+                   Reserve amount minor units of a wallet until they are
+                   captured, released or the hold expires
+  holds            Print the active holds
+  capture <hold> [amount]
+                   Debit part of a hold, or all that it still reserves
+  release <hold>   End a hold without debiting anything
+  statement [--format html|text|json] <period> [file]
+                   Download the statement of a month, written as YYYY-MM,
+                   to a file or stdout
+  show [user]      Print the account, or another account by ID or username
+                   (admin only for other accounts)
+  rename <user> <new-username>
+                   Change the username of an account; its ID stays the same
+                   (admin only)
+  open-wallet <user> <name> <currency> [precision]
+                   Add an empty wallet to an account (admin only)
+  balances [--wallet name] <user>...
+                   Print the balances of several accounts by ID or username
+                   (admin only)
+  import [--format csv|ndjson] [--dry-run] <file>
+                   Create or replace accounts from a file, or - for stdin
+                   (admin only)
+  export [--format csv|ndjson] [file]
+                   Write every account to a file, or stdout (admin only)`
+
+func runAccount(args []string, stdout io.Writer, stderr io.Writer) int {
+	var fs = newFlagSet("account", accountUsage, stderr)
+	var cf = newConfigFlags(fs)
+	bindClientFlags(fs, cf)
+
+	if code, ok := parseFlags(fs, args, stdout); !ok {
+		return code
+	}
+
+	cfg, code, ok := cf.load(stdout, stderr)
+	if !ok {
+		return code
+	}
+	var username = cfg.Client.Username
+
+	if fs.NArg() == 0 {
+		fmt.Fprintln(stderr, "account: missing action")
+		fs.Usage()
+		return ExitUsage
+	}
+	client, code, ok := newClient("account", cfg.Client, stderr)
+	if !ok {
+		return code
+	}
+	var ctx = context.Background()
+	var response *api.PointBalanceResponse
+	var err error
+
+	switch action := fs.Arg(0); action {
+	case "balance":
+		if fs.NArg() > 2 {
+			fmt.Fprintln(stderr, "account balance: expected at most one wallet")
+			return ExitUsage
+		}
+		response, err = client.GetPointBalance(ctx, fs.Arg(1))
+	case "credit", "debit":
+		if fs.NArg() != 2 && fs.NArg() != 3 {
+			fmt.Fprintf(stderr, "account %s: expected an amount and optionally a wallet\n", action)
+			return ExitUsage
+		}
+		amount, parseErr := strconv.ParseInt(fs.Arg(1), 10, 64)
+		if parseErr != nil || amount <= 0 {
+			fmt.Fprintf(stderr, "account %s: amount must be a positive integer, got %q\n", action, fs.Arg(1))
+			return ExitUsage
+		}
+		if action == "credit" {
+			response, err = client.Credit(ctx, fs.Arg(2), amount)
+		} else {
+			response, err = client.Debit(ctx, fs.Arg(2), amount)
+		}
+	case "hold":
+		return placeHold(ctx, client, username, fs.Args()[1:], stdout, stderr)
+	case "holds":
+		if fs.NArg() != 1 {
+			fmt.Fprintln(stderr, "account holds: expected no arguments")
+			return ExitUsage
+		}
+		holds, err := client.ListHolds(ctx)
+		if err != nil {
+			return reportClientError(stderr, err)
+		}
+		for _, hold := range holds.Holds {
+			printHold(stdout, hold, "")
+		}
+		return ExitOK
+	case "capture":
+		if fs.NArg() != 2 && fs.NArg() != 3 {
+			fmt.Fprintln(stderr, "account capture: expected a hold and optionally an amount")
+			return ExitUsage
+		}
+		var amount int64
+		if fs.NArg() == 3 {
+			var parseErr error
+			amount, parseErr = strconv.ParseInt(fs.Arg(2), 10, 64)
+			if parseErr != nil || amount <= 0 {
+				fmt.Fprintf(stderr, "account capture: amount must be a positive integer, got %q\n", fs.Arg(2))
+				return ExitUsage
+			}
+		}
+		hold, err := client.CaptureHold(ctx, fs.Arg(1), amount)
+		if err != nil {
+			return reportClientError(stderr, err)
+		}
+		printHold(stdout, hold.Hold, hold.Wallet.Currency)
+		printWallets(stdout, username, []api.WalletBalance{hold.Wallet})
+		return ExitOK
+	case "release":
+		if fs.NArg() != 2 {
+			fmt.Fprintln(stderr, "account release: expected a hold")
+			return ExitUsage
+		}
+		hold, err := client.ReleaseHold(ctx, fs.Arg(1))
+		if err != nil {
+			return reportClientError(stderr, err)
+		}
+		printHold(stdout, hold.Hold, hold.Wallet.Currency)
+		printWallets(stdout, username, []api.WalletBalance{hold.Wallet})
+		return ExitOK
+	case "show":
+		if fs.NArg() > 2 {
+			fmt.Fprintln(stderr, "account show: expected at most one user")
+			return ExitUsage
+		}
+		var account *api.AccountResponse
+		if fs.NArg() == 2 {
+			account, err = client.GetUserAccount(ctx, fs.Arg(1))
+		} else {
+			account, err = client.GetAccount(ctx)
+		}
+		if err != nil {
+			return reportClientError(stderr, err)
+		}
+		printAccount(stdout, account)
+		return ExitOK
+	case "rename":
+		if fs.NArg() != 3 {
+			fmt.Fprintln(stderr, "account rename: expected a user and a new username")
+			return ExitUsage
+		}
+		account, err := client.RenameAccount(ctx, fs.Arg(1), fs.Arg(2))
+		if err != nil {
+			return reportClientError(stderr, err)
+		}
+		printAccount(stdout, account)
+		return ExitOK
+	case "open-wallet":
+		if fs.NArg() != 4 && fs.NArg() != 5 {
+			fmt.Fprintln(stderr, "account open-wallet: expected a user, a wallet name, a currency and optionally a precision")
+			return ExitUsage
+		}
+		var precision int
+		if fs.NArg() == 5 {
+			var parseErr error
+			precision, parseErr = strconv.Atoi(fs.Arg(4))
+			if parseErr != nil {
+				fmt.Fprintf(stderr, "account open-wallet: precision must be an integer, got %q\n", fs.Arg(4))
+				return ExitUsage
+			}
+		}
+		account, err := client.OpenWallet(ctx, fs.Arg(1), fs.Arg(2), fs.Arg(3), precision)
+		if err != nil {
+			return reportClientError(stderr, err)
+		}
+		printAccount(stdout, account)
+		return ExitOK
+	case "statement":
+		return downloadStatement(ctx, client, fs.Args()[1:], stdout, stderr)
+	case "balances":
+		return printBalances(ctx, client, fs.Args()[1:], stdout, stderr)
+	case "import":
+		return importAccounts(ctx, client, fs.Args()[1:], stdout, stderr)
+	case "export":
+		return exportAccounts(ctx, client, fs.Args()[1:], stdout, stderr)
+	default:
+		fmt.Fprintf(stderr, "account: unknown action %q\n", action)
+		fs.Usage()
+		return ExitUsage
+	}
+
+	if err != nil {
+		return reportClientError(stderr, err)
+	}
+
+	printWallets(stdout, username, response.Wallets)
+	return ExitOK
+}
+
+// printWallets prints one line per wallet, such as "damien miles: 2500 MILES",
+// followed by the available amount when holds reserve part of it.
+func printWallets(w io.Writer, username string, wallets []api.WalletBalance) {
+	for _, wallet := range wallets {
+		fmt.Fprintf(w, "%s %s: %s %s", username, wallet.Name, wallet.Amount(), wallet.Currency)
+		if wallet.Available != wallet.Balance {
+			fmt.Fprintf(w, " (%s available)", api.FormatAmount(wallet.Available, wallet.Precision))
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// printHold prints a hold on one line, such as
+// "hld_0123456789abcdef points: 300 held, 0 captured, active until ...".
+// Amounts are in minor units; currency is added when known.
+func printHold(w io.Writer, hold api.HoldInfo, currency string) {
+	var unit string
+	if currency != "" {
+		unit = " " + currency
+	}
+	fmt.Fprintf(w, "%s %s: %d%s held, %d%s captured, %s", hold.ID, hold.Wallet, hold.Amount, unit, hold.Captured, unit, hold.Status)
+	if hold.Status == api.HoldActive {
+		fmt.Fprintf(w, " until %s", hold.ExpiresAt)
+	}
+	fmt.Fprintln(w)
+}
+
+// placeHold runs the hold action.
+func placeHold(ctx context.Context, client *api.Client, username string, args []string, stdout io.Writer, stderr io.Writer) int {
+	var fs = newFlagSet("account hold", "account [flags] hold [--ttl duration] <amount> [wallet]", stderr)
+	var ttl = fs.Duration("ttl", 0, "how long the hold lasts (default set by the server)")
+	if code, ok := parseFlags(fs, args, stdout); !ok {
+		return code
+	}
+	if fs.NArg() != 1 && fs.NArg() != 2 {
+		fmt.Fprintln(stderr, "account hold: expected an amount and optionally a wallet")
+		fs.Usage()
+		return ExitUsage
+	}
+	amount, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+	if err != nil || amount <= 0 {
+		fmt.Fprintf(stderr, "account hold: amount must be a positive integer, got %q\n", fs.Arg(0))
+		return ExitUsage
+	}
+
+	hold, err := client.PlaceHold(ctx, fs.Arg(1), amount, *ttl)
+	if err != nil {
+		return reportClientError(stderr, err)
+	}
+	printHold(stdout, hold.Hold, hold.Wallet.Currency)
+	printWallets(stdout, username, []api.WalletBalance{hold.Wallet})
+	return ExitOK
+}
+
+func printAccount(w io.Writer, account *api.AccountResponse) {
+	fmt.Fprintf(w, "id:       %s\n", account.UserID)
+	fmt.Fprintf(w, "username: %s\n", account.Username)
+	fmt.Fprintf(w, "name:     %s\n", account.Name)
+	fmt.Fprintf(w, "roles:    %s\n", strings.Join(account.Roles, ", "))
+	for _, wallet := range account.Wallets {
+		fmt.Fprintf(w, "wallet:   %s %s %s\n", wallet.Name, wallet.Amount(), wallet.Currency)
+	}
+	for _, rename := range account.Renames {
+		fmt.Fprintf(w, "renamed:  %s -> %s at %s\n", rename.From, rename.To, rename.At)
+	}
+}
+
+// printBalances runs the balances action. It prints one line per wallet
+// and fails if any lookup failed.
+func printBalances(ctx context.Context, client *api.Client, args []string, stdout io.Writer, stderr io.Writer) int {
+	var fs = newFlagSet("account balances", "account [flags] balances [--wallet name] <user>...", stderr)
+	var wallet = fs.String("wallet", "", "only print the `wallet` of this name")
+	if code, ok := parseFlags(fs, args, stdout); !ok {
+		return code
+	}
+	if fs.NArg() == 0 {
+		fmt.Fprintln(stderr, "account balances: expected at least one username")
+		fs.Usage()
+		return ExitUsage
+	}
+
+	response, err := client.GetPointBalances(ctx, fs.Args(), *wallet)
+	if err != nil {
+		return reportClientError(stderr, err)
+	}
+
+	var code = ExitOK
+	for _, result := range response.Results {
+		if result.Code != http.StatusOK {
+			fmt.Fprintf(stderr, "%s: error %d: %s\n", result.Username, result.Code, result.Error)
+			code = ExitError
+			continue
+		}
+		printWallets(stdout, result.Username, result.Wallets)
+	}
+	return code
+}
+
+// importAccounts runs the import action. Every rejected row is printed and
+// makes the command fail.
+func importAccounts(ctx context.Context, client *api.Client, args []string, stdout io.Writer, stderr io.Writer) int {
+	var fs = newFlagSet("account import", "account [flags] import [--format csv|ndjson] [--dry-run] <file>", stderr)
+	var format = fs.String("format", "", "input `format`, csv or ndjson (default from the file extension)")
+	var dryRun = fs.Bool("dry-run", false, "validate the rows without writing them")
+	if code, ok := parseFlags(fs, args, stdout); !ok {
+		return code
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(stderr, "account import: expected exactly one file")
+		fs.Usage()
+		return ExitUsage
+	}
+
+	var path = fs.Arg(0)
+	formatName, err := bulkFormat(*format, path)
+	if err != nil {
+		fmt.Fprintf(stderr, "account import: %v\n", err)
+		return ExitUsage
+	}
+
+	var input io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintf(stderr, "account import: %v\n", err)
+			return ExitError
+		}
+		defer file.Close()
+		input = file
+	}
+
+	// Imports of large files take longer than the client's default timeout.
+	client.HTTPClient.Timeout = 0
+	response, err := client.ImportAccounts(ctx, formatName, *dryRun, input)
+	if err != nil {
+		return reportClientError(stderr, err)
+	}
+
+	for _, rowErr := range response.Errors {
+		if rowErr.Username == "" {
+			fmt.Fprintf(stderr, "line %d: %s\n", rowErr.Line, rowErr.Error)
+		} else {
+			fmt.Fprintf(stderr, "line %d (%s): %s\n", rowErr.Line, rowErr.Username, rowErr.Error)
+		}
+	}
+	if response.ErrorsTruncated {
+		fmt.Fprintf(stderr, "... %d more rejected rows not listed\n", response.Failed-len(response.Errors))
+	}
+
+	if response.DryRun {
+		fmt.Fprintf(stdout, "dry run: %d rows, %d valid, %d rejected\n", response.Rows, response.Imported, response.Failed)
+	} else {
+		fmt.Fprintf(stdout, "imported %d of %d rows, %d rejected\n", response.Imported, response.Rows, response.Failed)
+	}
+	if response.Failed > 0 {
+		return ExitError
+	}
+	return ExitOK
+}
+
+func exportAccounts(ctx context.Context, client *api.Client, args []string, stdout io.Writer, stderr io.Writer) int {
+	var fs = newFlagSet("account export", "account [flags] export [--format csv|ndjson] [file]", stderr)
+	var format = fs.String("format", "", "output `format`, csv or ndjson (default from the file extension, or csv)")
+	if code, ok := parseFlags(fs, args, stdout); !ok {
+		return code
+	}
+	if fs.NArg() > 1 {
+		fmt.Fprintln(stderr, "account export: expected at most one file")
+		fs.Usage()
+		return ExitUsage
+	}
+
+	var path = fs.Arg(0)
+	if *format == "" && (path == "" || path == "-") {
+		*format = bulk.FormatCSV
+	}
+	formatName, err := bulkFormat(*format, path)
+	if err != nil {
+		fmt.Fprintf(stderr, "account export: %v\n", err)
+		return ExitUsage
+	}
+
+	client.HTTPClient.Timeout = 0
+	if path == "" || path == "-" {
+		err = client.ExportAccounts(ctx, formatName, stdout)
+	} else {
+		err = writeFile(path, func(w io.Writer) error {
+			return client.ExportAccounts(ctx, formatName, w)
+		})
+	}
+	if err != nil {
+		return reportClientError(stderr, err)
+	}
+	return ExitOK
+}
+
+func downloadStatement(ctx context.Context, client *api.Client, args []string, stdout io.Writer, stderr io.Writer) int {
+	var fs = newFlagSet("account statement", "account [flags] statement [--format html|text|json] <period> [file]", stderr)
+	var format = fs.String("format", statement.FormatText, "statement `format`, html, text or json")
+	if code, ok := parseFlags(fs, args, stdout); !ok {
+		return code
+	}
+	if fs.NArg() != 1 && fs.NArg() != 2 {
+		fmt.Fprintln(stderr, "account statement: expected a period and optionally a file")
+		fs.Usage()
+		return ExitUsage
+	}
+	formatName, err := statement.ParseFormat(*format)
+	if err != nil {
+		fmt.Fprintf(stderr, "account statement: %v\n", err)
+		return ExitUsage
+	}
+
+	if path := fs.Arg(1); path == "" || path == "-" {
+		err = client.DownloadStatement(ctx, fs.Arg(0), formatName, stdout)
+	} else {
+		err = writeFile(path, func(w io.Writer) error {
+			return client.DownloadStatement(ctx, fs.Arg(0), formatName, w)
+		})
+	}
+	if err != nil {
+		return reportClientError(stderr, err)
+	}
+	return ExitOK
+}
+
+// bulkFormat returns format if set, or the format implied by path.
+func bulkFormat(format string, path string) (string, error) {
+	if format != "" {
+		return bulk.ParseFormat(format)
+	}
+	if filepath.Ext(path) == "" {
+		return "", fmt.Errorf("cannot tell the format of %q, use --format", path)
+	}
+	return bulk.FormatFromPath(path)
+}
+
+// writeFile writes to a temporary file next to path and renames it to path
+// once write succeeds, so that a failed download leaves no partial file
+// behind and never truncates an existing one.
+func writeFile(path string, write func(io.Writer) error) error {
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	err = write(temp)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), path)
+}
+
+func reportClientError(stderr io.Writer, err error) int {
+	return reportCommandError("account", stderr, err)
+}
+
+// reportCommandError prints a failed API call of command and returns
+// ExitError.
+func reportCommandError(command string, stderr io.Writer, err error) int {
+	var apiErr api.Error
+	if errors.As(err, &apiErr) {
+		fmt.Fprintf(stderr, "%s: server returned %d: %s\n", command, apiErr.Code, apiErr.Message)
+		return ExitError
+	}
+	fmt.Fprintf(stderr, "%s: %v\n", command, err)
+	return ExitError
+}
+
+// bindClientFlags adds the flags of commands that talk to a running server.
+func bindClientFlags(fs *flag.FlagSet, cf *configFlags) {
+	cf.bind(fs, "server", "client.server", "base `URL` of the running server")
+	cf.bind(fs, "tenant", "client.tenant", "`tenant` the account belongs to")
+	cf.bind(fs, "username", "client.username", "account `username`")
+	cf.bind(fs, "token", "client.token", "account auth `token`")
+	cf.bind(fs, "ca-file", "client.ca_file", "PEM `file` of extra CA certificates to trust, e.g. a dev certificate")
+}
+
+// newClient builds an API client for command from the client settings. ok
+// is false when the caller should return code straight away.
+func newClient(command string, cfg config.Client, stderr io.Writer) (client *api.Client, code int, ok bool) {
+	if cfg.Username == "" || cfg.Token == "" {
+		fmt.Fprintf(stderr, "%s: --username and --token are required\n", command)
+		return nil, ExitUsage, false
+	}
+
+	client = api.NewClient(cfg.Server, cfg.Username, cfg.Token)
+	client.Tenant = cfg.Tenant
+	if cfg.CAFile != "" {
+		transport, err := caTransport(cfg.CAFile)
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", command, err)
+			return nil, ExitError, false
+		}
+		client.HTTPClient.Transport = transport
+	}
+	return client, ExitOK, true
+}
+
+// caTransport returns a transport that trusts the system roots plus the
+// certificates in caFile.
+func caTransport(caFile string) (*http.Transport, error) {
+	pemData, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pemData) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+
+	var transport = http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	return transport, nil
+}
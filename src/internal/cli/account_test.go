@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"bytes"
+	"golearn/src/apitest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAccountDownloads(t *testing.T) {
+	var srv = apitest.New(t, apitest.Options{})
+	var admin = srv.CreateAdmin(apitest.User{Username: "root"})
+	var user = srv.CreateUser(apitest.User{Username: "alice"})
+
+	var tests = []struct {
+		name     string
+		user     apitest.User
+		args     []string
+		wantCode int
+		// What the file should hold afterwards, or "" to check only that
+		// it was written.
+		want string
+	}{
+		{name: "export", user: admin, args: []string{"export"}, wantCode: ExitOK},
+		{name: "export refused", user: user, args: []string{"export"}, wantCode: ExitError, want: "previous"},
+		{name: "statement", user: user, args: []string{"statement", "2025-12"}, wantCode: ExitOK},
+		{name: "future statement", user: user, args: []string{"statement", "2099-01"}, wantCode: ExitError, want: "previous"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var dir = t.TempDir()
+			var path = filepath.Join(dir, "out.csv")
+			if err := os.WriteFile(path, []byte("previous"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			var stdout, stderr bytes.Buffer
+			var args = append([]string{"account", "--server", srv.URL, "--username", test.user.Username, "--token", test.user.Token},
+				append(test.args, path)...)
+			if code := Run(args, &stdout, &stderr); code != test.wantCode {
+				t.Fatalf("exit code %d, want %d: %s", code, test.wantCode, stderr.String())
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.want != "" && string(data) != test.want {
+				t.Errorf("file = %q, want %q", data, test.want)
+			}
+			if test.want == "" && (len(data) == 0 || strings.HasPrefix(string(data), "previous")) {
+				t.Errorf("file = %q, want the download", data)
+			}
+			if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+				t.Errorf("left %d files behind, want only the output", len(entries))
+			}
+		})
+	}
+}
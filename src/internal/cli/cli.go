@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+)
+
+// Exit codes returned by Run.
+const (
+	ExitOK    = 0
+	ExitError = 1
+	ExitUsage = 2
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string, stdout io.Writer, stderr io.Writer) int
+}
+
+var commands = []command{
+	{name: "serve", summary: "Start the points API server", run: runServe},
+	{name: "demo", summary: "Run one or more Go feature demonstrations", run: runDemo},
+	{name: "account", summary: "Query or change an account on a running server", run: runAccount},
+	{name: "migrate", summary: "Show or apply schema migrations of the data file", run: runMigrate},
+	{name: "snapshot", summary: "List, create or restore snapshots of a running server's store", run: runSnapshot},
+	{name: "jobs", summary: "List, run or pause the background jobs of a running server", run: runJobs},
+	{name: "statements", summary: "Write the monthly statements of every account to files", run: runStatements},
+}
+
+// Run executes the command named by args[0] and returns the process exit code.
+func Run(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return ExitUsage
+	}
+
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		usage(stdout)
+		return ExitOK
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return cmd.run(args[1:], stdout, stderr)
+		}
+	}
+
+	fmt.Fprintf(stderr, "golearn: unknown command %q\n\n", args[0])
+	usage(stderr)
+	return ExitUsage
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: golearn <command> [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'golearn <command> --help' for details on a command.")
+}
+
+// newFlagSet returns a FlagSet that reports errors instead of exiting and
+// prints usageLine followed by the flag defaults.
+func newFlagSet(name string, usageLine string, stderr io.Writer) *flag.FlagSet {
+	var fs = flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: golearn %s\n", usageLine)
+		var hasFlags bool
+		fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+		if hasFlags {
+			fmt.Fprintln(fs.Output())
+			fmt.Fprintln(fs.Output(), "Flags:")
+			fs.PrintDefaults()
+		}
+	}
+	return fs
+}
+
+// parseFlags parses args and maps the outcome to an exit code. Help goes to
+// stdout, parse errors to the flag set's output. ok is false when the caller
+// should return code straight away.
+func parseFlags(fs *flag.FlagSet, args []string, stdout io.Writer) (code int, ok bool) {
+	var printUsage = fs.Usage
+	fs.Usage = func() {}
+	var err = fs.Parse(args)
+	fs.Usage = printUsage
+
+	if errors.Is(err, flag.ErrHelp) {
+		var output = fs.Output()
+		fs.SetOutput(stdout)
+		fs.Usage()
+		fs.SetOutput(output)
+		return ExitOK, false
+	}
+	if err != nil {
+		fs.Usage()
+		return ExitUsage, false
+	}
+	return ExitOK, true
+}
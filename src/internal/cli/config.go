@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"golearn/src/internal/config"
+	"io"
+	"os"
+)
+
+// configFlags adds --config and --print-config to a command and collects
+// the flags that override individual settings.
+type configFlags struct {
+	path      *string
+	print     *bool
+	overrides config.Overrides
+}
+
+func newConfigFlags(fs *flag.FlagSet) *configFlags {
+	var c = &configFlags{}
+	c.path = fs.String("config", os.Getenv(config.EnvPrefix+"CONFIG"), "path to a JSON config file (env "+config.EnvPrefix+"CONFIG)")
+	c.print = fs.Bool("print-config", false, "print the effective configuration with secrets redacted and exit")
+	return c
+}
+
+func (c *configFlags) bind(fs *flag.FlagSet, name string, key string, usage string) {
+	c.overrides.Bind(fs, name, key, usage)
+}
+
+// load returns the effective configuration. ok is false when the caller
+// should return code straight away.
+func (c *configFlags) load(stdout io.Writer, stderr io.Writer) (cfg *config.Config, code int, ok bool) {
+	cfg, err := config.Load(*c.path, c.overrides)
+	if err != nil {
+		fmt.Fprintf(stderr, "golearn: invalid configuration:\n%v\n", err)
+		return nil, ExitUsage, false
+	}
+
+	if *c.print {
+		fmt.Fprintln(stdout, cfg)
+		return nil, ExitOK, false
+	}
+
+	return cfg, ExitOK, true
+}
@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"fmt"
+	"golearn/src/features"
+	"io"
+)
+
+type demo struct {
+	name    string
+	summary string
+	run     func()
+}
+
+var demos = []demo{
+	{name: "variables", summary: "Variable declarations and basic types", run: features.DemonstrateVariables},
+	{name: "functions", summary: "Functions, error handling and switch statements", run: demonstrateFunctions},
+	{name: "arrays", summary: "Arrays", run: features.DemonstrateArrays},
+	{name: "pointers", summary: "Pointers", run: features.DemonstratePointers},
+	{name: "maps", summary: "Maps", run: features.DemonstrateMaps},
+	{name: "perf", summary: "Slice preallocation performance", run: features.DemonstrateSlicePerformance},
+	{name: "strings", summary: "Strings, runes and builders", run: features.DemonstrateStrings},
+	{name: "types", summary: "Structs, interfaces and methods", run: features.DemonstrateTypes},
+	{name: "slices", summary: "Slices", run: features.DemonstrateSlices},
+	{name: "goroutines", summary: "Goroutines, WaitGroups and mutexes", run: features.DemonstrateGoroutines},
+	{name: "channels", summary: "Channels and select", run: features.DemonstrateChannels},
+	{name: "generics", summary: "Generics and embedded JSON files", run: features.DemonstrateGenerics},
+}
+
+// demonstrateFunctions chains the demos that share the result of the division
+// performed by features.DemonstrateFunctions.
+func demonstrateFunctions() {
+	var result, remainder, err = features.DemonstrateFunctions()
+
+	features.DemonstrateErrorHandling(err, remainder, result)
+
+	features.DemonstrateSwitchStatements(result, remainder)
+}
+
+func runDemo(args []string, stdout io.Writer, stderr io.Writer) int {
+	var fs = newFlagSet("demo", "demo [flags] [name...]\n\nRuns the named demonstrations in order, or all of them when no name is given.", stderr)
+	var list = fs.Bool("list", false, "list the available demonstrations and exit")
+
+	if code, ok := parseFlags(fs, args, stdout); !ok {
+		return code
+	}
+
+	if *list {
+		for _, d := range demos {
+			fmt.Fprintf(stdout, "%-12s %s\n", d.name, d.summary)
+		}
+		return ExitOK
+	}
+
+	var selected = demos
+	if fs.NArg() > 0 {
+		selected = make([]demo, 0, fs.NArg())
+		for _, name := range fs.Args() {
+			d, ok := findDemo(name)
+			if !ok {
+				fmt.Fprintf(stderr, "demo: unknown demonstration %q (see 'golearn demo --list')\n", name)
+				return ExitUsage
+			}
+			selected = append(selected, d)
+		}
+	}
+
+	for _, d := range selected {
+		d.run()
+	}
+
+	return ExitOK
+}
+
+func findDemo(name string) (demo, bool) {
+	for _, d := range demos {
+		if d.name == name {
+			return d, true
+		}
+	}
+	return demo{}, false
+}
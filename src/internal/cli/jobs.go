@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"golearn/src/api"
+	"io"
+	"text/tabwriter"
+)
+
+const jobsUsage = `jobs [flags] <action> [arguments]
+
+Actions:
+  list             List the server's background jobs
+  show <job>       Print a job and its recent runs, newest first
+  run <job>        Start a job now, even if it is paused
+  pause <job>      Stop the scheduled runs of a job
+  resume <job>     Schedule a paused job again
+
+The account must be an admin of the server's scheduler.operator_tenant.`
+
+func runJobs(args []string, stdout io.Writer, stderr io.Writer) int {
+	var fs = newFlagSet("jobs", jobsUsage, stderr)
+	var cf = newConfigFlags(fs)
+	bindClientFlags(fs, cf)
+
+	if code, ok := parseFlags(fs, args, stdout); !ok {
+		return code
+	}
+
+	cfg, code, ok := cf.load(stdout, stderr)
+	if !ok {
+		return code
+	}
+
+	if fs.NArg() == 0 {
+		fmt.Fprintln(stderr, "jobs: missing action")
+		fs.Usage()
+		return ExitUsage
+	}
+	client, code, ok := newClient("jobs", cfg.Client, stderr)
+	if !ok {
+		return code
+	}
+	var ctx = context.Background()
+
+	var action = fs.Arg(0)
+	if action == "list" {
+		if fs.NArg() != 1 {
+			fmt.Fprintln(stderr, "jobs list: takes no arguments")
+			return ExitUsage
+		}
+		response, err := client.ListJobs(ctx)
+		if err != nil {
+			return reportCommandError("jobs", stderr, err)
+		}
+		printJobs(stdout, response.Jobs)
+		return ExitOK
+	}
+
+	var change func(context.Context, string) (*api.JobResponse, error)
+	switch action {
+	case "show":
+		change = client.GetJob
+	case "run":
+		change = client.RunJob
+	case "pause":
+		change = client.PauseJob
+	case "resume":
+		change = client.ResumeJob
+	default:
+		fmt.Fprintf(stderr, "jobs: unknown action %q\n", action)
+		fs.Usage()
+		return ExitUsage
+	}
+	if fs.NArg() != 2 {
+		fmt.Fprintf(stderr, "jobs %s: expected exactly one job name\n", action)
+		return ExitUsage
+	}
+	response, err := change(ctx, fs.Arg(1))
+	if err != nil {
+		return reportCommandError("jobs", stderr, err)
+	}
+	printJobs(stdout, []api.JobInfo{response.Job})
+	if action == "show" {
+		fmt.Fprintln(stdout)
+		printJobRuns(stdout, response.History)
+	}
+	return ExitOK
+}
+
+func printJobs(stdout io.Writer, jobs []api.JobInfo) {
+	var tw = tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSCHEDULE\tSTATE\tNEXT RUN\tLAST RUN")
+	for _, job := range jobs {
+		var state = "scheduled"
+		switch {
+		case job.Running:
+			state = "running"
+		case job.Paused:
+			state = "paused"
+		}
+		var last = "-"
+		if job.LastRun != nil {
+			last = job.LastRun.StartedAt + " " + job.LastRun.Outcome
+		}
+		var next = job.NextRun
+		if next == "" {
+			next = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", job.Name, job.Schedule, state, next, last)
+	}
+	tw.Flush()
+}
+
+func printJobRuns(stdout io.Writer, runs []api.JobRun) {
+	var tw = tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "STARTED\tTRIGGER\tOUTCOME\tDURATION\tERROR")
+	for _, run := range runs {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", run.StartedAt, run.Trigger, run.Outcome, run.Duration, run.Error)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"golearn/src/internal/migrate"
+	"golearn/src/internal/tools"
+	"io"
+	"io/fs"
+)
+
+const migrateUsage = `migrate [flags] <action>
+
+Actions:
+  status  Print the schema version of the data file and pending migrations
+  up      Apply every pending migration
+
+Run it while the server is stopped. Only the file database keeps data.`
+
+func runMigrate(args []string, stdout io.Writer, stderr io.Writer) int {
+	var fs = newFlagSet("migrate", migrateUsage, stderr)
+	var cf = newConfigFlags(fs)
+	cf.bind(fs, "database", "database.driver", "store `driver`, mock or file")
+	cf.bind(fs, "database-file", "database.file", "JSON data `file` of the file database")
+
+	if code, ok := parseFlags(fs, args, stdout); !ok {
+		return code
+	}
+
+	cfg, code, ok := cf.load(stdout, stderr)
+	if !ok {
+		return code
+	}
+
+	if fs.NArg() != 1 {
+		fmt.Fprintln(stderr, "migrate: expected exactly one action")
+		fs.Usage()
+		return ExitUsage
+	}
+	if cfg.Database.Driver != "file" {
+		fmt.Fprintf(stderr, "migrate: the %s database keeps no data to migrate\n", cfg.Database.Driver)
+		return ExitUsage
+	}
+	var path = cfg.Database.File
+
+	switch action := fs.Arg(0); action {
+	case "status":
+		version, pending, err := tools.FileSchemaStatus(path)
+		if err != nil {
+			return reportMigrateError(stderr, path, err)
+		}
+		fmt.Fprintf(stdout, "%s: schema version %d, binary supports %d\n", path, version, tools.FileMigrations.Latest())
+		for _, m := range pending {
+			fmt.Fprintf(stdout, "pending %d: %s\n", m.Version, m.Name)
+		}
+	case "up":
+		from, to, err := tools.MigrateFile(path, func(m migrate.Migration) {
+			fmt.Fprintf(stdout, "applied %d: %s\n", m.Version, m.Name)
+		})
+		if err != nil {
+			return reportMigrateError(stderr, path, err)
+		}
+		if from == to {
+			fmt.Fprintf(stdout, "%s: already at schema version %d\n", path, to)
+		} else {
+			fmt.Fprintf(stdout, "%s: migrated from schema version %d to %d, original kept as %s.v%d.bak\n", path, from, to, path, from)
+		}
+	default:
+		fmt.Fprintf(stderr, "migrate: unknown action %q\n", action)
+		fs.Usage()
+		return ExitUsage
+	}
+	return ExitOK
+}
+
+func reportMigrateError(stderr io.Writer, path string, err error) int {
+	if errors.Is(err, fs.ErrNotExist) {
+		fmt.Fprintf(stderr, "migrate: %s does not exist; serve creates it at the current schema\n", path)
+		return ExitError
+	}
+	fmt.Fprintf(stderr, "migrate: %v\n", err)
+	return ExitError
+}
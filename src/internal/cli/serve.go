@@ -0,0 +1,272 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"golearn/src/internal/audit"
+	"golearn/src/internal/config"
+	"golearn/src/internal/handlers"
+	"golearn/src/internal/health"
+	"golearn/src/internal/jobs"
+	"golearn/src/internal/metrics"
+	"golearn/src/internal/scheduler"
+	"golearn/src/internal/server"
+	"golearn/src/internal/snapshot"
+	"golearn/src/internal/tenant"
+	"golearn/src/internal/tools"
+	"golearn/src/internal/tracing"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/go-chi/chi"
+	log "github.com/sirupsen/logrus"
+)
+
+func runServe(args []string, stdout io.Writer, stderr io.Writer) int {
+	var fs = newFlagSet("serve", "serve [flags]", stderr)
+	var cf = newConfigFlags(fs)
+	cf.bind(fs, "addr", "server.addr", "`host:port` to listen on")
+	cf.bind(fs, "shutdown-delay", "server.shutdown_delay", "how long to keep serving with a failing /readyz before draining (`duration`)")
+	cf.bind(fs, "shutdown-timeout", "server.shutdown_timeout", "how long to drain in-flight requests on shutdown (`duration`)")
+	cf.bind(fs, "tls", "tls.enabled", "serve HTTPS instead of plain HTTP")
+	cf.bind(fs, "tls-cert", "tls.cert_file", "PEM certificate `file` for HTTPS")
+	cf.bind(fs, "tls-key", "tls.key_file", "PEM private key `file` for HTTPS")
+	cf.bind(fs, "tls-dev", "tls.dev", "generate and cache a self-signed certificate when no certificate is given")
+	cf.bind(fs, "redirect-addr", "tls.redirect_addr", "optional `host:port` for a plain HTTP listener that redirects to HTTPS")
+	cf.bind(fs, "tenant-header", "tenancy.header", "request `header` naming the tenant")
+	cf.bind(fs, "tenant-host-suffix", "tenancy.host_suffix", "`domain` whose subdomains name tenants")
+	cf.bind(fs, "default-tenant", "tenancy.default_tenant", "`tenant` of requests that name none (empty to require one)")
+	cf.bind(fs, "snapshot-dir", "snapshot.dir", "`directory` holding store snapshots")
+	cf.bind(fs, "snapshot-operator", "snapshot.operator_tenant", "`tenant` whose admins may create and restore snapshots")
+	cf.bind(fs, "batch-workers", "api.batch_workers", "maximum parallel lookups per batch balance `request`")
+	cf.bind(fs, "audit-file", "audit.file", "`file` to append the hash-chained audit log to")
+	cf.bind(fs, "metrics", "metrics.enabled", "serve Prometheus metrics on /metrics")
+	cf.bind(fs, "tracing", "tracing.enabled", "record request and store spans")
+	cf.bind(fs, "trace-file", "tracing.file", "`file` to append JSON-lines spans to (default stderr)")
+	cf.bind(fs, "coalesce", "database.coalesce", "merge concurrent identical store lookups")
+	cf.bind(fs, "cache", "cache.enabled", "cache store lookups in memory")
+	cf.bind(fs, "hold-ttl", "holds.default_ttl", "how long a balance hold lasts unless the request says otherwise (`duration`)")
+	cf.bind(fs, "scheduler", "scheduler.enabled", "run background jobs such as deleting expired holds")
+	cf.bind(fs, "jobs-operator", "scheduler.operator_tenant", "`tenant` whose admins may manage background jobs")
+	cf.bind(fs, "dashboard", "dashboard.enabled", "serve the admin web UI under /admin")
+	cf.bind(fs, "database", "database.driver", "store `driver`, mock or file")
+	cf.bind(fs, "database-file", "database.file", "JSON data `file` of the file database")
+	cf.bind(fs, "migrate", "database.auto_migrate", "migrate the data file to the current schema at startup")
+	cf.bind(fs, "mock-latency", "database.mock_latency", "`duration` added to every mock database call")
+	cf.bind(fs, "mock-scenario", "database.mock_scenario", "scenario `file` of mock database latency and failures")
+	cf.bind(fs, "mock-debug", "database.mock_debug", "serve the unauthenticated /debug/mock endpoint to change the mock scenario")
+	cf.bind(fs, "fixtures", "database.fixtures", "accounts `file` to seed the mock database or a new data file")
+	cf.bind(fs, "log-level", "log.level", "minimum `level` of log lines to write")
+	cf.bind(fs, "log-format", "log.format", "log line `format`, text or json")
+	cf.bind(fs, "access-log", "log.access", "write one log line per request")
+	cf.bind(fs, "report-caller", "log.report_caller", "include the calling function in log lines")
+
+	if code, ok := parseFlags(fs, args, stdout); !ok {
+		return code
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(stderr, "serve: unexpected argument %q\n", fs.Arg(0))
+		fs.Usage()
+		return ExitUsage
+	}
+
+	cfg, code, ok := cf.load(stdout, stderr)
+	if !ok {
+		return code
+	}
+
+	configureLogging(cfg.Log)
+	defer flushLogs()
+	log.Infof("effective configuration:\n%s", cfg)
+
+	resolver, err := tenant.NewResolver(cfg.Tenancy)
+	if err != nil {
+		log.Error(err)
+		return ExitError
+	}
+
+	var database *tools.DatabaseInterface
+	database, err = tools.NewDatabase(cfg.Database)
+	if err != nil {
+		log.Error(err)
+		return ExitError
+	}
+
+	var store tools.DatabaseInterface = *database
+	if cfg.Tracing.Enabled {
+		exporter, err := newSpanExporter(cfg.Tracing)
+		if err != nil {
+			log.Error(err)
+			return ExitError
+		}
+		defer shutdownSpanExporter(exporter, cfg.Server.ShutdownTimeout.Duration)
+		tracing.SetDefault(tracing.NewTracer(cfg.Tracing.ServiceName, exporter, cfg.Tracing.SampleRatio))
+		store = tracing.TraceDatabase(store)
+	}
+	if cfg.Metrics.Enabled {
+		store = metrics.InstrumentDatabase(store)
+	}
+	if cfg.Database.Coalesce {
+		var coalesced = tools.NewCoalescedDatabase(store)
+		if cfg.Metrics.Enabled {
+			metrics.RegisterCoalescing(coalesced)
+		}
+		store = coalesced
+	}
+	if cfg.Cache.Enabled {
+		var cache = tools.NewCachedDatabase(store, cfg.Cache)
+		if cfg.Metrics.Enabled {
+			metrics.RegisterCache(cache)
+		}
+		store = cache
+	}
+
+	var auditLog *audit.Log
+	if cfg.Audit.Enabled {
+		auditLog, err = audit.Open(cfg.Audit)
+		if err != nil {
+			log.Error(err)
+			return ExitError
+		}
+		defer closeAuditLog(auditLog)
+	}
+
+	var mockControl tools.MockController
+	if cfg.Database.MockDebug {
+		control, ok := (*database).(tools.MockController)
+		if !ok {
+			log.Errorf("database.mock_debug needs the mock driver, not %s", cfg.Database.Driver)
+			return ExitError
+		}
+		log.Warn("serving /debug/mock: anyone can change the mock database scenario")
+		mockControl = control
+	}
+
+	var jobScheduler *scheduler.Scheduler
+	if cfg.Scheduler.Enabled {
+		jobScheduler = scheduler.New(cfg.Scheduler.History)
+		if err := jobs.Register(jobScheduler, cfg.Scheduler, store); err != nil {
+			log.Error(err)
+			return ExitError
+		}
+	}
+
+	var checker = health.NewChecker(cfg.Health.Timeout.Duration)
+	checker.Register("database", store.Ping)
+
+	var router *chi.Mux = chi.NewRouter()
+	handlers.Handler(router, handlers.Dependencies{
+		Database:  store,
+		Tenants:   resolver,
+		Snapshots: snapshot.NewStore(cfg.Snapshot.Dir),
+		Snapshot:  cfg.Snapshot,
+		Jobs:      jobScheduler,
+		Scheduler: cfg.Scheduler,
+		Audit:     auditLog,
+		Health:    checker,
+		API:       cfg.API,
+		Holds:     cfg.Holds,
+		Dashboard: cfg.Dashboard,
+		Metrics:   cfg.Metrics.Enabled,
+		AccessLog: cfg.Log.Access,
+		Tracing:   cfg.Tracing.Enabled,
+
+		MockControl: mockControl,
+	})
+
+	// The first SIGINT or SIGTERM starts a graceful shutdown; stop restores
+	// the default behaviour so a second signal terminates immediately.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	// Jobs stop with the server and are waited for before the store closes.
+	jobsCtx, stopJobs := context.WithCancel(ctx)
+	var jobsDone = make(chan struct{})
+	go func() {
+		defer close(jobsDone)
+		if jobScheduler != nil {
+			jobScheduler.Run(jobsCtx)
+		}
+	}()
+
+	var srvErr error
+	srv, srvErr := server.New(cfg.Server, cfg.TLS, router)
+	if srvErr == nil {
+		srv.OnStarted(checker.MarkReady)
+		srv.OnShutdown(checker.MarkShuttingDown)
+		srvErr = srv.Run(ctx)
+	}
+	stopJobs()
+	<-jobsDone
+
+	if closeErr := store.Close(); closeErr != nil {
+		log.Errorf("closing database: %v", closeErr)
+		if srvErr == nil {
+			srvErr = closeErr
+		}
+	}
+
+	if srvErr != nil {
+		log.Error(srvErr)
+		return ExitError
+	}
+
+	log.Info("server stopped")
+	return ExitOK
+}
+
+// configureLogging applies the log settings to the standard logrus logger.
+// The level has already been checked by config.Validate.
+func configureLogging(cfg config.Log) {
+	level, _ := log.ParseLevel(cfg.Level)
+	log.SetLevel(level)
+	log.SetReportCaller(cfg.ReportCaller)
+
+	if cfg.Format == "json" {
+		log.SetFormatter(&log.JSONFormatter{})
+	} else {
+		log.SetFormatter(&log.TextFormatter{})
+	}
+}
+
+// flushLogs syncs the log output to stable storage when it supports it.
+func flushLogs() {
+	if syncer, ok := log.StandardLogger().Out.(interface{ Sync() error }); ok {
+		syncer.Sync()
+	}
+}
+
+func newSpanExporter(cfg config.Tracing) (tracing.Exporter, error) {
+	if cfg.Exporter == "collector" {
+		return tracing.NewCollectorExporter(cfg.Endpoint, 256, 5*time.Second), nil
+	}
+	if cfg.File == "" {
+		return tracing.NewJSONLinesExporter(os.Stderr), nil
+	}
+	file, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("opening trace file: %w", err)
+	}
+	return tracing.NewJSONLinesExporter(file), nil
+}
+
+func shutdownSpanExporter(exporter tracing.Exporter, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := exporter.Shutdown(ctx); err != nil {
+		log.Errorf("flushing spans: %v", err)
+	}
+}
+
+func closeAuditLog(auditLog *audit.Log) {
+	if err := auditLog.Close(); err != nil {
+		log.Errorf("closing audit log: %v", err)
+	}
+}
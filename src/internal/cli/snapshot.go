@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"golearn/src/api"
+	"io"
+	"text/tabwriter"
+)
+
+const snapshotUsage = `snapshot [flags] <action> [arguments]
+
+Actions:
+  list              List the server's snapshots, newest first
+  create [label]    Snapshot every tenant's accounts
+  restore <name>    Replace all accounts with a snapshot, after validating it
+
+The account must be an admin of the server's snapshot.operator_tenant.`
+
+func runSnapshot(args []string, stdout io.Writer, stderr io.Writer) int {
+	var fs = newFlagSet("snapshot", snapshotUsage, stderr)
+	var cf = newConfigFlags(fs)
+	bindClientFlags(fs, cf)
+
+	if code, ok := parseFlags(fs, args, stdout); !ok {
+		return code
+	}
+
+	cfg, code, ok := cf.load(stdout, stderr)
+	if !ok {
+		return code
+	}
+
+	if fs.NArg() == 0 {
+		fmt.Fprintln(stderr, "snapshot: missing action")
+		fs.Usage()
+		return ExitUsage
+	}
+	client, code, ok := newClient("snapshot", cfg.Client, stderr)
+	if !ok {
+		return code
+	}
+	var ctx = context.Background()
+
+	switch action := fs.Arg(0); action {
+	case "list":
+		if fs.NArg() != 1 {
+			fmt.Fprintln(stderr, "snapshot list: takes no arguments")
+			return ExitUsage
+		}
+		response, err := client.ListSnapshots(ctx)
+		if err != nil {
+			return reportCommandError("snapshot", stderr, err)
+		}
+		printSnapshots(stdout, response.Snapshots)
+	case "create":
+		if fs.NArg() > 2 {
+			fmt.Fprintln(stderr, "snapshot create: expected at most one label")
+			return ExitUsage
+		}
+		response, err := client.CreateSnapshot(ctx, fs.Arg(1))
+		if err != nil {
+			return reportCommandError("snapshot", stderr, err)
+		}
+		fmt.Fprintf(stdout, "created %s: %d accounts in %d tenants\n",
+			response.Snapshot.Name, response.Snapshot.Accounts, response.Snapshot.Tenants)
+	case "restore":
+		if fs.NArg() != 2 {
+			fmt.Fprintln(stderr, "snapshot restore: expected exactly one snapshot name")
+			return ExitUsage
+		}
+		response, err := client.RestoreSnapshot(ctx, fs.Arg(1))
+		if err != nil {
+			return reportCommandError("snapshot", stderr, err)
+		}
+		fmt.Fprintf(stdout, "restored %s: %d accounts in %d tenants\n",
+			response.Restored.Name, response.Restored.Accounts, response.Restored.Tenants)
+		fmt.Fprintf(stdout, "previous data saved as %s\n", response.Backup.Name)
+	default:
+		fmt.Fprintf(stderr, "snapshot: unknown action %q\n", action)
+		fs.Usage()
+		return ExitUsage
+	}
+	return ExitOK
+}
+
+func printSnapshots(stdout io.Writer, snapshots []api.SnapshotInfo) {
+	var tw = tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tCREATED\tTENANTS\tACCOUNTS\tBYTES\tVERSION")
+	for _, info := range snapshots {
+		if info.Error != "" {
+			fmt.Fprintf(tw, "%s\tunreadable: %s\t\t\t%d\t\n", info.Name, info.Error, info.SizeBytes)
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%d\n", info.Name, info.CreatedAt, info.Tenants, info.Accounts, info.SizeBytes, info.Version)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"golearn/src/internal/statement"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const statementsUsage = `statements [flags] [period]
+
+Write the statement of every account of the tenant for a month, written as
+YYYY-MM, one file per account. The period defaults to the previous month.
+The account must have the admin role.`
+
+func runStatements(args []string, stdout io.Writer, stderr io.Writer) int {
+	var fs = newFlagSet("statements", statementsUsage, stderr)
+	var cf = newConfigFlags(fs)
+	bindClientFlags(fs, cf)
+	var format = fs.String("format", statement.FormatHTML, "statement `format`, html, text or json")
+	var dir = fs.String("dir", ".", "`directory` to write the statements to")
+
+	if code, ok := parseFlags(fs, args, stdout); !ok {
+		return code
+	}
+
+	cfg, code, ok := cf.load(stdout, stderr)
+	if !ok {
+		return code
+	}
+
+	if fs.NArg() > 1 {
+		fmt.Fprintln(stderr, "statements: expected at most one period")
+		fs.Usage()
+		return ExitUsage
+	}
+	var period = statement.PeriodOf(time.Now()).Previous()
+	if fs.NArg() == 1 {
+		var err error
+		if period, err = statement.ParsePeriod(fs.Arg(0)); err != nil {
+			fmt.Fprintf(stderr, "statements: %v\n", err)
+			return ExitUsage
+		}
+	}
+	formatName, err := statement.ParseFormat(*format)
+	if err != nil {
+		fmt.Fprintf(stderr, "statements: %v\n", err)
+		return ExitUsage
+	}
+
+	client, code, ok := newClient("statements", cfg.Client, stderr)
+	if !ok {
+		return code
+	}
+	client.HTTPClient.Timeout = 0
+	response, err := client.ListStatements(context.Background(), period.String())
+	if err != nil {
+		return reportCommandError("statements", stderr, err)
+	}
+
+	if err = os.MkdirAll(*dir, 0o755); err != nil {
+		fmt.Fprintf(stderr, "statements: %v\n", err)
+		return ExitError
+	}
+	for _, result := range response.Statements {
+		var path = filepath.Join(*dir, statement.Filename(result, formatName))
+		err := writeFile(path, func(w io.Writer) error {
+			return statement.Render(w, formatName, result)
+		})
+		if err != nil {
+			fmt.Fprintf(stderr, "statements: %v\n", err)
+			return ExitError
+		}
+	}
+	fmt.Fprintf(stdout, "wrote %d statements for %s to %s\n", len(response.Statements), response.Period, *dir)
+	return ExitOK
+}
@@ -0,0 +1,492 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golearn/src/internal/scheduler"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// EnvPrefix is prepended to every environment variable the loader reads.
+const EnvPrefix = "GOLEARN_"
+
+// Config is the effective configuration of the binary. Values are layered:
+// defaults, then the JSON config file, then GOLEARN_* environment variables,
+// then command-line flags.
+type Config struct {
+	Server    Server    `json:"server"`
+	TLS       TLS       `json:"tls"`
+	API       API       `json:"api"`
+	Tenancy   Tenancy   `json:"tenancy"`
+	Health    Health    `json:"health"`
+	Metrics   Metrics   `json:"metrics"`
+	Tracing   Tracing   `json:"tracing"`
+	Audit     Audit     `json:"audit"`
+	Database  Database  `json:"database"`
+	Snapshot  Snapshot  `json:"snapshot"`
+	Cache     Cache     `json:"cache"`
+	Holds     Holds     `json:"holds"`
+	Scheduler Scheduler `json:"scheduler"`
+	Dashboard Dashboard `json:"dashboard"`
+	Log       Log       `json:"log"`
+	Client    Client    `json:"client"`
+}
+
+type Server struct {
+	Addr            string   `json:"addr"`
+	ReadTimeout     Duration `json:"read_timeout"`
+	WriteTimeout    Duration `json:"write_timeout"`
+	ShutdownTimeout Duration `json:"shutdown_timeout"`
+	ShutdownDelay   Duration `json:"shutdown_delay"`
+}
+
+// API holds limits for the HTTP API. BatchWorkers bounds how many store
+// lookups one batch request runs at once.
+type API struct {
+	BatchWorkers int `json:"batch_workers"`
+	BatchMaxSize int `json:"batch_max_size"`
+}
+
+// Tenancy decides which tenant a request belongs to: a host listed in Hosts
+// as "host=tenant", a subdomain of HostSuffix, the Header, and finally
+// DefaultTenant. An empty DefaultTenant makes the tenant mandatory.
+type Tenancy struct {
+	Header        string   `json:"header"`
+	HostSuffix    string   `json:"host_suffix"`
+	Hosts         []string `json:"hosts"`
+	DefaultTenant string   `json:"default_tenant"`
+}
+
+// Health configures the /readyz dependency checks.
+type Health struct {
+	Timeout Duration `json:"timeout"`
+}
+
+// TLS controls HTTPS serving. In dev mode a self-signed certificate is
+// generated and cached in DevCertDir when no certificate files are given.
+type TLS struct {
+	Enabled      bool   `json:"enabled"`
+	CertFile     string `json:"cert_file"`
+	KeyFile      string `json:"key_file"`
+	MinVersion   string `json:"min_version"`
+	CipherPolicy string `json:"cipher_policy"`
+	RedirectAddr string `json:"redirect_addr"`
+	Dev          bool   `json:"dev"`
+	DevCertDir   string `json:"dev_cert_dir"`
+}
+
+// Metrics controls the Prometheus /metrics endpoint and instrumentation.
+type Metrics struct {
+	Enabled bool `json:"enabled"`
+}
+
+// Tracing controls span recording. Spans are written as JSON lines to File
+// (stderr when empty) or posted in batches to a collector Endpoint.
+type Tracing struct {
+	Enabled     bool    `json:"enabled"`
+	Exporter    string  `json:"exporter"`
+	File        string  `json:"file"`
+	Endpoint    string  `json:"endpoint"`
+	ServiceName string  `json:"service_name"`
+	SampleRatio float64 `json:"sample_ratio"`
+}
+
+// Audit controls the security audit log. The newest MaxEntries entries are
+// kept in memory, all of them when it is 0, and every entry is appended to
+// File if set. A Key turns the chain hashes into HMACs so the log cannot be
+// rewritten without it; without one, anyone able to edit the file can
+// recompute the chain, so it only detects accidental damage.
+type Audit struct {
+	Enabled    bool   `json:"enabled"`
+	File       string `json:"file"`
+	Key        string `json:"key" secret:"true"`
+	MaxEntries int    `json:"max_entries"`
+}
+
+// Database selects the store. Coalesce merges concurrent identical lookups
+// into a single backend call. The mock driver keeps the Fixtures accounts
+// (built-in samples when empty) in memory and injects the latency and
+// failures of MockScenario, adjustable at /debug/mock with MockDebug. The
+// file driver persists accounts to File, seeded from Fixtures, and migrates
+// it to the current schema at startup when AutoMigrate is set.
+type Database struct {
+	Driver       string   `json:"driver"`
+	File         string   `json:"file"`
+	AutoMigrate  bool     `json:"auto_migrate"`
+	Fixtures     string   `json:"fixtures"`
+	MockLatency  Duration `json:"mock_latency"`
+	MockScenario string   `json:"mock_scenario"`
+	MockDebug    bool     `json:"mock_debug"`
+	Coalesce     bool     `json:"coalesce"`
+}
+
+// Snapshot configures point-in-time archives of the whole store. They span
+// every tenant, so only admins of OperatorTenant may create or restore them;
+// when it is empty the snapshot API is disabled.
+type Snapshot struct {
+	Dir            string `json:"dir"`
+	OperatorTenant string `json:"operator_tenant"`
+}
+
+// Cache configures the in-process cache in front of the database. MaxEntries
+// bounds the login and point caches separately.
+type Cache struct {
+	Enabled     bool     `json:"enabled"`
+	LoginTTL    Duration `json:"login_ttl"`
+	PointTTL    Duration `json:"point_ttl"`
+	NegativeTTL Duration `json:"negative_ttl"`
+	MaxEntries  int      `json:"max_entries"`
+}
+
+// Holds bounds how long balance holds last. DefaultTTL applies when a
+// request does not ask for one; no hold may last longer than MaxTTL.
+type Holds struct {
+	DefaultTTL Duration `json:"default_ttl"`
+	MaxTTL     Duration `json:"max_ttl"`
+}
+
+// Scheduler controls the background jobs of the server. Job schedules are
+// cron expressions or "@every <duration>"; an empty schedule turns the job
+// off. History is how many runs of each job are kept. Jobs span every
+// tenant, so only admins of OperatorTenant may manage them; when it is empty
+// the jobs API is disabled.
+type Scheduler struct {
+	Enabled        bool   `json:"enabled"`
+	History        int    `json:"history"`
+	HoldSweep      string `json:"hold_sweep"`
+	OperatorTenant string `json:"operator_tenant"`
+}
+
+// Dashboard controls the admin web UI served under /admin. Sessions end
+// after SessionTTL without a request.
+type Dashboard struct {
+	Enabled    bool     `json:"enabled"`
+	SessionTTL Duration `json:"session_ttl"`
+}
+
+type Log struct {
+	Level        string `json:"level"`
+	Format       string `json:"format"`
+	ReportCaller bool   `json:"report_caller"`
+	Access       bool   `json:"access"`
+}
+
+// Client holds the settings used by the account command to reach a server.
+type Client struct {
+	Server   string `json:"server"`
+	Tenant   string `json:"tenant"`
+	Username string `json:"username"`
+	Token    string `json:"token" secret:"true"`
+	CAFile   string `json:"ca_file"`
+}
+
+func Default() Config {
+	return Config{
+		Server: Server{
+			Addr:            "localhost:9276",
+			ReadTimeout:     Duration{10 * time.Second},
+			WriteTimeout:    Duration{30 * time.Second},
+			ShutdownTimeout: Duration{15 * time.Second},
+		},
+		API: API{
+			BatchWorkers: 8,
+			BatchMaxSize: 1000,
+		},
+		Tenancy: Tenancy{
+			Header:        "X-Tenant-ID",
+			Hosts:         []string{},
+			DefaultTenant: "default",
+		},
+		Health: Health{
+			Timeout: Duration{2 * time.Second},
+		},
+		TLS: TLS{
+			MinVersion:   "1.2",
+			CipherPolicy: "default",
+			DevCertDir:   defaultDevCertDir(),
+		},
+		Metrics: Metrics{
+			Enabled: true,
+		},
+		Tracing: Tracing{
+			Exporter:    "jsonl",
+			ServiceName: "golearn",
+			SampleRatio: 1,
+		},
+		Audit: Audit{
+			Enabled:    true,
+			MaxEntries: 100000,
+		},
+		Database: Database{
+			Driver:      "mock",
+			AutoMigrate: true,
+			MockLatency: Duration{time.Second},
+			Coalesce:    true,
+		},
+		Snapshot: Snapshot{
+			Dir: "snapshots",
+		},
+		Cache: Cache{
+			Enabled:     true,
+			LoginTTL:    Duration{5 * time.Minute},
+			PointTTL:    Duration{30 * time.Second},
+			NegativeTTL: Duration{10 * time.Second},
+			MaxEntries:  10000,
+		},
+		Holds: Holds{
+			DefaultTTL: Duration{15 * time.Minute},
+			MaxTTL:     Duration{7 * 24 * time.Hour},
+		},
+		Scheduler: Scheduler{
+			Enabled:   true,
+			History:   20,
+			HoldSweep: "@every 5m",
+		},
+		Dashboard: Dashboard{
+			Enabled:    true,
+			SessionTTL: Duration{30 * time.Minute},
+		},
+		Log: Log{
+			Level:        "info",
+			Format:       "text",
+			ReportCaller: true,
+			Access:       true,
+		},
+		Client: Client{
+			Server: "http://localhost:9276",
+		},
+	}
+}
+
+// Load builds the effective configuration from the defaults, the JSON file at
+// path (skipped when empty), the environment and finally overrides, which
+// normally come from command-line flags. The result is validated.
+func Load(path string, overrides Overrides) (*Config, error) {
+	var cfg = Default()
+
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, key := range Keys() {
+		var name = EnvName(key)
+		if value, ok := os.LookupEnv(name); ok {
+			if err := cfg.Set(key, value); err != nil {
+				return nil, fmt.Errorf("environment %s: %w", name, err)
+			}
+		}
+	}
+
+	for _, o := range overrides {
+		if err := cfg.Set(o.Key, o.Value); err != nil {
+			return nil, fmt.Errorf("flag for %s: %w", o.Key, err)
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	defer file.Close()
+
+	var decoder = json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(c); err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate reports every invalid setting at once.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if _, _, err := net.SplitHostPort(c.Server.Addr); err != nil {
+		errs = append(errs, fmt.Errorf("server.addr: %w", err))
+	}
+	if c.Server.ReadTimeout.Duration < 0 {
+		errs = append(errs, errors.New("server.read_timeout: must not be negative"))
+	}
+	if c.Server.WriteTimeout.Duration < 0 {
+		errs = append(errs, errors.New("server.write_timeout: must not be negative"))
+	}
+
+	if c.Server.ShutdownTimeout.Duration <= 0 {
+		errs = append(errs, errors.New("server.shutdown_timeout: must be positive"))
+	}
+	if c.Server.ShutdownDelay.Duration < 0 {
+		errs = append(errs, errors.New("server.shutdown_delay: must not be negative"))
+	}
+	if c.API.BatchWorkers <= 0 {
+		errs = append(errs, errors.New("api.batch_workers: must be positive"))
+	}
+	if c.API.BatchMaxSize <= 0 {
+		errs = append(errs, errors.New("api.batch_max_size: must be positive"))
+	}
+	if c.Tenancy.Header == "" {
+		errs = append(errs, errors.New("tenancy.header: must not be empty"))
+	}
+	for _, pair := range c.Tenancy.Hosts {
+		if host, id, ok := strings.Cut(pair, "="); !ok || host == "" || id == "" {
+			errs = append(errs, fmt.Errorf("tenancy.hosts: %q is not host=tenant", pair))
+		}
+	}
+	if c.Health.Timeout.Duration <= 0 {
+		errs = append(errs, errors.New("health.timeout: must be positive"))
+	}
+
+	errs = append(errs, c.validateTLS()...)
+
+	if c.Tracing.Exporter != "jsonl" && c.Tracing.Exporter != "collector" {
+		errs = append(errs, fmt.Errorf("tracing.exporter: must be \"jsonl\" or \"collector\", got %q", c.Tracing.Exporter))
+	}
+	if c.Tracing.Enabled && c.Tracing.Exporter == "collector" {
+		if u, err := url.Parse(c.Tracing.Endpoint); err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("tracing.endpoint: %q is not an absolute URL", c.Tracing.Endpoint))
+		}
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, errors.New("tracing.sample_ratio: must be between 0 and 1"))
+	}
+	if c.Tracing.ServiceName == "" {
+		errs = append(errs, errors.New("tracing.service_name: must not be empty"))
+	}
+
+	if c.Audit.MaxEntries < 0 {
+		errs = append(errs, errors.New("audit.max_entries: must not be negative"))
+	}
+
+	switch c.Database.Driver {
+	case "mock":
+	case "file":
+		if c.Database.File == "" {
+			errs = append(errs, errors.New("database.file: required by the file driver"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("database.driver: unsupported driver %q, expected mock or file", c.Database.Driver))
+	}
+	if c.Database.MockLatency.Duration < 0 {
+		errs = append(errs, errors.New("database.mock_latency: must not be negative"))
+	}
+	if c.Snapshot.Dir == "" {
+		errs = append(errs, errors.New("snapshot.dir: must not be empty"))
+	}
+
+	if c.Cache.Enabled {
+		if c.Cache.LoginTTL.Duration <= 0 || c.Cache.PointTTL.Duration <= 0 {
+			errs = append(errs, errors.New("cache.login_ttl and cache.point_ttl: must be positive"))
+		}
+		if c.Cache.NegativeTTL.Duration < 0 {
+			errs = append(errs, errors.New("cache.negative_ttl: must not be negative"))
+		}
+		if c.Cache.MaxEntries <= 0 {
+			errs = append(errs, errors.New("cache.max_entries: must be positive"))
+		}
+	}
+
+	if c.Holds.DefaultTTL.Duration <= 0 || c.Holds.MaxTTL.Duration <= 0 {
+		errs = append(errs, errors.New("holds.default_ttl and holds.max_ttl: must be positive"))
+	} else if c.Holds.DefaultTTL.Duration > c.Holds.MaxTTL.Duration {
+		errs = append(errs, errors.New("holds.default_ttl: must not exceed holds.max_ttl"))
+	}
+
+	if c.Scheduler.Enabled {
+		if c.Scheduler.History <= 0 {
+			errs = append(errs, errors.New("scheduler.history: must be positive"))
+		}
+		if c.Scheduler.HoldSweep != "" {
+			if _, err := scheduler.Parse(c.Scheduler.HoldSweep); err != nil {
+				errs = append(errs, fmt.Errorf("scheduler.hold_sweep: %w", err))
+			}
+		}
+	}
+
+	if c.Dashboard.Enabled && c.Dashboard.SessionTTL.Duration <= 0 {
+		errs = append(errs, errors.New("dashboard.session_ttl: must be positive"))
+	}
+
+	if _, err := log.ParseLevel(c.Log.Level); err != nil {
+		errs = append(errs, fmt.Errorf("log.level: %w", err))
+	}
+	if c.Log.Format != "text" && c.Log.Format != "json" {
+		errs = append(errs, fmt.Errorf("log.format: must be \"text\" or \"json\", got %q", c.Log.Format))
+	}
+
+	if u, err := url.Parse(c.Client.Server); err != nil || u.Scheme == "" || u.Host == "" {
+		errs = append(errs, fmt.Errorf("client.server: %q is not an absolute URL", c.Client.Server))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *Config) validateTLS() []error {
+	var errs []error
+
+	if c.TLS.MinVersion != "1.2" && c.TLS.MinVersion != "1.3" {
+		errs = append(errs, fmt.Errorf("tls.min_version: must be \"1.2\" or \"1.3\", got %q", c.TLS.MinVersion))
+	}
+	if c.TLS.CipherPolicy != "default" && c.TLS.CipherPolicy != "modern" {
+		errs = append(errs, fmt.Errorf("tls.cipher_policy: must be \"default\" or \"modern\", got %q", c.TLS.CipherPolicy))
+	}
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		errs = append(errs, errors.New("tls.cert_file and tls.key_file must be set together"))
+	}
+
+	if !c.TLS.Enabled {
+		if c.TLS.RedirectAddr != "" {
+			errs = append(errs, errors.New("tls.redirect_addr: requires tls.enabled"))
+		}
+		return errs
+	}
+
+	if c.TLS.CertFile == "" && !c.TLS.Dev {
+		errs = append(errs, errors.New("tls.enabled: requires tls.cert_file and tls.key_file, or tls.dev"))
+	}
+	if c.TLS.CertFile == "" && c.TLS.Dev && c.TLS.DevCertDir == "" {
+		errs = append(errs, errors.New("tls.dev_cert_dir: required to cache the dev certificate"))
+	}
+	if c.TLS.RedirectAddr != "" {
+		if _, _, err := net.SplitHostPort(c.TLS.RedirectAddr); err != nil {
+			errs = append(errs, fmt.Errorf("tls.redirect_addr: %w", err))
+		} else if c.TLS.RedirectAddr == c.Server.Addr {
+			errs = append(errs, errors.New("tls.redirect_addr: must differ from server.addr"))
+		}
+	}
+
+	return errs
+}
+
+func defaultDevCertDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "golearn", "tls")
+}
+
+// String renders the configuration as indented JSON with secrets redacted.
+func (c Config) String() string {
+	data, err := json.MarshalIndent(c.Redacted(), "", "  ")
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadLayers(t *testing.T) {
+	var path = filepath.Join(t.TempDir(), "config.json")
+	var file = `{"server": {"addr": "localhost:8000", "read_timeout": "3s"}, "api": {"batch_workers": 2}}`
+	if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(EnvName("server.addr"), "localhost:9000")
+	t.Setenv(EnvName("api.batch_workers"), "4")
+
+	cfg, err := Load(path, Overrides{{Key: "api.batch_workers", Value: "8"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var defaults = Default()
+	var tests = []struct {
+		name string
+		got  any
+		want any
+	}{
+		{"default", cfg.Cache.MaxEntries, defaults.Cache.MaxEntries},
+		{"file", cfg.Server.ReadTimeout.Duration, 3 * time.Second},
+		{"environment over file", cfg.Server.Addr, "localhost:9000"},
+		{"flag over environment", cfg.API.BatchWorkers, 8},
+	}
+	for _, test := range tests {
+		if test.got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, test.got, test.want)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	var tests = []struct {
+		name      string
+		file      string
+		overrides Overrides
+		wantErr   []string
+	}{
+		{name: "unknown file field", file: `{"server": {"port": 80}}`, wantErr: []string{`unknown field "port"`}},
+		{name: "unknown key", overrides: Overrides{{Key: "server.port", Value: "80"}}, wantErr: []string{"server.port"}},
+		{name: "bad duration", overrides: Overrides{{Key: "server.read_timeout", Value: "soon"}}, wantErr: []string{"server.read_timeout"}},
+		{
+			name: "every invalid setting",
+			overrides: Overrides{
+				{Key: "server.addr", Value: "nowhere"},
+				{Key: "api.batch_workers", Value: "0"},
+				{Key: "tracing.sample_ratio", Value: "2"},
+			},
+			wantErr: []string{"server.addr", "api.batch_workers", "tracing.sample_ratio"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var path string
+			if test.file != "" {
+				path = filepath.Join(t.TempDir(), "config.json")
+				if err := os.WriteFile(path, []byte(test.file), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			_, err := Load(path, test.overrides)
+			if err == nil {
+				t.Fatal("Load accepted the configuration")
+			}
+			for _, want := range test.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Load = %v, want it to mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestStringRedactsSecrets(t *testing.T) {
+	var cfg = Default()
+	cfg.Audit.Key = "audit-secret"
+	cfg.Client.Token = "ABC123"
+
+	var out = cfg.String()
+	for _, secret := range []string{"audit-secret", "ABC123"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("String shows %q:\n%s", secret, out)
+		}
+	}
+	if cfg.Audit.Key != "audit-secret" {
+		t.Error("String changed the configuration")
+	}
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// redacted replaces the value of every non-empty field tagged secret:"true".
+const redacted = "REDACTED"
+
+// Keys returns every settable key in "section.field" form, using the JSON
+// names of the struct fields.
+func Keys() []string {
+	var keys []string
+	walk(reflect.ValueOf(&Config{}).Elem(), func(key string, _ reflect.Value, _ reflect.StructField) {
+		keys = append(keys, key)
+	})
+	return keys
+}
+
+// EnvName returns the environment variable that sets key,
+// e.g. "server.addr" -> "GOLEARN_SERVER_ADDR".
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// Get returns the string form of the setting named by key.
+func (c *Config) Get(key string) (string, error) {
+	var field, ok = c.field(key)
+	if !ok {
+		return "", fmt.Errorf("unknown setting %q", key)
+	}
+	return format(field), nil
+}
+
+// Set parses value into the setting named by key.
+func (c *Config) Set(key string, value string) error {
+	var field, ok = c.field(key)
+	if !ok {
+		return fmt.Errorf("unknown setting %q", key)
+	}
+
+	if u, isText := field.Addr().Interface().(encoding.TextUnmarshaler); isText {
+		return u.UnmarshalText([]byte(value))
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		field.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		field.SetFloat(f)
+	case reflect.Slice:
+		var parts = []string{}
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				parts = append(parts, part)
+			}
+		}
+		field.Set(reflect.ValueOf(parts))
+	default:
+		return fmt.Errorf("unsupported setting type %s", field.Type())
+	}
+	return nil
+}
+
+// IsBool reports whether key names a boolean setting.
+func IsBool(key string) bool {
+	var cfg = Config{}
+	var field, ok = cfg.field(key)
+	return ok && field.Kind() == reflect.Bool
+}
+
+// Redacted returns a copy of the configuration with secrets masked.
+func (c Config) Redacted() Config {
+	var copied = c
+	walk(reflect.ValueOf(&copied).Elem(), func(_ string, field reflect.Value, sf reflect.StructField) {
+		if sf.Tag.Get("secret") == "true" && field.Kind() == reflect.String && field.String() != "" {
+			field.SetString(redacted)
+		}
+	})
+	return copied
+}
+
+func (c *Config) field(key string) (reflect.Value, bool) {
+	var found reflect.Value
+	walk(reflect.ValueOf(c).Elem(), func(k string, field reflect.Value, _ reflect.StructField) {
+		if k == key {
+			found = field
+		}
+	})
+	return found, found.IsValid()
+}
+
+// walk calls fn for every leaf setting of the two-level Config struct.
+func walk(root reflect.Value, fn func(key string, field reflect.Value, sf reflect.StructField)) {
+	var rootType = root.Type()
+	for i := 0; i < rootType.NumField(); i++ {
+		var section = root.Field(i)
+		var sectionName = jsonName(rootType.Field(i))
+		var sectionType = section.Type()
+		for j := 0; j < sectionType.NumField(); j++ {
+			var sf = sectionType.Field(j)
+			fn(sectionName+"."+jsonName(sf), section.Field(j), sf)
+		}
+	}
+}
+
+func jsonName(sf reflect.StructField) string {
+	var name, _, _ = strings.Cut(sf.Tag.Get("json"), ",")
+	if name == "" {
+		return strings.ToLower(sf.Name)
+	}
+	return name
+}
+
+func format(field reflect.Value) string {
+	if m, ok := field.Interface().(encoding.TextMarshaler); ok {
+		text, _ := m.MarshalText()
+		return string(text)
+	}
+	if field.Kind() == reflect.Slice {
+		var parts = make([]string, field.Len())
+		for i := range parts {
+			parts[i] = fmt.Sprint(field.Index(i).Interface())
+		}
+		return strings.Join(parts, ",")
+	}
+	return fmt.Sprint(field.Interface())
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"flag"
+)
+
+// Override is a single key=value setting taken from the command line.
+type Override struct {
+	Key   string
+	Value string
+}
+
+// Overrides collects command-line settings in the order they were given.
+type Overrides []Override
+
+// Bind registers a flag called name on fs that overrides the setting key.
+// The flag's default shown in --help is the built-in default for key.
+func (o *Overrides) Bind(fs *flag.FlagSet, name string, key string, usage string) {
+	var defaults = Default()
+	var def, err = defaults.Get(key)
+	if err != nil {
+		panic(err)
+	}
+	fs.Var(&overrideFlag{overrides: o, key: key, def: def}, name, usage+" (env "+EnvName(key)+")")
+}
+
+type overrideFlag struct {
+	overrides *Overrides
+	key       string
+	def       string
+}
+
+func (f *overrideFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.def
+}
+
+func (f *overrideFlag) Set(value string) error {
+	var probe = Default()
+	if err := probe.Set(f.key, value); err != nil {
+		return err
+	}
+	*f.overrides = append(*f.overrides, Override{Key: f.key, Value: value})
+	return nil
+}
+
+func (f *overrideFlag) IsBoolFlag() bool {
+	return IsBool(f.key)
+}
@@ -0,0 +1,228 @@
+// Package dashboard serves the admin web UI: server-rendered pages to find
+// accounts, view their balances and ledger, adjust balances and read the
+// audit log. Admins sign in with their username and auth token; every form
+// carries a CSRF token tied to the session.
+package dashboard
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"golearn/src/api"
+	"golearn/src/internal/audit"
+	"golearn/src/internal/config"
+	"golearn/src/internal/logging"
+	"golearn/src/internal/middleware"
+	"golearn/src/internal/tenant"
+	"golearn/src/internal/tools"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	log "github.com/sirupsen/logrus"
+)
+
+// BasePath is where the dashboard is mounted.
+const BasePath = "/admin"
+
+// maxFormBytes bounds the size of a submitted form.
+const maxFormBytes = 64 << 10
+
+//go:embed templates static
+var files embed.FS
+
+var funcs = template.FuncMap{
+	"amount": api.FormatAmount,
+	"signed": api.FormatSignedAmount,
+	"time": func(t time.Time) string {
+		return t.UTC().Format("2006-01-02 15:04:05 MST")
+	},
+}
+
+// pages are the templates of each page, each with the shared layout.
+var pages = parsePages("login.html", "search.html", "account.html", "audit.html", "error.html")
+
+func parsePages(names ...string) map[string]*template.Template {
+	var layout = template.Must(template.New("layout.html").Funcs(funcs).ParseFS(files, "templates/layout.html"))
+	var parsed = map[string]*template.Template{}
+	for _, name := range names {
+		parsed[name] = template.Must(template.Must(layout.Clone()).ParseFS(files, "templates/"+name))
+	}
+	return parsed
+}
+
+// page is what every template is executed with. Viewer is nil on the login
+// page.
+type page struct {
+	Title  string
+	Viewer *viewer
+	Notice string
+	Error  string
+	Data   any
+}
+
+type viewer struct {
+	Username string
+	Tenant   string
+	CSRF     string
+}
+
+type Dashboard struct {
+	database tools.DatabaseInterface
+	tenants  *tenant.Resolver
+	audit    *audit.Log
+	sessions *sessions
+	now      func() time.Time
+}
+
+// New returns the dashboard of database. now is the clock of sessions and
+// statements; nil means time.Now.
+func New(database tools.DatabaseInterface, tenants *tenant.Resolver, auditLog *audit.Log, cfg config.Dashboard, now func() time.Time) *Dashboard {
+	if now == nil {
+		now = time.Now
+	}
+	return &Dashboard{
+		database: database,
+		tenants:  tenants,
+		audit:    auditLog,
+		sessions: newSessions(cfg.SessionTTL.Duration, now),
+		now:      now,
+	}
+}
+
+// Routes returns the handler to mount at BasePath.
+func (d *Dashboard) Routes() http.Handler {
+	var static, _ = fs.Sub(files, "static")
+	var router = chi.NewRouter()
+	router.Use(secureHeaders)
+
+	router.Handle("/static/*", http.StripPrefix(BasePath+"/static/", http.FileServer(http.FS(static))))
+	router.Get("/login", d.loginPage)
+	router.Post("/login", d.login)
+	router.Group(func(r chi.Router) {
+		r.Use(d.authenticate)
+
+		r.Get("/", d.search)
+		r.Post("/logout", d.logout)
+		r.Get("/accounts/{user}", d.account)
+		r.Post("/accounts/{user}/adjust", d.adjust)
+		r.Get("/accounts/{user}/statement", d.statement)
+		r.Get("/audit", d.auditLog)
+	})
+	return router
+}
+
+// secureHeaders keeps the pages out of caches and frames and limits them to
+// resources of the dashboard itself.
+func secureHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var header = w.Header()
+		header.Set("Cache-Control", "no-store")
+		header.Set("Content-Security-Policy", "default-src 'self'; frame-ancestors 'none'; form-action 'self'")
+		header.Set("X-Frame-Options", "DENY")
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("Referrer-Policy", "same-origin")
+		next.ServeHTTP(w, r)
+	})
+}
+
+type sessionKey struct{}
+
+// authenticate lets through requests with a live session of an admin whose
+// token has not changed since signing in, scoped to the session's tenant.
+// Others are sent to the login page. Requests other than GET must carry
+// the session's CSRF token.
+func (d *Dashboard) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var current session
+		var ok bool
+		if cookie, err := r.Cookie(sessionCookie); err == nil {
+			current, ok = d.sessions.get(cookie.Value)
+		}
+		if !ok {
+			http.Redirect(w, r, BasePath+"/login", http.StatusSeeOther)
+			return
+		}
+
+		// The host may pin a tenant; a session of another one is void.
+		tenantID, err := d.tenants.ResolveClaim(r, current.tenant)
+		if err != nil || tenantID != current.tenant {
+			d.endSession(w, r, current.id)
+			return
+		}
+		var ctx = tenant.NewContext(r.Context(), tenantID)
+		logging.AddFields(ctx, log.Fields{"tenant": tenantID})
+
+		loginDetails, err := d.database.GetUserLoginDetails(ctx, current.userID)
+		if err != nil && !errors.Is(err, tools.ErrUserNotFound) {
+			logging.FromContext(ctx).Error(err)
+			d.renderError(w, r, http.StatusInternalServerError, "The account store failed. Try again later.")
+			return
+		}
+		if loginDetails == nil || !current.signedInWith(loginDetails.AuthToken) {
+			d.endSession(w, r, current.id)
+			return
+		}
+		logging.SetUser(ctx, loginDetails.Username)
+		r = r.WithContext(middleware.NewLoginContext(ctx, loginDetails))
+
+		if !loginDetails.HasRole(tools.RoleAdmin) {
+			middleware.RecordAudit(r, d.audit, audit.Event{
+				Action:  audit.ActionAuthForbidden,
+				Outcome: audit.OutcomeFailure,
+				Details: map[string]string{"role": tools.RoleAdmin, "path": r.URL.Path},
+			})
+			d.sessions.delete(current.id)
+			setCookie(w, r, sessionCookie, "", -1)
+			d.renderError(w, r, http.StatusForbidden, "This account is not an admin.")
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			r.Body = http.MaxBytesReader(w, r.Body, maxFormBytes)
+			if !validToken(r.PostFormValue(csrfField), current.csrf) {
+				middleware.RecordAudit(r, d.audit, audit.Event{
+					Action:  audit.ActionAuthForbidden,
+					Outcome: audit.OutcomeFailure,
+					Details: map[string]string{"reason": "csrf", "path": r.URL.Path},
+				})
+				d.renderError(w, r, http.StatusForbidden, "The form has expired. Reload the page and try again.")
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), sessionKey{}, current)))
+	})
+}
+
+// endSession forgets the session and sends the browser to sign in again.
+func (d *Dashboard) endSession(w http.ResponseWriter, r *http.Request, id string) {
+	d.sessions.delete(id)
+	setCookie(w, r, sessionCookie, "", -1)
+	http.Redirect(w, r, BasePath+"/login", http.StatusSeeOther)
+}
+
+// render executes the named page into a buffer, so that a template error
+// is still answered with a 500, and writes it with status.
+func (d *Dashboard) render(w http.ResponseWriter, r *http.Request, status int, name string, p page) {
+	if current, ok := r.Context().Value(sessionKey{}).(session); ok {
+		var loginDetails = middleware.LoginDetailsFromContext(r.Context())
+		p.Viewer = &viewer{Username: loginDetails.Username, Tenant: current.tenant, CSRF: current.csrf}
+	}
+
+	var body bytes.Buffer
+	if err := pages[name].ExecuteTemplate(&body, "layout", p); err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	body.WriteTo(w)
+}
+
+func (d *Dashboard) renderError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	d.render(w, r, status, "error.html", page{Title: http.StatusText(status), Error: message})
+}
@@ -0,0 +1,186 @@
+package dashboard_test
+
+import (
+	"context"
+	"golearn/src/apitest"
+	"io"
+	"math"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var csrfPattern = regexp.MustCompile(`name="csrf_token" value="([^"]+)"`)
+
+// browser is a client with a cookie jar that follows the dashboard's
+// redirects and remembers the last CSRF token it was shown.
+type browser struct {
+	t      *testing.T
+	srv    *apitest.Server
+	client *http.Client
+	csrf   string
+}
+
+func newBrowser(t *testing.T, srv *apitest.Server) *browser {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &browser{t: t, srv: srv, client: &http.Client{Jar: jar}}
+}
+
+func (b *browser) get(path string) (int, string) {
+	b.t.Helper()
+	return b.read(b.client.Get(b.srv.URL + path))
+}
+
+func (b *browser) post(path string, form url.Values) (int, string) {
+	b.t.Helper()
+	return b.read(b.client.PostForm(b.srv.URL+path, form))
+}
+
+func (b *browser) read(resp *http.Response, err error) (int, string) {
+	b.t.Helper()
+	if err != nil {
+		b.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		b.t.Fatal(err)
+	}
+	if match := csrfPattern.FindSubmatch(body); match != nil {
+		b.csrf = string(match[1])
+	}
+	return resp.StatusCode, string(body)
+}
+
+// login signs in through the form and returns the status of the page it
+// ends on.
+func (b *browser) login(user apitest.User, token string) int {
+	b.t.Helper()
+	b.get("/admin/login")
+	status, _ := b.post("/admin/login", url.Values{
+		"username":   {user.Username},
+		"token":      {token},
+		"csrf_token": {b.csrf},
+	})
+	return status
+}
+
+func TestLogin(t *testing.T) {
+	var tests = []struct {
+		name       string
+		admin      bool
+		wrongToken bool
+		noCSRF     bool
+		want       int
+		wantAudit  string
+	}{
+		{name: "admin", admin: true, want: http.StatusOK, wantAudit: "auth.success"},
+		{name: "wrong token", admin: true, wrongToken: true, want: http.StatusUnauthorized, wantAudit: "auth.failure"},
+		{name: "not an admin", want: http.StatusForbidden, wantAudit: "auth.forbidden"},
+		{name: "missing CSRF token", admin: true, noCSRF: true, want: http.StatusForbidden},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var srv = apitest.New(t, apitest.Options{})
+			var user = apitest.User{Username: "alice"}
+			if test.admin {
+				user = srv.CreateAdmin(user)
+			} else {
+				user = srv.CreateUser(user)
+			}
+			var token = user.Token
+			if test.wrongToken {
+				token = "wrong"
+			}
+
+			var b = newBrowser(t, srv)
+			var status int
+			if test.noCSRF {
+				status, _ = b.post("/admin/login", url.Values{"username": {user.Username}, "token": {token}})
+			} else {
+				status = b.login(user, token)
+			}
+			if status != test.want {
+				t.Fatalf("login status = %d, want %d", status, test.want)
+			}
+
+			if test.wantAudit == "" {
+				return
+			}
+			var entries = srv.AuditEntries(apitest.AuditFilter{Action: test.wantAudit})
+			if len(entries) != 1 {
+				t.Fatalf("%s entries = %+v, want one", test.wantAudit, entries)
+			}
+			var wantActor = user.ID
+			if test.wrongToken {
+				wantActor = user.Username
+			}
+			if entries[0].Actor != wantActor {
+				t.Fatalf("entry actor = %q, want %q", entries[0].Actor, wantActor)
+			}
+		})
+	}
+}
+
+func TestAdjust(t *testing.T) {
+	var tests = []struct {
+		name        string
+		balance     int64
+		amount      string
+		noCSRF      bool
+		want        int
+		wantBalance int64
+	}{
+		{name: "credit", balance: 10, amount: "5", want: http.StatusOK, wantBalance: 15},
+		{name: "debit", balance: 10, amount: "-4", want: http.StatusOK, wantBalance: 6},
+		{name: "insufficient funds", balance: 10, amount: "-11", want: http.StatusConflict, wantBalance: 10},
+		{name: "overflow", balance: math.MaxInt64, amount: "1", want: http.StatusConflict, wantBalance: math.MaxInt64},
+		{name: "zero", balance: 10, amount: "0", want: http.StatusBadRequest, wantBalance: 10},
+		{name: "missing CSRF token", balance: 10, amount: "5", noCSRF: true, want: http.StatusForbidden, wantBalance: 10},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var srv = apitest.New(t, apitest.Options{})
+			var admin = srv.CreateAdmin(apitest.User{Username: "root"})
+			var user = srv.CreateUser(apitest.User{Username: "alice", Balance: test.balance})
+
+			var b = newBrowser(t, srv)
+			if status := b.login(admin, admin.Token); status != http.StatusOK {
+				t.Fatalf("login status = %d", status)
+			}
+			if status, _ := b.get("/admin/accounts/" + user.ID); status != http.StatusOK {
+				t.Fatalf("account page status = %d", status)
+			}
+			var form = url.Values{
+				"wallet":     {"points"},
+				"amount":     {test.amount},
+				"reason":     {"support ticket"},
+				"csrf_token": {b.csrf},
+			}
+			if test.noCSRF {
+				form.Del("csrf_token")
+			}
+			status, body := b.post("/admin/accounts/"+user.ID+"/adjust", form)
+			if status != test.want {
+				t.Fatalf("adjust status = %d, want %d\n%s", status, test.want, body)
+			}
+
+			balance, err := srv.Client(user).GetPointBalance(context.Background(), "points")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := balance.Wallets[0].Balance; got != test.wantBalance {
+				t.Fatalf("balance = %d, want %d", got, test.wantBalance)
+			}
+			if test.want == http.StatusOK && !strings.Contains(body, "The adjustment was applied.") {
+				t.Fatalf("the account page does not confirm the adjustment:\n%s", body)
+			}
+		})
+	}
+}
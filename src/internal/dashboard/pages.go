@@ -0,0 +1,466 @@
+package dashboard
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"golearn/src/api"
+	"golearn/src/internal/audit"
+	"golearn/src/internal/logging"
+	"golearn/src/internal/metrics"
+	"golearn/src/internal/middleware"
+	"golearn/src/internal/statement"
+	"golearn/src/internal/tenant"
+	"golearn/src/internal/tools"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// searchLimit bounds how many accounts a search lists.
+	searchLimit = 50
+	// ledgerLimit bounds how many ledger entries the account page shows.
+	ledgerLimit = 100
+	// maxReasonLength bounds the reason of an adjustment.
+	maxReasonLength   = 200
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+var errSearchFull = errors.New("search limit reached")
+
+type loginForm struct {
+	Tenant   string
+	Username string
+	CSRF     string
+}
+
+func (d *Dashboard) loginPage(w http.ResponseWriter, r *http.Request) {
+	d.renderLogin(w, r, http.StatusOK, loginForm{}, "")
+}
+
+// renderLogin shows the login form with a fresh CSRF token.
+func (d *Dashboard) renderLogin(w http.ResponseWriter, r *http.Request, status int, form loginForm, message string) {
+	form.CSRF = randomToken()
+	setCookie(w, r, loginCookie, form.CSRF, 0)
+	d.render(w, r, status, "login.html", page{Title: "Sign in", Error: message, Data: form})
+}
+
+// login signs in an admin with their username and auth token.
+func (d *Dashboard) login(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormBytes)
+	var form = loginForm{
+		Tenant:   strings.TrimSpace(r.PostFormValue("tenant")),
+		Username: strings.TrimSpace(r.PostFormValue("username")),
+	}
+	var token = r.PostFormValue("token")
+
+	cookie, err := r.Cookie(loginCookie)
+	if err != nil || !validToken(r.PostFormValue(csrfField), cookie.Value) {
+		d.renderLogin(w, r, http.StatusForbidden, form, "The form has expired. Try again.")
+		return
+	}
+	tenantID, err := d.tenants.ResolveClaim(r, form.Tenant)
+	if err != nil {
+		d.renderLogin(w, r, http.StatusBadRequest, form, err.Error())
+		return
+	}
+	var ctx = tenant.NewContext(r.Context(), tenantID)
+	logging.AddFields(ctx, log.Fields{"tenant": tenantID})
+	r = r.WithContext(ctx)
+
+	loginDetails, err := d.database.GetUserLoginDetails(ctx, form.Username)
+	if err != nil && !errors.Is(err, tools.ErrUserNotFound) {
+		logging.FromContext(ctx).Error(err)
+		d.renderLogin(w, r, http.StatusInternalServerError, form, "The account store failed. Try again later.")
+		return
+	}
+	if form.Username == "" || loginDetails == nil || subtle.ConstantTimeCompare([]byte(token), []byte(loginDetails.AuthToken)) != 1 {
+		var subject = form.Username
+		if loginDetails != nil {
+			subject = loginDetails.UserID
+		}
+		metrics.AuthFailures.Inc("invalid_credentials")
+		middleware.RecordAudit(r, d.audit, audit.Event{
+			Action:  audit.ActionAuthFailure,
+			Actor:   form.Username,
+			Subject: subject,
+			Outcome: audit.OutcomeFailure,
+			Details: map[string]string{"reason": "invalid_credentials", "via": "dashboard"},
+		})
+		d.renderLogin(w, r, http.StatusUnauthorized, form, "Invalid tenant, username or token.")
+		return
+	}
+	if !loginDetails.HasRole(tools.RoleAdmin) {
+		metrics.AuthFailures.Inc("forbidden")
+		middleware.RecordAudit(r, d.audit, audit.Event{
+			Action:  audit.ActionAuthForbidden,
+			Actor:   loginDetails.UserID,
+			Outcome: audit.OutcomeFailure,
+			Details: map[string]string{"role": tools.RoleAdmin, "path": r.URL.Path},
+		})
+		d.renderLogin(w, r, http.StatusForbidden, form, "This account is not an admin.")
+		return
+	}
+
+	var created = d.sessions.create(tenantID, loginDetails.UserID, loginDetails.AuthToken)
+	middleware.RecordAudit(r, d.audit, audit.Event{
+		Action:  audit.ActionAuthSuccess,
+		Actor:   loginDetails.UserID,
+		Subject: loginDetails.UserID,
+		Details: map[string]string{"via": "dashboard"},
+	})
+	setCookie(w, r, loginCookie, "", -1)
+	setCookie(w, r, sessionCookie, created.id, 0)
+	http.Redirect(w, r, BasePath, http.StatusSeeOther)
+}
+
+func (d *Dashboard) logout(w http.ResponseWriter, r *http.Request) {
+	var current = r.Context().Value(sessionKey{}).(session)
+	d.endSession(w, r, current.id)
+}
+
+type searchView struct {
+	Query     string
+	Accounts  []tools.Account
+	Truncated bool
+}
+
+// search lists the accounts whose username or name contains the query, or
+// whose ID is the query; all of them when it is empty.
+func (d *Dashboard) search(w http.ResponseWriter, r *http.Request) {
+	var view = searchView{Query: strings.TrimSpace(r.URL.Query().Get("q"))}
+	var needle = strings.ToLower(view.Query)
+	var err = d.database.ListAccounts(r.Context(), func(account tools.Account) error {
+		if needle != "" && account.ID != view.Query &&
+			!strings.Contains(strings.ToLower(account.Username), needle) &&
+			!strings.Contains(strings.ToLower(account.Name), needle) {
+			return nil
+		}
+		if len(view.Accounts) == searchLimit {
+			view.Truncated = true
+			return errSearchFull
+		}
+		view.Accounts = append(view.Accounts, account)
+		return nil
+	})
+	if err != nil && !errors.Is(err, errSearchFull) {
+		logging.FromContext(r.Context()).Error(err)
+		d.renderError(w, r, http.StatusInternalServerError, "The account store failed. Try again later.")
+		return
+	}
+	d.render(w, r, http.StatusOK, "search.html", page{Title: "Accounts", Data: view})
+}
+
+type accountView struct {
+	Account tools.Account
+	Wallets []api.WalletBalance
+	Holds   []holdRow
+	// Ledger is newest first; More counts the older entries left out.
+	Ledger []ledgerRow
+	More   int
+	Period string
+	Form   adjustForm
+}
+
+type holdRow struct {
+	tools.Hold
+	Precision int
+}
+
+type ledgerRow struct {
+	tools.LedgerEntry
+	Precision int
+}
+
+type adjustForm struct {
+	Wallet string
+	Amount string
+	Reason string
+}
+
+func (d *Dashboard) account(w http.ResponseWriter, r *http.Request) {
+	account, ok := d.findAccount(w, r)
+	if !ok {
+		return
+	}
+	middleware.RecordAudit(r, d.audit, audit.Event{
+		Action:  audit.ActionAccountRead,
+		Subject: account.ID,
+		Details: map[string]string{"via": "dashboard"},
+	})
+
+	var notice string
+	if r.URL.Query().Get("adjusted") != "" {
+		notice = "The adjustment was applied."
+	}
+	d.render(w, r, http.StatusOK, "account.html", page{
+		Title:  account.Username,
+		Notice: notice,
+		Data:   d.accountView(*account, adjustForm{}),
+	})
+}
+
+// adjust changes a balance of the account by a signed amount in major
+// units, recording the reason in the ledger and the audit log.
+func (d *Dashboard) adjust(w http.ResponseWriter, r *http.Request) {
+	account, ok := d.findAccount(w, r)
+	if !ok {
+		return
+	}
+	var form = adjustForm{
+		Wallet: r.PostFormValue("wallet"),
+		Amount: strings.TrimSpace(r.PostFormValue("amount")),
+		Reason: strings.TrimSpace(r.PostFormValue("reason")),
+	}
+	var invalid = func(status int, message string) {
+		d.render(w, r, status, "account.html", page{
+			Title: account.Username,
+			Error: message,
+			Data:  d.accountView(*account, form),
+		})
+	}
+
+	wallet, found := tools.PointDetails{Wallets: account.Wallets}.Wallet(form.Wallet)
+	if !found {
+		invalid(http.StatusBadRequest, fmt.Sprintf("The account has no wallet %q.", form.Wallet))
+		return
+	}
+	delta, err := api.ParseAmount(form.Amount, wallet.Precision)
+	switch {
+	case err != nil:
+		invalid(http.StatusBadRequest, fmt.Sprintf("The amount must be a number with at most %d decimal places.", wallet.Precision))
+		return
+	case delta == 0:
+		invalid(http.StatusBadRequest, "The amount must not be zero.")
+		return
+	case form.Reason == "":
+		invalid(http.StatusBadRequest, "A reason is required.")
+		return
+	case len(form.Reason) > maxReasonLength:
+		invalid(http.StatusBadRequest, fmt.Sprintf("The reason must be at most %d characters.", maxReasonLength))
+		return
+	}
+
+	_, err = d.database.AdjustBalance(r.Context(), account.ID, wallet.Name, delta, form.Reason)
+	var details = map[string]string{
+		"wallet": wallet.Name,
+		"amount": strconv.FormatInt(delta, 10),
+		"reason": form.Reason,
+		"via":    "dashboard",
+	}
+	if err != nil {
+		details["error"] = err.Error()
+	}
+	middleware.RecordAudit(r, d.audit, audit.Event{
+		Action:  audit.ActionBalanceAdjust,
+		Subject: account.ID,
+		Outcome: audit.OutcomeOf(err),
+		Details: details,
+	})
+	switch {
+	case err == nil:
+		http.Redirect(w, r, BasePath+"/accounts/"+account.ID+"?adjusted=1", http.StatusSeeOther)
+	case errors.Is(err, tools.ErrInsufficientFunds):
+		invalid(http.StatusConflict, "The wallet does not have that much available.")
+	case errors.Is(err, tools.ErrBalanceOverflow):
+		invalid(http.StatusConflict, "The wallet cannot hold that much.")
+	case errors.Is(err, tools.ErrUserNotFound):
+		d.renderError(w, r, http.StatusNotFound, "The account no longer exists.")
+	case errors.Is(err, tools.ErrWalletNotFound), errors.Is(err, tools.ErrInvalidAdjustment):
+		invalid(http.StatusBadRequest, err.Error())
+	default:
+		logging.FromContext(r.Context()).Error(err)
+		d.renderError(w, r, http.StatusInternalServerError, "The account store failed. Try again later.")
+	}
+}
+
+// statement shows the account's statement for the month in the period
+// parameter.
+func (d *Dashboard) statement(w http.ResponseWriter, r *http.Request) {
+	period, err := statement.ParsePeriod(r.URL.Query().Get("period"))
+	if err != nil {
+		d.renderError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	account, ok := d.findAccount(w, r)
+	if !ok {
+		return
+	}
+	result, err := statement.Build(*account, period, d.now())
+	middleware.RecordAudit(r, d.audit, audit.Event{
+		Action:  audit.ActionStatementRead,
+		Subject: account.ID,
+		Outcome: audit.OutcomeOf(err),
+		Details: map[string]string{"period": period.String(), "format": statement.FormatHTML, "via": "dashboard"},
+	})
+	if err != nil {
+		d.renderError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var body bytes.Buffer
+	if err = statement.Render(&body, statement.FormatHTML, result); err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		d.renderError(w, r, http.StatusInternalServerError, "The statement could not be rendered.")
+		return
+	}
+	// Statements carry their own inline styles.
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; frame-ancestors 'none'")
+	w.Header().Set("Content-Type", statement.ContentType(statement.FormatHTML))
+	body.WriteTo(w)
+}
+
+type auditForm struct {
+	User   string
+	Action string
+	Since  string
+	Until  string
+	Limit  int
+}
+
+type auditView struct {
+	Enabled    bool
+	Form       auditForm
+	ChainValid bool
+	ChainError string
+	Entries    []audit.Entry
+}
+
+// auditLog searches the audit log of the tenant. Since and Until are dates,
+// Until included, or RFC 3339 timestamps.
+func (d *Dashboard) auditLog(w http.ResponseWriter, r *http.Request) {
+	var query = r.URL.Query()
+	var view = auditView{
+		Enabled:    d.audit != nil,
+		ChainValid: true,
+		Form: auditForm{
+			User:   strings.TrimSpace(query.Get("user")),
+			Action: strings.TrimSpace(query.Get("action")),
+			Since:  query.Get("since"),
+			Until:  query.Get("until"),
+			Limit:  defaultAuditLimit,
+		},
+	}
+	var render = func(status int, message string) {
+		d.render(w, r, status, "audit.html", page{Title: "Audit log", Error: message, Data: view})
+	}
+	if !view.Enabled {
+		render(http.StatusOK, "")
+		return
+	}
+
+	tenantID, _ := tenant.FromContext(r.Context())
+	var filter = audit.Filter{Tenant: tenantID, User: view.Form.User, Action: view.Form.Action}
+	if view.Form.User != "" {
+		loginDetails, err := d.database.GetUserLoginDetails(r.Context(), view.Form.User)
+		if err == nil {
+			filter.User = loginDetails.UserID
+		} else if !errors.Is(err, tools.ErrUserNotFound) {
+			logging.FromContext(r.Context()).Error(err)
+			render(http.StatusInternalServerError, "The account store failed. Try again later.")
+			return
+		}
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			render(http.StatusBadRequest, "The limit must be a positive number.")
+			return
+		}
+		view.Form.Limit = min(n, maxAuditLimit)
+	}
+	filter.Limit = view.Form.Limit
+	var err error
+	if filter.Since, err = parseDate(view.Form.Since, false); err != nil {
+		render(http.StatusBadRequest, "Since must be a date.")
+		return
+	}
+	if filter.Until, err = parseDate(view.Form.Until, true); err != nil {
+		render(http.StatusBadRequest, "Until must be a date.")
+		return
+	}
+
+	middleware.RecordAudit(r, d.audit, audit.Event{
+		Action:  audit.ActionAuditQuery,
+		Subject: filter.User,
+		Details: map[string]string{"action": view.Form.Action, "since": view.Form.Since, "until": view.Form.Until, "via": "dashboard"},
+	})
+	var chainErr *audit.ChainError
+	if err := d.audit.Verify(); errors.As(err, &chainErr) {
+		view.ChainValid = false
+		view.ChainError = chainErr.Error()
+	}
+	view.Entries = d.audit.Query(filter)
+	render(http.StatusOK, "")
+}
+
+// findAccount looks up the account named in the path, answering the
+// request itself when it cannot.
+func (d *Dashboard) findAccount(w http.ResponseWriter, r *http.Request) (*tools.Account, bool) {
+	var user = chi.URLParam(r, "user")
+	account, err := d.database.GetAccount(r.Context(), user)
+	switch {
+	case errors.Is(err, tools.ErrUserNotFound):
+		d.renderError(w, r, http.StatusNotFound, fmt.Sprintf("There is no account %q in this tenant.", user))
+		return nil, false
+	case err != nil:
+		logging.FromContext(r.Context()).Error(err)
+		d.renderError(w, r, http.StatusInternalServerError, "The account store failed. Try again later.")
+		return nil, false
+	}
+	return account, true
+}
+
+func (d *Dashboard) accountView(account tools.Account, form adjustForm) accountView {
+	var details = tools.PointDetails{Wallets: account.Wallets, Holds: account.Holds}
+	var precisions = map[string]int{}
+	var view = accountView{
+		Account: account,
+		Period:  statement.PeriodOf(d.now()).String(),
+		Form:    form,
+	}
+	for _, wallet := range account.Wallets {
+		precisions[wallet.Name] = wallet.Precision
+		view.Wallets = append(view.Wallets, api.WalletBalance{
+			Name:      wallet.Name,
+			Currency:  wallet.Currency,
+			Precision: wallet.Precision,
+			Balance:   wallet.Balance,
+			Available: details.Available(wallet.Name),
+		})
+	}
+	for _, hold := range account.Holds {
+		view.Holds = append(view.Holds, holdRow{Hold: hold, Precision: precisions[hold.Wallet]})
+	}
+	for i := len(account.Ledger) - 1; i >= 0; i-- {
+		if len(view.Ledger) == ledgerLimit {
+			view.More = i + 1
+			break
+		}
+		var entry = account.Ledger[i]
+		view.Ledger = append(view.Ledger, ledgerRow{LedgerEntry: entry, Precision: precisions[entry.Wallet]})
+	}
+	return view
+}
+
+// parseDate reads a date as written by a date input, or an RFC 3339
+// timestamp. A date as an upper bound includes the whole day.
+func parseDate(value string, end bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if day, err := time.Parse(time.DateOnly, value); err == nil {
+		if end {
+			day = day.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		return day, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,121 @@
+package dashboard
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	sessionCookie = "golearn_session"
+	// loginCookie carries the CSRF token of the login form, which is
+	// submitted before there is a session to keep it in.
+	loginCookie = "golearn_login"
+	csrfField   = "csrf_token"
+)
+
+// session is a signed-in admin. tokenHash is the SHA-256 of the auth token
+// used to sign in, so that changing the token ends the session.
+type session struct {
+	id        string
+	tenant    string
+	userID    string
+	tokenHash [sha256.Size]byte
+	csrf      string
+	expires   time.Time
+}
+
+// sessions keeps the sessions of the dashboard in memory; they end when
+// the server restarts. A session expires after ttl without a request.
+type sessions struct {
+	mu   sync.Mutex
+	byID map[string]*session
+	ttl  time.Duration
+	now  func() time.Time
+}
+
+func newSessions(ttl time.Duration, now func() time.Time) *sessions {
+	return &sessions{byID: map[string]*session{}, ttl: ttl, now: now}
+}
+
+func (s *sessions) create(tenantID string, userID string, token string) session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var now = s.now()
+	for id, current := range s.byID {
+		if !now.Before(current.expires) {
+			delete(s.byID, id)
+		}
+	}
+	var created = &session{
+		id:        randomToken(),
+		tenant:    tenantID,
+		userID:    userID,
+		tokenHash: sha256.Sum256([]byte(token)),
+		csrf:      randomToken(),
+		expires:   now.Add(s.ttl),
+	}
+	s.byID[created.id] = created
+	return *created
+}
+
+// get returns the live session with id and extends it.
+func (s *sessions) get(id string) (session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.byID[id]
+	if !ok {
+		return session{}, false
+	}
+	var now = s.now()
+	if !now.Before(current.expires) {
+		delete(s.byID, id)
+		return session{}, false
+	}
+	current.expires = now.Add(s.ttl)
+	return *current, true
+}
+
+func (s *sessions) delete(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.byID, id)
+}
+
+// signedInWith reports whether token is the one the session was created
+// with.
+func (s session) signedInWith(token string) bool {
+	var hash = sha256.Sum256([]byte(token))
+	return subtle.ConstantTimeCompare(hash[:], s.tokenHash[:]) == 1
+}
+
+func randomToken() string {
+	var raw = make([]byte, 32)
+	rand.Read(raw)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// validToken compares a submitted CSRF token with the expected one.
+func validToken(got string, want string) bool {
+	return want != "" && subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
+
+// setCookie sets a cookie scoped to the dashboard that scripts cannot read
+// and other sites cannot send. A negative maxAge deletes it.
+func setCookie(w http.ResponseWriter, r *http.Request, name string, value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     BasePath,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"errors"
+	"golearn/src/api"
+	"golearn/src/internal/audit"
+	"golearn/src/internal/logging"
+	"golearn/src/internal/middleware"
+	"golearn/src/internal/tools"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/gorilla/schema"
+)
+
+// GetOwnAccount describes the authenticated account.
+func GetOwnAccount(database tools.DatabaseInterface, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		getAccount(w, r, database, auditLog, middleware.LoginDetailsFromContext(r.Context()).UserID)
+	}
+}
+
+// GetAccount lets admins look up any account of their tenant by ID or
+// username.
+func GetAccount(database tools.DatabaseInterface, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		getAccount(w, r, database, auditLog, chi.URLParam(r, "user"))
+	}
+}
+
+func getAccount(w http.ResponseWriter, r *http.Request, database tools.DatabaseInterface, auditLog *audit.Log, user string) {
+	account, err := database.GetAccount(r.Context(), user)
+	var subject = user
+	if account != nil {
+		subject = account.ID
+	}
+	middleware.RecordAudit(r, auditLog, audit.Event{
+		Action:  audit.ActionAccountRead,
+		Subject: subject,
+		Outcome: audit.OutcomeOf(err),
+	})
+	if errors.Is(err, tools.ErrUserNotFound) {
+		api.RequestErrorHandler(w, r, err)
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.InternalErrorHandler(w, r)
+		return
+	}
+
+	writeResponse(w, r, http.StatusOK, accountResponse(*account))
+}
+
+// RenameAccount lets admins change the username of an account, found by ID
+// or its current username. The account keeps its ID and records the old
+// username in its history.
+func RenameAccount(database tools.DatabaseInterface, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params = api.RenameAccountParams{}
+		var decoder *schema.Decoder = schema.NewDecoder()
+		decoder.IgnoreUnknownKeys(true)
+		var err error
+
+		err = r.ParseForm()
+		if err == nil {
+			err = decoder.Decode(&params, r.Form)
+		}
+		if err != nil {
+			api.BadRequestErrorHandler(w, r, err)
+			return
+		}
+
+		var user = chi.URLParam(r, "user")
+		var event = audit.Event{
+			Action:  audit.ActionAccountRename,
+			Subject: user,
+			Details: map[string]string{"to": params.NewUsername},
+		}
+		if previous, err := database.GetUserLoginDetails(r.Context(), user); err == nil {
+			event.Subject = previous.UserID
+			event.Details["from"] = previous.Username
+		}
+
+		renamed, err := database.RenameUser(r.Context(), user, params.NewUsername)
+		event.Outcome = audit.OutcomeOf(err)
+		if err != nil {
+			event.Details["error"] = err.Error()
+		}
+		middleware.RecordAudit(r, auditLog, event)
+		switch {
+		case errors.Is(err, tools.ErrUserNotFound):
+			api.RequestErrorHandler(w, r, err)
+			return
+		case errors.Is(err, tools.ErrInvalidUsername):
+			api.BadRequestErrorHandler(w, r, err)
+			return
+		case errors.Is(err, tools.ErrUsernameTaken):
+			api.ConflictErrorHandler(w, r, err)
+			return
+		case err != nil:
+			logging.FromContext(r.Context()).Error(err)
+			api.InternalErrorHandler(w, r)
+			return
+		}
+
+		account, err := database.GetAccount(r.Context(), renamed.UserID)
+		if err != nil {
+			logging.FromContext(r.Context()).Error(err)
+			api.InternalErrorHandler(w, r)
+			return
+		}
+		writeResponse(w, r, http.StatusOK, accountResponse(*account))
+	}
+}
+
+func accountResponse(account tools.Account) api.AccountResponse {
+	var response = api.AccountResponse{
+		Code:     http.StatusOK,
+		UserID:   account.ID,
+		Username: account.Username,
+		Name:     account.Name,
+		Roles:    account.Roles,
+		Wallets:  walletBalances(account.Wallets, account.Holds),
+		Renames:  make([]api.AccountRename, 0, len(account.Renames)),
+	}
+	if response.Roles == nil {
+		response.Roles = []string{}
+	}
+	for _, rename := range account.Renames {
+		response.Renames = append(response.Renames, api.AccountRename{
+			From: rename.From,
+			To:   rename.To,
+			At:   rename.At.Format(time.RFC3339Nano),
+		})
+	}
+	return response
+}
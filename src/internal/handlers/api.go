@@ -1,21 +1,159 @@
 package handlers
 
 import (
+	"golearn/src/api"
+	"golearn/src/internal/audit"
+	"golearn/src/internal/config"
+	"golearn/src/internal/dashboard"
+	"golearn/src/internal/health"
+	"golearn/src/internal/metrics"
 	"golearn/src/internal/middleware"
+	"golearn/src/internal/scheduler"
+	"golearn/src/internal/snapshot"
+	"golearn/src/internal/tenant"
+	"golearn/src/internal/tools"
+	"golearn/src/internal/tracing"
+	"net/http"
+	"time"
 
 	"github.com/go-chi/chi"
 	chimiddle "github.com/go-chi/chi/middleware"
 )
 
-func Handler(router *chi.Mux) {
+// Dependencies are the shared services the routes are built from.
+type Dependencies struct {
+	Database tools.DatabaseInterface
+	Tenants  *tenant.Resolver
+	Audit    *audit.Log
+	Health   *health.Checker
+	API      config.API
+	Holds    config.Holds
+	// Now is the time statements are generated at and the clock of
+	// dashboard sessions; nil means time.Now.
+	Now func() time.Time
+	// Dashboard serves the admin web UI under /admin when enabled.
+	Dashboard config.Dashboard
+	// Snapshots serves the snapshot API to admins of Snapshot.OperatorTenant.
+	Snapshots *snapshot.Store
+	Snapshot  config.Snapshot
+	// Jobs serves the background jobs API to admins of
+	// Scheduler.OperatorTenant.
+	Jobs      *scheduler.Scheduler
+	Scheduler config.Scheduler
+	// Metrics enables request instrumentation and the /metrics endpoint.
+	Metrics bool
+	// AccessLog writes one log line per request.
+	AccessLog bool
+	// Tracing records spans for requests and middleware.
+	Tracing bool
+	// MockControl serves /debug/mock when set. It is for tests only: the
+	// endpoint is unauthenticated.
+	MockControl tools.MockController
+}
+
+func Handler(router *chi.Mux, deps Dependencies) {
+	router.Use(middleware.RequestID)
+	if deps.AccessLog {
+		router.Use(middleware.AccessLog)
+	}
+	if deps.Metrics {
+		router.Use(metrics.Middleware)
+	}
+	if deps.Tracing {
+		router.Use(tracing.Middleware)
+	}
 	router.Use(chimiddle.StripSlashes)
 
+	var now = deps.Now
+	if now == nil {
+		now = time.Now
+	}
+
+	router.Get("/healthz", deps.Health.LivenessHandler)
+	router.Get("/readyz", deps.Health.ReadinessHandler)
+	if deps.Metrics {
+		router.Get("/metrics", metrics.Handler())
+	}
+	if deps.Dashboard.Enabled {
+		router.Mount(dashboard.BasePath, dashboard.New(deps.Database, deps.Tenants, deps.Audit, deps.Dashboard, now).Routes())
+	}
+	if deps.MockControl != nil {
+		router.Get("/debug/mock", GetMockScenario(deps.MockControl))
+		router.Put("/debug/mock", SetMockScenario(deps.MockControl))
+	}
+
 	router.Route("/api", func(r chi.Router) {
+		r.Use(middleware.Tenant(deps.Tenants))
+
+		var authorization = traced(deps, "middleware.Authorization", middleware.Authorization(deps.Database, deps.Audit))
 
 		r.Route("/account", func(acc chi.Router) {
-			acc.Use(middleware.Authorization)
+			acc.Use(authorization)
 
-			acc.Get("/balance", GetPointBalance)
+			acc.Get("/", GetOwnAccount(deps.Database, deps.Audit))
+			acc.Get("/balance", GetPointBalance(deps.Database, deps.Audit))
+			acc.With(middleware.RequireRole(tools.RoleAdmin, deps.Audit)).Post("/credit", CreditPointBalance(deps.Database, deps.Audit))
+			acc.Post("/debit", DebitPointBalance(deps.Database, deps.Audit))
+			acc.Get("/holds", ListHolds(deps.Database, deps.Audit))
+			acc.Post("/holds", PlaceHold(deps.Database, deps.Audit, deps.Holds))
+			acc.Post("/holds/{hold}/capture", CaptureHold(deps.Database, deps.Audit))
+			acc.Post("/holds/{hold}/release", ReleaseHold(deps.Database, deps.Audit))
+			acc.With(api.AcceptOnly).Get("/statements/{period}", GetOwnStatement(deps.Database, deps.Audit, now))
 		})
+
+		r.Route("/accounts", func(admin chi.Router) {
+			admin.Use(authorization)
+			admin.Use(middleware.RequireRole(tools.RoleAdmin, deps.Audit))
+
+			admin.Post("/balances", GetPointBalances(deps.Database, deps.Audit, deps.API))
+			admin.With(api.AcceptOnly).Post("/import", ImportAccounts(deps.Database, deps.Audit))
+			admin.With(api.AcceptOnly).Get("/export", ExportAccounts(deps.Database, deps.Audit))
+			admin.Get("/statements/{period}", ListStatements(deps.Database, deps.Audit, now))
+			admin.Get("/{user}", GetAccount(deps.Database, deps.Audit))
+			admin.Post("/{user}/rename", RenameAccount(deps.Database, deps.Audit))
+			admin.Post("/{user}/wallets", OpenWallet(deps.Database, deps.Audit))
+			admin.With(api.AcceptOnly).Get("/{user}/statements/{period}", GetStatement(deps.Database, deps.Audit, now))
+		})
+
+		r.Route("/audit", func(admin chi.Router) {
+			admin.Use(authorization)
+			admin.Use(middleware.RequireRole(tools.RoleAdmin, deps.Audit))
+
+			admin.Get("/", GetAuditLog(deps.Audit, deps.Database))
+		})
+
+		if deps.Snapshots != nil && deps.Snapshot.OperatorTenant != "" {
+			r.Route("/snapshots", func(operator chi.Router) {
+				operator.Use(authorization)
+				operator.Use(middleware.RequireRole(tools.RoleAdmin, deps.Audit))
+				operator.Use(middleware.RequireTenant(deps.Snapshot.OperatorTenant, deps.Audit))
+
+				operator.Get("/", ListSnapshots(deps.Snapshots))
+				operator.Post("/", CreateSnapshot(deps.Snapshots, deps.Database, deps.Audit))
+				operator.Post("/{name}/restore", RestoreSnapshot(deps.Snapshots, deps.Database, deps.Audit))
+			})
+		}
+
+		if deps.Jobs != nil && deps.Scheduler.OperatorTenant != "" {
+			r.Route("/jobs", func(operator chi.Router) {
+				operator.Use(authorization)
+				operator.Use(middleware.RequireRole(tools.RoleAdmin, deps.Audit))
+				operator.Use(middleware.RequireTenant(deps.Scheduler.OperatorTenant, deps.Audit))
+
+				operator.Get("/", ListJobs(deps.Jobs))
+				operator.Get("/{job}", GetJob(deps.Jobs))
+				operator.Post("/{job}/run", RunJob(deps.Jobs, deps.Audit))
+				operator.Post("/{job}/pause", PauseJob(deps.Jobs, deps.Audit))
+				operator.Post("/{job}/resume", ResumeJob(deps.Jobs, deps.Audit))
+			})
+		}
 	})
 }
+
+// traced wraps mw in its own span when tracing is enabled.
+func traced(deps Dependencies, name string, mw func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	if !deps.Tracing {
+		return mw
+	}
+	return tracing.WrapMiddleware(name, mw)
+}
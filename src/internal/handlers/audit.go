@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"golearn/src/api"
+	"golearn/src/internal/audit"
+	"golearn/src/internal/logging"
+	"golearn/src/internal/middleware"
+	"golearn/src/internal/tenant"
+	"golearn/src/internal/tools"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/schema"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// GetAuditLog lets admins search the audit log of their own tenant by user,
+// action and time range. User may be an ID or a current username, which
+// finds the account's entries from before any rename. Since and Until are
+// RFC 3339 timestamps.
+func GetAuditLog(auditLog *audit.Log, database tools.DatabaseInterface) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params = api.AuditLogParams{}
+		var decoder *schema.Decoder = schema.NewDecoder()
+		decoder.IgnoreUnknownKeys(true)
+		var err error
+
+		err = decoder.Decode(&params, r.URL.Query())
+		if err != nil {
+			api.BadRequestErrorHandler(w, r, err)
+			return
+		}
+
+		tenantID, err := tenant.MustFromContext(r.Context())
+		if err != nil {
+			logging.FromContext(r.Context()).Error(err)
+			api.InternalErrorHandler(w, r)
+			return
+		}
+
+		var filter = audit.Filter{Tenant: tenantID, User: params.User, Action: params.Action, Limit: params.Limit}
+		if params.User != "" {
+			loginDetails, err := database.GetUserLoginDetails(r.Context(), params.User)
+			if err == nil {
+				filter.User = loginDetails.UserID
+			} else if !errors.Is(err, tools.ErrUserNotFound) {
+				logging.FromContext(r.Context()).Error(err)
+				api.InternalErrorHandler(w, r)
+				return
+			}
+		}
+		if filter.Limit <= 0 {
+			filter.Limit = defaultAuditLimit
+		}
+		if filter.Limit > maxAuditLimit {
+			filter.Limit = maxAuditLimit
+		}
+		if filter.Since, err = parseOptionalTime("since", params.Since); err != nil {
+			api.BadRequestErrorHandler(w, r, err)
+			return
+		}
+		if filter.Until, err = parseOptionalTime("until", params.Until); err != nil {
+			api.BadRequestErrorHandler(w, r, err)
+			return
+		}
+
+		middleware.RecordAudit(r, auditLog, audit.Event{
+			Action:  audit.ActionAuditQuery,
+			Subject: filter.User,
+			Details: map[string]string{"action": params.Action, "since": params.Since, "until": params.Until},
+		})
+
+		var response = api.AuditLogResponse{
+			Code:       http.StatusOK,
+			ChainValid: true,
+			Entries:    []api.AuditEntry{},
+		}
+		var chainErr *audit.ChainError
+		if err = auditLog.Verify(); errors.As(err, &chainErr) {
+			response.ChainValid = false
+			response.ChainError = chainErr.Error()
+		}
+		for _, entry := range auditLog.Query(filter) {
+			response.Entries = append(response.Entries, api.AuditEntry{
+				Seq:       entry.Seq,
+				Time:      entry.Time.Format(time.RFC3339Nano),
+				Tenant:    entry.Tenant,
+				Action:    entry.Action,
+				Actor:     entry.Actor,
+				Subject:   entry.Subject,
+				Outcome:   entry.Outcome,
+				IP:        entry.IP,
+				RequestID: entry.RequestID,
+				Details:   entry.Details,
+				PrevHash:  entry.PrevHash,
+				Hash:      entry.Hash,
+			})
+		}
+
+		writeResponse(w, r, http.StatusOK, response)
+	}
+}
+
+func parseOptionalTime(name string, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be an RFC 3339 timestamp", name)
+	}
+	return parsed, nil
+}
+
+func formatInt(n int64) string {
+	return strconv.FormatInt(n, 10)
+}
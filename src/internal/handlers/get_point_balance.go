@@ -1,53 +1,67 @@
 package handlers
 
 import (
-	"encoding/json"
+	"errors"
 	"golearn/src/api"
+	"golearn/src/internal/audit"
+	"golearn/src/internal/logging"
+	"golearn/src/internal/middleware"
 	"golearn/src/internal/tools"
 	"net/http"
 
 	"github.com/gorilla/schema"
-	log "github.com/sirupsen/logrus"
 )
 
-func GetPointBalance(w http.ResponseWriter, r *http.Request) {
-	var params = api.PointBalanceParams{}
-	var decoder *schema.Decoder = schema.NewDecoder()
-	var err error
+func GetPointBalance(database tools.DatabaseInterface, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params = api.PointBalanceParams{}
+		var decoder *schema.Decoder = schema.NewDecoder()
+		decoder.IgnoreUnknownKeys(true)
+		var err error
 
-	err = decoder.Decode(&params, r.URL.Query())
+		err = decoder.Decode(&params, r.URL.Query())
 
-	if err != nil {
-		log.Error(err)
-		api.InternalErrorHandler(w)
-		return
-	}
+		if err != nil {
+			logging.FromContext(r.Context()).Error(err)
+			api.InternalErrorHandler(w, r)
+			return
+		}
 
-	var database *tools.DatabaseInterface
-	database, err = tools.NewDatabase()
-	if err != nil {
-		log.Error(err)
-		api.InternalErrorHandler(w)
-		return
-	}
+		// params.Username may be the ID or the username; the authenticated
+		// account is the same either way.
+		var loginDetails = middleware.LoginDetailsFromContext(r.Context())
+		var pointDetails *tools.PointDetails
+		var wallets []tools.Wallet
+		pointDetails, err = database.GetUserPointDetails(r.Context(), loginDetails.UserID)
+		if err == nil {
+			wallets, err = selectWallets(pointDetails.Wallets, params.Wallet)
+		}
+		var event = audit.Event{
+			Action:  audit.ActionBalanceRead,
+			Subject: loginDetails.UserID,
+			Outcome: audit.OutcomeOf(err),
+		}
+		if params.Wallet != "" {
+			event.Details = map[string]string{"wallet": params.Wallet}
+		}
+		middleware.RecordAudit(r, auditLog, event)
+		if errors.Is(err, tools.ErrWalletNotFound) {
+			api.RequestErrorHandler(w, r, err)
+			return
+		}
+		if err != nil {
+			logging.FromContext(r.Context()).Error(err)
+			api.InternalErrorHandler(w, r)
+			return
+		}
 
-	var pointDetails *tools.PointDetails = (*database).GetUserPointDetails(params.Username)
-	if pointDetails == nil {
-		log.Error(err)
-		api.InternalErrorHandler(w)
-		return
-	}
-
-	var response = api.PointBalanceResponse{
-		Code:    http.StatusOK,
-		Balance: (*pointDetails).Balance,
-	}
+		var response = api.PointBalanceResponse{
+			Code:     http.StatusOK,
+			UserID:   pointDetails.UserID,
+			Username: pointDetails.Username,
+			Wallets:  walletBalances(wallets, pointDetails.Holds),
+		}
 
-	w.Header().Set("Content-Type", "application/json")
-	err = json.NewEncoder(w).Encode(response)
-	if err != nil {
-		log.Error(err)
-		api.InternalErrorHandler(w)
-		return
+		writeResponse(w, r, http.StatusOK, response)
 	}
 }
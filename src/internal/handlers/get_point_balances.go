@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golearn/src/api"
+	"golearn/src/internal/audit"
+	"golearn/src/internal/config"
+	"golearn/src/internal/logging"
+	"golearn/src/internal/middleware"
+	"golearn/src/internal/tools"
+	"net/http"
+	"sync"
+)
+
+var ErrorEmptyBatch = errors.New("usernames must not be empty")
+
+// GetPointBalances looks up the balances of many users at once, by ID or
+// username, running at most cfg.BatchWorkers lookups in parallel. Results
+// keep the order of the request and failures are reported per user, so one
+// unknown user, or one without the requested wallet, does not fail the
+// batch.
+func GetPointBalances(database tools.DatabaseInterface, auditLog *audit.Log, cfg config.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params = api.BatchBalanceParams{}
+		var err error
+
+		var decoder = json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&params)
+		if err != nil {
+			api.BadRequestErrorHandler(w, r, fmt.Errorf("invalid request body: %w", err))
+			return
+		}
+		if len(params.Usernames) == 0 {
+			api.BadRequestErrorHandler(w, r, ErrorEmptyBatch)
+			return
+		}
+		if len(params.Usernames) > cfg.BatchMaxSize {
+			api.BadRequestErrorHandler(w, r, fmt.Errorf("at most %d usernames may be requested at once", cfg.BatchMaxSize))
+			return
+		}
+
+		var results = make([]api.BatchBalanceResult, len(params.Usernames))
+		var sem = make(chan struct{}, cfg.BatchWorkers)
+		var wg = sync.WaitGroup{}
+		var ctx = r.Context()
+		var logger = logging.FromContext(ctx)
+
+		for i, username := range params.Usernames {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				results[i] = batchFailure(username, ctx.Err())
+				continue
+			}
+
+			wg.Add(1)
+			go func(i int, username string) {
+				defer wg.Done()
+				defer func() { <-sem }()
+
+				if username == "" {
+					results[i] = api.BatchBalanceResult{Username: username, Code: http.StatusBadRequest, Error: "username must not be empty"}
+					return
+				}
+
+				pointDetails, err := database.GetUserPointDetails(ctx, username)
+				var subject = username
+				var wallets []tools.Wallet
+				if pointDetails != nil {
+					subject = pointDetails.UserID
+					wallets, err = selectWallets(pointDetails.Wallets, params.Wallet)
+				}
+				var details = map[string]string{"batch": "true"}
+				if params.Wallet != "" {
+					details["wallet"] = params.Wallet
+				}
+				middleware.RecordAudit(r, auditLog, audit.Event{
+					Action:  audit.ActionBalanceRead,
+					Subject: subject,
+					Outcome: audit.OutcomeOf(err),
+					Details: details,
+				})
+				if err != nil {
+					if !errors.Is(err, tools.ErrUserNotFound) && !errors.Is(err, tools.ErrWalletNotFound) {
+						logger.WithField("username", username).Error(err)
+					}
+					results[i] = batchFailure(username, err)
+					return
+				}
+				results[i] = api.BatchBalanceResult{Username: username, UserID: pointDetails.UserID, Code: http.StatusOK, Wallets: walletBalances(wallets, pointDetails.Holds)}
+			}(i, username)
+		}
+		wg.Wait()
+
+		var response = api.BatchBalanceResponse{
+			Code:    http.StatusOK,
+			Results: results,
+		}
+
+		writeResponse(w, r, http.StatusOK, response)
+	}
+}
+
+func batchFailure(username string, err error) api.BatchBalanceResult {
+	if errors.Is(err, tools.ErrUserNotFound) || errors.Is(err, tools.ErrWalletNotFound) {
+		return api.BatchBalanceResult{Username: username, Code: http.StatusNotFound, Error: err.Error()}
+	}
+	return api.BatchBalanceResult{Username: username, Code: http.StatusInternalServerError, Error: http.StatusText(http.StatusInternalServerError)}
+}
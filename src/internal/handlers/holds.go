@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"golearn/src/api"
+	"golearn/src/internal/audit"
+	"golearn/src/internal/config"
+	"golearn/src/internal/logging"
+	"golearn/src/internal/middleware"
+	"golearn/src/internal/tools"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/gorilla/schema"
+)
+
+var ErrorInvalidTTL = errors.New("ttl must be a positive duration")
+
+// PlaceHold reserves part of a wallet of the authenticated account. The
+// reserved units stay in the ledger balance but cannot be debited or held
+// again until the hold is captured, released or expires.
+func PlaceHold(database tools.DatabaseInterface, auditLog *audit.Log, holds config.Holds) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params = api.HoldParams{}
+		var decoder *schema.Decoder = schema.NewDecoder()
+		decoder.IgnoreUnknownKeys(true)
+		var err error
+
+		err = r.ParseForm()
+		if err == nil {
+			err = decoder.Decode(&params, r.Form)
+		}
+		if err != nil {
+			api.BadRequestErrorHandler(w, r, err)
+			return
+		}
+
+		if params.Amount <= 0 {
+			api.BadRequestErrorHandler(w, r, ErrorInvalidAmount)
+			return
+		}
+		if params.Wallet == "" {
+			params.Wallet = tools.DefaultWallet
+		}
+		var ttl = holds.DefaultTTL.Duration
+		if params.TTL != "" {
+			ttl, err = time.ParseDuration(params.TTL)
+			if err != nil || ttl <= 0 {
+				api.BadRequestErrorHandler(w, r, ErrorInvalidTTL)
+				return
+			}
+		}
+		if ttl > holds.MaxTTL.Duration {
+			api.BadRequestErrorHandler(w, r, fmt.Errorf("ttl must not exceed %s", holds.MaxTTL.Duration))
+			return
+		}
+
+		var loginDetails = middleware.LoginDetailsFromContext(r.Context())
+		hold, pointDetails, err := database.PlaceHold(r.Context(), loginDetails.UserID, params.Wallet, params.Amount, ttl)
+
+		var event = audit.Event{
+			Action:  audit.ActionHoldPlace,
+			Subject: loginDetails.UserID,
+			Outcome: audit.OutcomeOf(err),
+			Details: map[string]string{"wallet": params.Wallet, "amount": formatInt(params.Amount), "ttl": ttl.String()},
+		}
+		if err != nil {
+			event.Details["error"] = err.Error()
+		} else {
+			event.Details["hold"] = hold.ID
+		}
+		middleware.RecordAudit(r, auditLog, event)
+		if writeHoldError(w, r, err) {
+			return
+		}
+
+		writeResponse(w, r, http.StatusOK, holdResponse(pointDetails, *hold, holdStatus(*hold)))
+	}
+}
+
+// ListHolds lists the active holds of the authenticated account.
+func ListHolds(database tools.DatabaseInterface, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var loginDetails = middleware.LoginDetailsFromContext(r.Context())
+		pointDetails, err := database.GetUserPointDetails(r.Context(), loginDetails.UserID)
+		middleware.RecordAudit(r, auditLog, audit.Event{
+			Action:  audit.ActionBalanceRead,
+			Subject: loginDetails.UserID,
+			Outcome: audit.OutcomeOf(err),
+			Details: map[string]string{"holds": "true"},
+		})
+		if err != nil {
+			logging.FromContext(r.Context()).Error(err)
+			api.InternalErrorHandler(w, r)
+			return
+		}
+
+		var response = api.HoldListResponse{
+			Code:     http.StatusOK,
+			UserID:   pointDetails.UserID,
+			Username: pointDetails.Username,
+			Holds:    make([]api.HoldInfo, 0, len(pointDetails.Holds)),
+		}
+		for _, hold := range pointDetails.Holds {
+			response.Holds = append(response.Holds, holdInfo(hold, api.HoldActive))
+		}
+		writeResponse(w, r, http.StatusOK, response)
+	}
+}
+
+// CaptureHold debits the wallet by part or all of a hold. What is left of a
+// partly captured hold stays reserved.
+func CaptureHold(database tools.DatabaseInterface, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params = api.CaptureHoldParams{}
+		var decoder *schema.Decoder = schema.NewDecoder()
+		decoder.IgnoreUnknownKeys(true)
+		var err error
+
+		err = r.ParseForm()
+		if err == nil {
+			err = decoder.Decode(&params, r.Form)
+		}
+		if err != nil {
+			api.BadRequestErrorHandler(w, r, err)
+			return
+		}
+		if params.Amount < 0 {
+			api.BadRequestErrorHandler(w, r, ErrorInvalidAmount)
+			return
+		}
+
+		var loginDetails = middleware.LoginDetailsFromContext(r.Context())
+		var holdID = chi.URLParam(r, "hold")
+		hold, pointDetails, err := database.CaptureHold(r.Context(), loginDetails.UserID, holdID, params.Amount)
+
+		var event = audit.Event{
+			Action:  audit.ActionHoldCapture,
+			Subject: loginDetails.UserID,
+			Outcome: audit.OutcomeOf(err),
+			Details: map[string]string{"hold": holdID},
+		}
+		if params.Amount > 0 {
+			event.Details["amount"] = formatInt(params.Amount)
+		}
+		if err != nil {
+			event.Details["error"] = err.Error()
+		} else {
+			event.Details["wallet"] = hold.Wallet
+			event.Details["captured"] = formatInt(hold.Captured)
+			event.Details["remaining"] = formatInt(hold.Amount)
+		}
+		middleware.RecordAudit(r, auditLog, event)
+		if writeHoldError(w, r, err) {
+			return
+		}
+
+		writeResponse(w, r, http.StatusOK, holdResponse(pointDetails, *hold, holdStatus(*hold)))
+	}
+}
+
+// ReleaseHold ends a hold without debiting anything.
+func ReleaseHold(database tools.DatabaseInterface, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var loginDetails = middleware.LoginDetailsFromContext(r.Context())
+		var holdID = chi.URLParam(r, "hold")
+		hold, pointDetails, err := database.ReleaseHold(r.Context(), loginDetails.UserID, holdID)
+
+		var event = audit.Event{
+			Action:  audit.ActionHoldRelease,
+			Subject: loginDetails.UserID,
+			Outcome: audit.OutcomeOf(err),
+			Details: map[string]string{"hold": holdID},
+		}
+		if err != nil {
+			event.Details["error"] = err.Error()
+		} else {
+			event.Details["wallet"] = hold.Wallet
+			event.Details["amount"] = formatInt(hold.Amount)
+		}
+		middleware.RecordAudit(r, auditLog, event)
+		if writeHoldError(w, r, err) {
+			return
+		}
+
+		writeResponse(w, r, http.StatusOK, holdResponse(pointDetails, *hold, api.HoldReleased))
+	}
+}
+
+// writeHoldError answers a failed hold operation and reports whether it did.
+func writeHoldError(w http.ResponseWriter, r *http.Request, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, tools.ErrHoldNotFound), errors.Is(err, tools.ErrWalletNotFound):
+		api.RequestErrorHandler(w, r, err)
+	case errors.Is(err, tools.ErrInvalidHoldAmount):
+		api.BadRequestErrorHandler(w, r, err)
+	case errors.Is(err, tools.ErrInsufficientFunds), errors.Is(err, tools.ErrHoldExceeded):
+		api.ConflictErrorHandler(w, r, err)
+	default:
+		logging.FromContext(r.Context()).Error(err)
+		api.InternalErrorHandler(w, r)
+	}
+	return true
+}
+
+// holdStatus is the status of a hold that was placed or captured: active
+// while it still reserves something.
+func holdStatus(hold tools.Hold) string {
+	if hold.Amount > 0 {
+		return api.HoldActive
+	}
+	return api.HoldCaptured
+}
+
+func holdResponse(pointDetails *tools.PointDetails, hold tools.Hold, status string) api.HoldResponse {
+	var response = api.HoldResponse{
+		Code:     http.StatusOK,
+		UserID:   pointDetails.UserID,
+		Username: pointDetails.Username,
+		Hold:     holdInfo(hold, status),
+	}
+	if wallets, err := selectWallets(pointDetails.Wallets, hold.Wallet); err == nil {
+		response.Wallet = walletBalances(wallets, pointDetails.Holds)[0]
+	}
+	return response
+}
+
+func holdInfo(hold tools.Hold, status string) api.HoldInfo {
+	return api.HoldInfo{
+		ID:        hold.ID,
+		Wallet:    hold.Wallet,
+		Amount:    hold.Amount,
+		Captured:  hold.Captured,
+		Status:    status,
+		CreatedAt: hold.CreatedAt.Format(time.RFC3339Nano),
+		ExpiresAt: hold.ExpiresAt.Format(time.RFC3339Nano),
+	}
+}
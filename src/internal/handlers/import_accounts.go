@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"golearn/src/api"
+	"golearn/src/internal/audit"
+	"golearn/src/internal/bulk"
+	"golearn/src/internal/logging"
+	"golearn/src/internal/middleware"
+	"golearn/src/internal/tools"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/schema"
+)
+
+// maxImportErrors caps the rejected rows listed in an import response.
+const maxImportErrors = 1000
+
+var ErrorUnknownFormat = errors.New("format must be csv or ndjson, as a query parameter or Content-Type")
+
+// ImportAccounts streams CSV or NDJSON accounts from the request body into
+// the store, or only validates them when dry_run is set. Invalid rows are
+// skipped and listed in the response.
+func ImportAccounts(database tools.DatabaseInterface, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params = api.ImportParams{}
+		var decoder *schema.Decoder = schema.NewDecoder()
+		decoder.IgnoreUnknownKeys(true)
+		var err error
+
+		err = decoder.Decode(&params, r.URL.Query())
+		if err != nil {
+			api.BadRequestErrorHandler(w, r, err)
+			return
+		}
+
+		format, err := requestFormat(params.Format, r.Header.Get("Content-Type"))
+		if err != nil {
+			api.BadRequestErrorHandler(w, r, err)
+			return
+		}
+
+		// Large imports outlive the server's default timeouts.
+		var rc = http.NewResponseController(w)
+		rc.SetReadDeadline(time.Time{})
+		rc.SetWriteDeadline(time.Time{})
+
+		reader, err := bulk.NewReader(r.Body, format)
+		if err != nil {
+			api.BadRequestErrorHandler(w, r, err)
+			return
+		}
+
+		var response = api.ImportResponse{
+			Code:   http.StatusOK,
+			DryRun: params.DryRun,
+			Errors: []api.ImportRowError{},
+		}
+		var logger = logging.FromContext(r.Context())
+
+		result, err := bulk.Import(r.Context(), database, reader, bulk.ImportOptions{
+			DryRun: params.DryRun,
+			OnError: func(rowErr *bulk.RowError) {
+				if len(response.Errors) == maxImportErrors {
+					response.ErrorsTruncated = true
+					return
+				}
+				response.Errors = append(response.Errors, api.ImportRowError{
+					Line:     rowErr.Line,
+					Username: rowErr.Username,
+					Error:    rowErr.Err.Error(),
+				})
+			},
+		})
+		response.Rows = result.Rows
+		response.Imported = result.Imported
+		response.Failed = result.Failed
+
+		middleware.RecordAudit(r, auditLog, audit.Event{
+			Action:  audit.ActionAccountImport,
+			Outcome: audit.OutcomeOf(err),
+			Details: map[string]string{
+				"format":   format,
+				"dry_run":  strconv.FormatBool(params.DryRun),
+				"rows":     strconv.Itoa(result.Rows),
+				"imported": strconv.Itoa(result.Imported),
+				"failed":   strconv.Itoa(result.Failed),
+			},
+		})
+
+		if err != nil {
+			logger.WithField("rows", result.Rows).Error(err)
+			api.BadRequestErrorHandler(w, r, fmt.Errorf("import stopped after %d rows, %d imported: %w", result.Rows, result.Imported, err))
+			return
+		}
+
+		writeResponse(w, r, http.StatusOK, response)
+	}
+}
+
+// requestFormat picks the bulk format from the format parameter or, when
+// that is empty, from contentType.
+func requestFormat(format string, contentType string) (string, error) {
+	if format != "" {
+		return bulk.ParseFormat(format)
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/csv":
+		return bulk.FormatCSV, nil
+	case "application/x-ndjson", "application/jsonl":
+		return bulk.FormatNDJSON, nil
+	}
+	return "", ErrorUnknownFormat
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"errors"
+	"golearn/src/api"
+	"golearn/src/internal/audit"
+	"golearn/src/internal/logging"
+	"golearn/src/internal/middleware"
+	"golearn/src/internal/scheduler"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+// ListJobs describes every background job.
+func ListJobs(jobs *scheduler.Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var statuses = jobs.Jobs()
+		var response = api.JobListResponse{
+			Code: http.StatusOK,
+			Jobs: make([]api.JobInfo, 0, len(statuses)),
+		}
+		for _, status := range statuses {
+			response.Jobs = append(response.Jobs, jobInfo(status))
+		}
+		writeResponse(w, r, http.StatusOK, response)
+	}
+}
+
+// GetJob describes the job named in the path with its run history.
+func GetJob(jobs *scheduler.Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status, history, err := jobs.Job(chi.URLParam(r, "job"))
+		if writeJobError(w, r, err) {
+			return
+		}
+		writeResponse(w, r, http.StatusOK, jobResponse(http.StatusOK, status, history))
+	}
+}
+
+// RunJob starts the job named in the path now, even if it is paused, and
+// answers 202 without waiting for it to finish.
+func RunJob(jobs *scheduler.Scheduler, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var name = chi.URLParam(r, "job")
+		var err = jobs.Trigger(name)
+		middleware.RecordAudit(r, auditLog, audit.Event{
+			Action:  audit.ActionJobRun,
+			Outcome: audit.OutcomeOf(err),
+			Details: map[string]string{"job": name},
+		})
+		if writeJobError(w, r, err) {
+			return
+		}
+
+		status, history, err := jobs.Job(name)
+		if writeJobError(w, r, err) {
+			return
+		}
+		writeResponse(w, r, http.StatusAccepted, jobResponse(http.StatusAccepted, status, history))
+	}
+}
+
+// PauseJob stops the scheduled runs of the job named in the path.
+func PauseJob(jobs *scheduler.Scheduler, auditLog *audit.Log) http.HandlerFunc {
+	return changeJob(jobs, auditLog, audit.ActionJobPause, jobs.Pause)
+}
+
+// ResumeJob schedules the job named in the path again.
+func ResumeJob(jobs *scheduler.Scheduler, auditLog *audit.Log) http.HandlerFunc {
+	return changeJob(jobs, auditLog, audit.ActionJobResume, jobs.Resume)
+}
+
+func changeJob(jobs *scheduler.Scheduler, auditLog *audit.Log, action string, change func(name string) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var name = chi.URLParam(r, "job")
+		var err = change(name)
+		middleware.RecordAudit(r, auditLog, audit.Event{
+			Action:  action,
+			Outcome: audit.OutcomeOf(err),
+			Details: map[string]string{"job": name},
+		})
+		if writeJobError(w, r, err) {
+			return
+		}
+
+		status, history, err := jobs.Job(name)
+		if writeJobError(w, r, err) {
+			return
+		}
+		writeResponse(w, r, http.StatusOK, jobResponse(http.StatusOK, status, history))
+	}
+}
+
+// writeJobError answers a failed job request and reports whether it did.
+func writeJobError(w http.ResponseWriter, r *http.Request, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, scheduler.ErrJobNotFound):
+		api.RequestErrorHandler(w, r, err)
+	case errors.Is(err, scheduler.ErrJobRunning), errors.Is(err, scheduler.ErrStopped):
+		api.ConflictErrorHandler(w, r, err)
+	default:
+		logging.FromContext(r.Context()).Error(err)
+		api.InternalErrorHandler(w, r)
+	}
+	return true
+}
+
+func jobResponse(code int, status scheduler.Status, history []scheduler.Run) api.JobResponse {
+	var response = api.JobResponse{
+		Code:    code,
+		Job:     jobInfo(status),
+		History: make([]api.JobRun, 0, len(history)),
+	}
+	for _, run := range history {
+		response.History = append(response.History, jobRun(run))
+	}
+	return response
+}
+
+func jobInfo(status scheduler.Status) api.JobInfo {
+	var info = api.JobInfo{
+		Name:     status.Name,
+		Schedule: status.Schedule,
+		Paused:   status.Paused,
+		Running:  status.Running,
+	}
+	if !status.NextRun.IsZero() {
+		info.NextRun = status.NextRun.Format(time.RFC3339Nano)
+	}
+	if status.LastRun != nil {
+		var last = jobRun(*status.LastRun)
+		info.LastRun = &last
+	}
+	return info
+}
+
+func jobRun(run scheduler.Run) api.JobRun {
+	var result = api.JobRun{
+		Trigger:   run.Trigger,
+		StartedAt: run.StartedAt.Format(time.RFC3339Nano),
+		Duration:  run.Duration.String(),
+		Outcome:   run.Outcome,
+	}
+	if run.Err != nil {
+		result.Error = run.Err.Error()
+	}
+	return result
+}
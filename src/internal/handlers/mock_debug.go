@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"golearn/src/api"
+	"golearn/src/internal/logging"
+	"golearn/src/internal/tools"
+	"net/http"
+)
+
+// GetMockScenario returns the scenario the mock database is running.
+func GetMockScenario(control tools.MockController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, r, control.Scenario())
+	}
+}
+
+// SetMockScenario replaces the scenario of the mock database with the one
+// in the request body.
+func SetMockScenario(control tools.MockController) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var scenario tools.MockScenario
+		var buf bytes.Buffer
+		if _, err := buf.ReadFrom(r.Body); err != nil {
+			api.BadRequestErrorHandler(w, r, err)
+			return
+		}
+		var decoder = json.NewDecoder(&buf)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&scenario); err != nil {
+			api.BadRequestErrorHandler(w, r, err)
+			return
+		}
+
+		if err := control.SetScenario(scenario); err != nil {
+			api.BadRequestErrorHandler(w, r, err)
+			return
+		}
+		logging.FromContext(r.Context()).Warn("mock database scenario replaced")
+		writeJSON(w, r, control.Scenario())
+	}
+}
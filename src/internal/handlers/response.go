@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"golearn/src/api"
+	"golearn/src/internal/logging"
+	"net/http"
+)
+
+// writeResponse answers with response in the format r negotiated.
+func writeResponse(w http.ResponseWriter, r *http.Request, code int, response any) {
+	var err = api.Render(w, r, code, response)
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+	}
+}
+
+// writeJSON answers with response as JSON whatever r asked for.
+func writeJSON(w http.ResponseWriter, r *http.Request, response any) {
+	var err = api.Write(w, api.FormatJSON, http.StatusOK, response)
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+	}
+}
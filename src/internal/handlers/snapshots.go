@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"errors"
+	"golearn/src/api"
+	"golearn/src/internal/audit"
+	"golearn/src/internal/logging"
+	"golearn/src/internal/middleware"
+	"golearn/src/internal/snapshot"
+	"golearn/src/internal/tools"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/gorilla/schema"
+)
+
+// ListSnapshots lists the archives in the snapshot directory, newest first.
+func ListSnapshots(snapshots *snapshot.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		infos, err := snapshots.List()
+		if err != nil {
+			logging.FromContext(r.Context()).Error(err)
+			api.InternalErrorHandler(w, r)
+			return
+		}
+
+		var response = api.SnapshotListResponse{
+			Code:      http.StatusOK,
+			Snapshots: make([]api.SnapshotInfo, 0, len(infos)),
+		}
+		for _, info := range infos {
+			response.Snapshots = append(response.Snapshots, snapshotInfo(info))
+		}
+		writeResponse(w, r, http.StatusOK, response)
+	}
+}
+
+// CreateSnapshot archives every tenant's accounts.
+func CreateSnapshot(snapshots *snapshot.Store, database tools.DatabaseInterface, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params = api.CreateSnapshotParams{}
+		var decoder *schema.Decoder = schema.NewDecoder()
+		decoder.IgnoreUnknownKeys(true)
+		var err error
+
+		err = decoder.Decode(&params, r.URL.Query())
+		if err != nil {
+			api.BadRequestErrorHandler(w, r, err)
+			return
+		}
+
+		info, err := snapshots.Create(r.Context(), database, params.Label)
+		middleware.RecordAudit(r, auditLog, audit.Event{
+			Action:  audit.ActionSnapshotCreate,
+			Outcome: audit.OutcomeOf(err),
+			Details: map[string]string{"snapshot": info.Name, "accounts": strconv.Itoa(info.Accounts)},
+		})
+		if errors.Is(err, snapshot.ErrInvalidLabel) {
+			api.BadRequestErrorHandler(w, r, err)
+			return
+		}
+		if err != nil {
+			logging.FromContext(r.Context()).Error(err)
+			api.InternalErrorHandler(w, r)
+			return
+		}
+
+		writeResponse(w, r, http.StatusOK, api.SnapshotResponse{Code: http.StatusOK, Snapshot: snapshotInfo(info)})
+	}
+}
+
+// RestoreSnapshot replaces the contents of the store with the archive named
+// in the path, once the whole archive has been validated.
+func RestoreSnapshot(snapshots *snapshot.Store, database tools.DatabaseInterface, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var name = chi.URLParam(r, "name")
+
+		restored, backup, err := snapshots.Restore(r.Context(), database, name)
+		middleware.RecordAudit(r, auditLog, audit.Event{
+			Action:  audit.ActionSnapshotRestore,
+			Outcome: audit.OutcomeOf(err),
+			Details: map[string]string{"snapshot": name, "backup": backup.Name},
+		})
+		switch {
+		case errors.Is(err, snapshot.ErrNotFound), errors.Is(err, snapshot.ErrInvalidName):
+			api.RequestErrorHandler(w, r, err)
+			return
+		case err != nil && backup.Name == "":
+			// The archive was rejected before anything changed.
+			logging.FromContext(r.Context()).Error(err)
+			api.ConflictErrorHandler(w, r, err)
+			return
+		case err != nil:
+			logging.FromContext(r.Context()).Error(err)
+			api.InternalErrorHandler(w, r)
+			return
+		}
+
+		writeResponse(w, r, http.StatusOK, api.RestoreResponse{
+			Code:     http.StatusOK,
+			Restored: snapshotInfo(restored),
+			Backup:   snapshotInfo(backup),
+		})
+	}
+}
+
+func snapshotInfo(info snapshot.Info) api.SnapshotInfo {
+	var result = api.SnapshotInfo{
+		Name:      info.Name,
+		Label:     info.Label,
+		Version:   info.Version,
+		Tenants:   info.Tenants,
+		Accounts:  info.Accounts,
+		SizeBytes: info.Size,
+	}
+	if !info.CreatedAt.IsZero() {
+		result.CreatedAt = info.CreatedAt.Format(time.RFC3339Nano)
+	}
+	if info.Err != nil {
+		result.Error = info.Err.Error()
+	}
+	return result
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"golearn/src/api"
+	"golearn/src/internal/audit"
+	"golearn/src/internal/logging"
+	"golearn/src/internal/middleware"
+	"golearn/src/internal/statement"
+	"golearn/src/internal/tools"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/gorilla/schema"
+)
+
+// GetOwnStatement downloads the authenticated account's statement for the
+// month in the path, as HTML unless the format parameter asks for text or
+// json.
+func GetOwnStatement(database tools.DatabaseInterface, auditLog *audit.Log, now func() time.Time) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		getStatement(w, r, database, auditLog, now, middleware.LoginDetailsFromContext(r.Context()).UserID)
+	}
+}
+
+// GetStatement lets admins download the statement of any account of their
+// tenant.
+func GetStatement(database tools.DatabaseInterface, auditLog *audit.Log, now func() time.Time) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		getStatement(w, r, database, auditLog, now, chi.URLParam(r, "user"))
+	}
+}
+
+func getStatement(w http.ResponseWriter, r *http.Request, database tools.DatabaseInterface, auditLog *audit.Log, now func() time.Time, user string) {
+	var params = api.StatementParams{}
+	var decoder *schema.Decoder = schema.NewDecoder()
+	decoder.IgnoreUnknownKeys(true)
+
+	var err = decoder.Decode(&params, r.URL.Query())
+	if err != nil {
+		api.BadRequestErrorHandler(w, r, err)
+		return
+	}
+	var format = statement.FormatHTML
+	if params.Format != "" {
+		if format, err = statement.ParseFormat(params.Format); err != nil {
+			api.BadRequestErrorHandler(w, r, err)
+			return
+		}
+	}
+	period, err := statement.ParsePeriod(chi.URLParam(r, "period"))
+	if err != nil {
+		api.BadRequestErrorHandler(w, r, err)
+		return
+	}
+
+	account, err := database.GetAccount(r.Context(), user)
+	var result api.Statement
+	var subject = user
+	if account != nil {
+		subject = account.ID
+		result, err = statement.Build(*account, period, now())
+	}
+	middleware.RecordAudit(r, auditLog, audit.Event{
+		Action:  audit.ActionStatementRead,
+		Subject: subject,
+		Outcome: audit.OutcomeOf(err),
+		Details: map[string]string{"period": period.String(), "format": format},
+	})
+	if writeStatementError(w, r, err) {
+		return
+	}
+
+	if format == statement.FormatJSON {
+		writeJSON(w, r, api.StatementResponse{Code: http.StatusOK, Statement: result})
+		return
+	}
+	// Render to a buffer first so that a template error is still a 500.
+	var body bytes.Buffer
+	if err = statement.Render(&body, format, result); err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.InternalErrorHandler(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", statement.ContentType(format))
+	w.Header().Set("Content-Disposition", `attachment; filename="`+statement.Filename(result, format)+`"`)
+	body.WriteTo(w)
+}
+
+// ListStatements returns the statements of every account of the tenant for
+// the month in the path, so that they can be produced in bulk.
+func ListStatements(database tools.DatabaseInterface, auditLog *audit.Log, now func() time.Time) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		period, err := statement.ParsePeriod(chi.URLParam(r, "period"))
+		if err != nil {
+			api.BadRequestErrorHandler(w, r, err)
+			return
+		}
+
+		var at = now()
+		var response = api.StatementListResponse{
+			Code:       http.StatusOK,
+			Period:     period.String(),
+			Statements: []api.Statement{},
+		}
+		if !period.Start().Before(at) {
+			err = statement.ErrFuturePeriod
+		} else {
+			err = database.ListAccounts(r.Context(), func(account tools.Account) error {
+				result, err := statement.Build(account, period, at)
+				if err != nil {
+					return err
+				}
+				response.Statements = append(response.Statements, result)
+				return nil
+			})
+		}
+		middleware.RecordAudit(r, auditLog, audit.Event{
+			Action:  audit.ActionStatementRead,
+			Outcome: audit.OutcomeOf(err),
+			Details: map[string]string{"period": period.String(), "accounts": strconv.Itoa(len(response.Statements))},
+		})
+		if writeStatementError(w, r, err) {
+			return
+		}
+		writeResponse(w, r, http.StatusOK, response)
+	}
+}
+
+// writeStatementError answers a failed statement request and reports
+// whether it did.
+func writeStatementError(w http.ResponseWriter, r *http.Request, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, tools.ErrUserNotFound):
+		api.RequestErrorHandler(w, r, err)
+	case errors.Is(err, statement.ErrFuturePeriod):
+		api.BadRequestErrorHandler(w, r, err)
+	default:
+		logging.FromContext(r.Context()).Error(err)
+		api.InternalErrorHandler(w, r)
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"errors"
+	"golearn/src/api"
+	"golearn/src/internal/audit"
+	"golearn/src/internal/logging"
+	"golearn/src/internal/middleware"
+	"golearn/src/internal/tools"
+	"net/http"
+
+	"github.com/gorilla/schema"
+)
+
+var ErrorInvalidAmount = errors.New("amount must be greater than zero")
+
+func CreditPointBalance(database tools.DatabaseInterface, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		updatePointBalance(w, r, database, auditLog, 1)
+	}
+}
+
+func DebitPointBalance(database tools.DatabaseInterface, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		updatePointBalance(w, r, database, auditLog, -1)
+	}
+}
+
+func updatePointBalance(w http.ResponseWriter, r *http.Request, database tools.DatabaseInterface, auditLog *audit.Log, sign int64) {
+	var params = api.PointUpdateParams{}
+	var decoder *schema.Decoder = schema.NewDecoder()
+	decoder.IgnoreUnknownKeys(true)
+	var err error
+
+	err = r.ParseForm()
+	if err == nil {
+		err = decoder.Decode(&params, r.Form)
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.BadRequestErrorHandler(w, r, err)
+		return
+	}
+
+	if params.Amount <= 0 {
+		api.BadRequestErrorHandler(w, r, ErrorInvalidAmount)
+		return
+	}
+	if params.Wallet == "" {
+		params.Wallet = tools.DefaultWallet
+	}
+
+	var loginDetails = middleware.LoginDetailsFromContext(r.Context())
+	var pointDetails *tools.PointDetails
+	var wallets []tools.Wallet
+	pointDetails, err = database.UpdateUserBalance(r.Context(), loginDetails.UserID, params.Wallet, sign*params.Amount)
+	if err == nil {
+		wallets, err = selectWallets(pointDetails.Wallets, params.Wallet)
+	}
+
+	var event = audit.Event{
+		Action:  audit.ActionBalanceCredit,
+		Subject: loginDetails.UserID,
+		Outcome: audit.OutcomeOf(err),
+		Details: map[string]string{"wallet": params.Wallet, "amount": formatInt(params.Amount)},
+	}
+	if sign < 0 {
+		event.Action = audit.ActionBalanceDebit
+	}
+	if err != nil {
+		event.Details["error"] = err.Error()
+	} else {
+		event.Details["currency"] = wallets[0].Currency
+		event.Details["balance"] = formatInt(wallets[0].Balance)
+	}
+	middleware.RecordAudit(r, auditLog, event)
+	if errors.Is(err, tools.ErrWalletNotFound) {
+		api.RequestErrorHandler(w, r, err)
+		return
+	}
+	if errors.Is(err, tools.ErrInsufficientFunds) || errors.Is(err, tools.ErrBalanceOverflow) {
+		api.ConflictErrorHandler(w, r, err)
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error(err)
+		api.InternalErrorHandler(w, r)
+		return
+	}
+
+	var response = api.PointBalanceResponse{
+		Code:     http.StatusOK,
+		UserID:   pointDetails.UserID,
+		Username: pointDetails.Username,
+		Wallets:  walletBalances(wallets, pointDetails.Holds),
+	}
+
+	writeResponse(w, r, http.StatusOK, response)
+}
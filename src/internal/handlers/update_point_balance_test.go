@@ -0,0 +1,64 @@
+package handlers_test
+
+import (
+	"context"
+	"golearn/src/apitest"
+	"math"
+	"net/http"
+	"testing"
+)
+
+func TestUpdatePointBalance(t *testing.T) {
+	var tests = []struct {
+		name     string
+		admin    bool
+		balance  int64
+		credit   bool
+		amount   int64
+		wantCode int
+		// The points balance afterwards.
+		want int64
+	}{
+		{name: "debit", balance: 50, amount: 20, wantCode: http.StatusOK, want: 30},
+		{name: "debit beyond balance", balance: 50, amount: 80, wantCode: http.StatusConflict, want: 50},
+		{name: "credit by a user", balance: 50, credit: true, amount: 20, wantCode: http.StatusForbidden, want: 50},
+		{name: "credit by an admin", admin: true, balance: 50, credit: true, amount: 20, wantCode: http.StatusOK, want: 70},
+		{name: "credit overflow", admin: true, balance: math.MaxInt64 - 10, credit: true, amount: 11, wantCode: http.StatusConflict, want: math.MaxInt64 - 10},
+		{name: "credit to the limit", admin: true, balance: math.MaxInt64 - 10, credit: true, amount: 10, wantCode: http.StatusOK, want: math.MaxInt64},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var srv = apitest.New(t, apitest.Options{})
+			var user = apitest.User{Username: "alice", Balance: test.balance}
+			if test.admin {
+				user = srv.CreateAdmin(user)
+			} else {
+				user = srv.CreateUser(user)
+			}
+			var client = srv.Client(user)
+			var ctx = context.Background()
+
+			var err error
+			if test.credit {
+				_, err = client.Credit(ctx, "points", test.amount)
+			} else {
+				_, err = client.Debit(ctx, "points", test.amount)
+			}
+			if test.wantCode == http.StatusOK {
+				if err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				apitest.AssertError(t, err, test.wantCode)
+			}
+
+			balance, err := client.GetPointBalance(ctx, "points")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := balance.Wallets[0].Balance; got != test.want {
+				t.Errorf("balance = %d, want %d", got, test.want)
+			}
+		})
+	}
+}
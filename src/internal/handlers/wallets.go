@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"errors"
+	"golearn/src/api"
+	"golearn/src/internal/audit"
+	"golearn/src/internal/logging"
+	"golearn/src/internal/middleware"
+	"golearn/src/internal/tools"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/gorilla/schema"
+)
+
+// OpenWallet lets admins add an empty wallet in a new currency to an
+// account, found by ID or username.
+func OpenWallet(database tools.DatabaseInterface, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params = api.OpenWalletParams{}
+		var decoder *schema.Decoder = schema.NewDecoder()
+		decoder.IgnoreUnknownKeys(true)
+		var err error
+
+		err = r.ParseForm()
+		if err == nil {
+			err = decoder.Decode(&params, r.Form)
+		}
+		if err != nil {
+			api.BadRequestErrorHandler(w, r, err)
+			return
+		}
+
+		var user = chi.URLParam(r, "user")
+		var wallet = tools.Wallet{Name: params.Name, Currency: params.Currency, Precision: params.Precision}
+		pointDetails, err := database.OpenWallet(r.Context(), user, wallet)
+
+		var event = audit.Event{
+			Action:  audit.ActionWalletOpen,
+			Subject: user,
+			Outcome: audit.OutcomeOf(err),
+			Details: map[string]string{
+				"wallet":    wallet.Name,
+				"currency":  wallet.Currency,
+				"precision": strconv.Itoa(wallet.Precision),
+			},
+		}
+		if loginDetails, err := database.GetUserLoginDetails(r.Context(), user); err == nil {
+			event.Subject = loginDetails.UserID
+		}
+		if err != nil {
+			event.Details["error"] = err.Error()
+		}
+		middleware.RecordAudit(r, auditLog, event)
+		switch {
+		case errors.Is(err, tools.ErrUserNotFound):
+			api.RequestErrorHandler(w, r, err)
+			return
+		case errors.Is(err, tools.ErrInvalidWallet):
+			api.BadRequestErrorHandler(w, r, err)
+			return
+		case errors.Is(err, tools.ErrWalletExists):
+			api.ConflictErrorHandler(w, r, err)
+			return
+		case err != nil:
+			logging.FromContext(r.Context()).Error(err)
+			api.InternalErrorHandler(w, r)
+			return
+		}
+
+		account, err := database.GetAccount(r.Context(), pointDetails.UserID)
+		if err != nil {
+			logging.FromContext(r.Context()).Error(err)
+			api.InternalErrorHandler(w, r)
+			return
+		}
+		writeResponse(w, r, http.StatusOK, accountResponse(*account))
+	}
+}
+
+// selectWallets returns every wallet, or only the one named if name is not
+// empty.
+func selectWallets(wallets []tools.Wallet, name string) ([]tools.Wallet, error) {
+	if name == "" {
+		return wallets, nil
+	}
+	for _, wallet := range wallets {
+		if wallet.Name == name {
+			return []tools.Wallet{wallet}, nil
+		}
+	}
+	return nil, tools.ErrWalletNotFound
+}
+
+// walletBalances converts wallets, taking what the active holds reserve off
+// their available balance.
+func walletBalances(wallets []tools.Wallet, holds []tools.Hold) []api.WalletBalance {
+	var details = tools.PointDetails{Wallets: wallets, Holds: holds}
+	var balances = make([]api.WalletBalance, 0, len(wallets))
+	for _, wallet := range wallets {
+		balances = append(balances, api.WalletBalance{
+			Name:      wallet.Name,
+			Currency:  wallet.Currency,
+			Precision: wallet.Precision,
+			Balance:   wallet.Balance,
+			Available: details.Available(wallet.Name),
+		})
+	}
+	return balances
+}
@@ -0,0 +1,165 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"golearn/src/api"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+var (
+	ErrNotStarted   = errors.New("startup has not finished")
+	ErrShuttingDown = errors.New("server is shutting down")
+)
+
+// Check reports whether a dependency is usable. It must return promptly once
+// ctx is done.
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker answers liveness and readiness probes. Readiness fails until
+// MarkReady is called, runs every registered check with a deadline, and
+// fails again from MarkShuttingDown onwards.
+type Checker struct {
+	timeout      time.Duration
+	started      atomic.Bool
+	shuttingDown atomic.Bool
+
+	mu     sync.RWMutex
+	checks []namedCheck
+}
+
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Register adds a readiness check. Checks run concurrently in every probe.
+func (c *Checker) Register(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+func (c *Checker) MarkReady() {
+	c.started.Store(true)
+}
+
+func (c *Checker) MarkShuttingDown() {
+	c.shuttingDown.Store(true)
+}
+
+// LivenessHandler reports that the process is up and able to serve HTTP.
+func (c *Checker) LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	writeResponse(w, r, api.HealthResponse{
+		Code:   http.StatusOK,
+		Status: StatusOK,
+		Checks: []api.HealthCheck{},
+	})
+}
+
+// ReadinessHandler reports whether the server should receive traffic.
+func (c *Checker) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	var results = c.Run(r.Context())
+
+	var response = api.HealthResponse{
+		Code:   http.StatusOK,
+		Status: StatusOK,
+		Checks: results,
+	}
+	for _, result := range results {
+		if result.Status != StatusOK {
+			response.Code = http.StatusServiceUnavailable
+			response.Status = StatusFail
+		}
+	}
+
+	writeResponse(w, r, response)
+}
+
+// Run executes the lifecycle checks and every registered check, each bounded
+// by the checker's timeout, and returns the results in registration order.
+func (c *Checker) Run(ctx context.Context) []api.HealthCheck {
+	c.mu.RLock()
+	var checks = append([]namedCheck{
+		{name: "startup", check: c.checkStarted},
+		{name: "shutdown", check: c.checkShutdown},
+	}, c.checks...)
+	c.mu.RUnlock()
+
+	var results = make([]api.HealthCheck, len(checks))
+	var wg = sync.WaitGroup{}
+	for i, nc := range checks {
+		wg.Add(1)
+		go func(i int, nc namedCheck) {
+			defer wg.Done()
+			results[i] = c.runCheck(ctx, nc)
+		}(i, nc)
+	}
+	wg.Wait()
+
+	return results
+}
+
+func (c *Checker) runCheck(ctx context.Context, nc namedCheck) api.HealthCheck {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var start = time.Now()
+	var done = make(chan error, 1)
+	go func() {
+		done <- nc.check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	var result = api.HealthCheck{
+		Name:      nc.name,
+		Status:    StatusOK,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+func (c *Checker) checkStarted(ctx context.Context) error {
+	if !c.started.Load() {
+		return ErrNotStarted
+	}
+	return nil
+}
+
+func (c *Checker) checkShutdown(ctx context.Context) error {
+	if c.shuttingDown.Load() {
+		return ErrShuttingDown
+	}
+	return nil
+}
+
+func writeResponse(w http.ResponseWriter, r *http.Request, response api.HealthResponse) {
+	w.Header().Set("Cache-Control", "no-store")
+	if err := api.Render(w, r, response.Code, response); err != nil {
+		log.Error(err)
+	}
+}
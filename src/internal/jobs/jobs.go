@@ -0,0 +1,40 @@
+// Package jobs defines the background jobs of the server.
+package jobs
+
+import (
+	"context"
+	"golearn/src/internal/config"
+	"golearn/src/internal/scheduler"
+	"golearn/src/internal/tools"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Names of the jobs.
+const (
+	ExpireHolds = "expire-holds"
+)
+
+// Register adds every job with a schedule in cfg to s.
+func Register(s *scheduler.Scheduler, cfg config.Scheduler, database tools.DatabaseInterface) error {
+	if cfg.HoldSweep != "" {
+		if err := s.Add(ExpireHolds, cfg.HoldSweep, expireHolds(database)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expireHolds deletes expired holds from the store.
+func expireHolds(database tools.DatabaseInterface) scheduler.Func {
+	return func(ctx context.Context) error {
+		expired, err := database.ExpireHolds(ctx)
+		if err != nil {
+			return err
+		}
+		if expired > 0 {
+			log.Infof("deleted %d expired holds", expired)
+		}
+		return nil
+	}
+}
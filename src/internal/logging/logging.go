@@ -0,0 +1,64 @@
+package logging
+
+import (
+	"context"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+type contextKey struct{}
+
+// requestState is shared by every layer handling one request, so that fields
+// added by inner layers (such as the authenticated user) reach the access
+// log written by the outermost middleware.
+type requestState struct {
+	mu    sync.Mutex
+	entry *log.Entry
+	user  string
+}
+
+// NewContext returns a context carrying entry as the request's logger.
+func NewContext(ctx context.Context, entry *log.Entry) context.Context {
+	return context.WithValue(ctx, contextKey{}, &requestState{entry: entry})
+}
+
+// FromContext returns the request's logger, or the standard logger when ctx
+// does not belong to a request.
+func FromContext(ctx context.Context) *log.Entry {
+	if state, ok := ctx.Value(contextKey{}).(*requestState); ok {
+		state.mu.Lock()
+		defer state.mu.Unlock()
+		return state.entry
+	}
+	return log.NewEntry(log.StandardLogger())
+}
+
+// AddFields attaches fields to every later log line of the request.
+func AddFields(ctx context.Context, fields log.Fields) {
+	if state, ok := ctx.Value(contextKey{}).(*requestState); ok {
+		state.mu.Lock()
+		defer state.mu.Unlock()
+		state.entry = state.entry.WithFields(fields)
+	}
+}
+
+// SetUser records the authenticated user of the request.
+func SetUser(ctx context.Context, user string) {
+	if state, ok := ctx.Value(contextKey{}).(*requestState); ok {
+		state.mu.Lock()
+		defer state.mu.Unlock()
+		state.user = user
+		state.entry = state.entry.WithField("user", user)
+	}
+}
+
+// User returns the user recorded by SetUser, if any.
+func User(ctx context.Context) string {
+	if state, ok := ctx.Value(contextKey{}).(*requestState); ok {
+		state.mu.Lock()
+		defer state.mu.Unlock()
+		return state.user
+	}
+	return ""
+}
@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"golearn/src/internal/tools"
+)
+
+// RegisterCache exposes the hit, miss and eviction counters of cache in the
+// Default registry.
+func RegisterCache(cache *tools.CachedDatabase) {
+	Default.NewCounterSampleFunc(
+		"golearn_store_cache_hits_total",
+		"Store lookups answered from the cache, by cache.",
+		func() []Sample {
+			var stats = cache.Stats()
+			return []Sample{
+				{LabelValues: []string{"login"}, Value: float64(stats.LoginHits)},
+				{LabelValues: []string{"point"}, Value: float64(stats.PointHits)},
+			}
+		},
+		"cache",
+	)
+	Default.NewCounterSampleFunc(
+		"golearn_store_cache_misses_total",
+		"Store lookups that went to the database, by cache.",
+		func() []Sample {
+			var stats = cache.Stats()
+			return []Sample{
+				{LabelValues: []string{"login"}, Value: float64(stats.LoginMisses)},
+				{LabelValues: []string{"point"}, Value: float64(stats.PointMisses)},
+			}
+		},
+		"cache",
+	)
+	Default.NewCounterFunc(
+		"golearn_store_cache_evictions_total",
+		"Cache entries evicted to stay within cache.max_entries.",
+		func() float64 { return float64(cache.Stats().Evictions) },
+	)
+	Default.NewGaugeFunc(
+		"golearn_store_cache_entries",
+		"Entries currently held by the store caches.",
+		func() float64 { return float64(cache.Stats().Entries) },
+	)
+}
+
+// RegisterCoalescing exposes how many lookups were merged by coalesced.
+func RegisterCoalescing(coalesced *tools.CoalescedDatabase) {
+	Default.NewCounterFunc(
+		"golearn_store_coalesced_executions_total",
+		"Backend lookups started by the coalescing layer.",
+		func() float64 { return float64(coalesced.Stats().Executions) },
+	)
+	Default.NewCounterFunc(
+		"golearn_store_coalesced_shared_total",
+		"Lookups that waited for an identical lookup already in flight.",
+		func() float64 { return float64(coalesced.Stats().Shared) },
+	)
+}
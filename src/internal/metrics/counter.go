@@ -0,0 +1,160 @@
+package metrics
+
+import (
+	"bufio"
+	"sort"
+	"sync"
+)
+
+// CounterVec is a family of monotonically increasing counters partitioned by
+// labels.
+type CounterVec struct {
+	metricName string
+	help       string
+	labelNames []string
+
+	mu     sync.Mutex
+	series map[string]*counterSeries
+}
+
+type counterSeries struct {
+	labelValues []string
+	value       float64
+}
+
+func (reg *Registry) NewCounterVec(name string, help string, labelNames ...string) *CounterVec {
+	var c = &CounterVec{
+		metricName: name,
+		help:       help,
+		labelNames: labelNames,
+		series:     map[string]*counterSeries{},
+	}
+	reg.register(c)
+	return c
+}
+
+// Inc adds one to the counter identified by labelValues.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds delta, which must not be negative, to the counter identified by
+// labelValues.
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		panic("metrics: counter " + c.metricName + " cannot decrease")
+	}
+	checkLabels(c.metricName, c.labelNames, labelValues)
+
+	var key = labelKey(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var s, ok = c.series[key]
+	if !ok {
+		s = &counterSeries{labelValues: append([]string(nil), labelValues...)}
+		c.series[key] = s
+	}
+	s.value += delta
+}
+
+// Value returns the current value of the counter identified by labelValues.
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.series[labelKey(labelValues)]; ok {
+		return s.value
+	}
+	return 0
+}
+
+func (c *CounterVec) name() string {
+	return c.metricName
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	writeHeader(w, c.metricName, c.help, "counter")
+	for _, key := range sortedKeys(c.series) {
+		var s = c.series[key]
+		writeSample(w, c.metricName, c.labelNames, s.labelValues, s.value)
+	}
+}
+
+// FuncMetric reports a single value computed at scrape time, for example a
+// counter kept by another package.
+type FuncMetric struct {
+	metricName string
+	help       string
+	kind       string
+	fn         func() float64
+}
+
+// NewGaugeFunc registers a gauge whose value is read from fn on every scrape.
+func (reg *Registry) NewGaugeFunc(name string, help string, fn func() float64) *FuncMetric {
+	var f = &FuncMetric{metricName: name, help: help, kind: "gauge", fn: fn}
+	reg.register(f)
+	return f
+}
+
+// NewCounterFunc registers a counter whose value is read from fn on every
+// scrape. fn must never return a smaller value than before.
+func (reg *Registry) NewCounterFunc(name string, help string, fn func() float64) *FuncMetric {
+	var f = &FuncMetric{metricName: name, help: help, kind: "counter", fn: fn}
+	reg.register(f)
+	return f
+}
+
+func (f *FuncMetric) name() string {
+	return f.metricName
+}
+
+func (f *FuncMetric) write(w *bufio.Writer) {
+	writeHeader(w, f.metricName, f.help, f.kind)
+	writeSample(w, f.metricName, nil, nil, f.fn())
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	var keys = make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Sample is one labelled value reported by a SampleFunc metric.
+type Sample struct {
+	LabelValues []string
+	Value       float64
+}
+
+// SampleFunc reports labelled values computed at scrape time.
+type SampleFunc struct {
+	metricName string
+	help       string
+	kind       string
+	labelNames []string
+	fn         func() []Sample
+}
+
+// NewCounterSampleFunc registers a labelled counter family read from fn on
+// every scrape.
+func (reg *Registry) NewCounterSampleFunc(name string, help string, fn func() []Sample, labelNames ...string) *SampleFunc {
+	var f = &SampleFunc{metricName: name, help: help, kind: "counter", labelNames: labelNames, fn: fn}
+	reg.register(f)
+	return f
+}
+
+func (f *SampleFunc) name() string {
+	return f.metricName
+}
+
+func (f *SampleFunc) write(w *bufio.Writer) {
+	writeHeader(w, f.metricName, f.help, f.kind)
+	for _, sample := range f.fn() {
+		checkLabels(f.metricName, f.labelNames, sample.LabelValues)
+		writeSample(w, f.metricName, f.labelNames, sample.LabelValues, sample.Value)
+	}
+}
@@ -0,0 +1,126 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"golearn/src/internal/tools"
+	"time"
+)
+
+// instrumentedDatabase records the latency and failures of every call to
+// the wrapped store.
+type instrumentedDatabase struct {
+	next tools.DatabaseInterface
+}
+
+// InstrumentDatabase wraps database so that every call is measured. Lookups
+// that find nothing and rejected updates are not counted as errors.
+func InstrumentDatabase(database tools.DatabaseInterface) tools.DatabaseInterface {
+	return &instrumentedDatabase{next: database}
+}
+
+func observe(method string, start time.Time, err error) {
+	StoreCallDuration.ObserveSince(start, method)
+	if err != nil && !errors.Is(err, tools.ErrUserNotFound) && !errors.Is(err, tools.ErrInsufficientFunds) &&
+		!errors.Is(err, tools.ErrUsernameTaken) && !errors.Is(err, tools.ErrWalletNotFound) &&
+		!errors.Is(err, tools.ErrWalletExists) && !errors.Is(err, tools.ErrHoldNotFound) &&
+		!errors.Is(err, tools.ErrHoldExceeded) && !errors.Is(err, tools.ErrInvalidAdjustment) &&
+		!errors.Is(err, tools.ErrInvalidHoldAmount) && !errors.Is(err, tools.ErrBalanceOverflow) {
+		StoreCallErrors.Inc(method)
+	}
+}
+
+func (d *instrumentedDatabase) GetUserLoginDetails(ctx context.Context, user string) (loginDetails *tools.LoginDetails, err error) {
+	defer func(start time.Time) { observe("GetUserLoginDetails", start, err) }(time.Now())
+	return d.next.GetUserLoginDetails(ctx, user)
+}
+
+func (d *instrumentedDatabase) GetUserPointDetails(ctx context.Context, user string) (pointDetails *tools.PointDetails, err error) {
+	defer func(start time.Time) { observe("GetUserPointDetails", start, err) }(time.Now())
+	return d.next.GetUserPointDetails(ctx, user)
+}
+
+func (d *instrumentedDatabase) UpdateUserBalance(ctx context.Context, user string, wallet string, delta int64) (pointDetails *tools.PointDetails, err error) {
+	defer func(start time.Time) { observe("UpdateUserBalance", start, err) }(time.Now())
+	return d.next.UpdateUserBalance(ctx, user, wallet, delta)
+}
+
+func (d *instrumentedDatabase) AdjustBalance(ctx context.Context, user string, wallet string, delta int64, reason string) (pointDetails *tools.PointDetails, err error) {
+	defer func(start time.Time) { observe("AdjustBalance", start, err) }(time.Now())
+	return d.next.AdjustBalance(ctx, user, wallet, delta, reason)
+}
+
+func (d *instrumentedDatabase) OpenWallet(ctx context.Context, user string, wallet tools.Wallet) (pointDetails *tools.PointDetails, err error) {
+	defer func(start time.Time) { observe("OpenWallet", start, err) }(time.Now())
+	return d.next.OpenWallet(ctx, user, wallet)
+}
+
+func (d *instrumentedDatabase) PlaceHold(ctx context.Context, user string, wallet string, amount int64, ttl time.Duration) (hold *tools.Hold, pointDetails *tools.PointDetails, err error) {
+	defer func(start time.Time) { observe("PlaceHold", start, err) }(time.Now())
+	return d.next.PlaceHold(ctx, user, wallet, amount, ttl)
+}
+
+func (d *instrumentedDatabase) CaptureHold(ctx context.Context, user string, holdID string, amount int64) (hold *tools.Hold, pointDetails *tools.PointDetails, err error) {
+	defer func(start time.Time) { observe("CaptureHold", start, err) }(time.Now())
+	return d.next.CaptureHold(ctx, user, holdID, amount)
+}
+
+func (d *instrumentedDatabase) ReleaseHold(ctx context.Context, user string, holdID string) (hold *tools.Hold, pointDetails *tools.PointDetails, err error) {
+	defer func(start time.Time) { observe("ReleaseHold", start, err) }(time.Now())
+	return d.next.ReleaseHold(ctx, user, holdID)
+}
+
+func (d *instrumentedDatabase) GetAccount(ctx context.Context, user string) (account *tools.Account, err error) {
+	defer func(start time.Time) { observe("GetAccount", start, err) }(time.Now())
+	return d.next.GetAccount(ctx, user)
+}
+
+func (d *instrumentedDatabase) RenameUser(ctx context.Context, user string, username string) (loginDetails *tools.LoginDetails, err error) {
+	defer func(start time.Time) { observe("RenameUser", start, err) }(time.Now())
+	return d.next.RenameUser(ctx, user, username)
+}
+
+func (d *instrumentedDatabase) ListAccounts(ctx context.Context, fn func(tools.Account) error) (err error) {
+	defer func(start time.Time) { observe("ListAccounts", start, err) }(time.Now())
+	return d.next.ListAccounts(ctx, fn)
+}
+
+func (d *instrumentedDatabase) PutAccount(ctx context.Context, account tools.Account) (err error) {
+	defer func(start time.Time) { observe("PutAccount", start, err) }(time.Now())
+	return d.next.PutAccount(ctx, account)
+}
+
+func (d *instrumentedDatabase) PutAccounts(ctx context.Context, accounts []tools.Account) (errs []error, err error) {
+	defer func(start time.Time) { observe("PutAccounts", start, err) }(time.Now())
+	return d.next.PutAccounts(ctx, accounts)
+}
+
+func (d *instrumentedDatabase) Snapshot(ctx context.Context) (data tools.Dataset, err error) {
+	defer func(start time.Time) { observe("Snapshot", start, err) }(time.Now())
+	return d.next.Snapshot(ctx)
+}
+
+func (d *instrumentedDatabase) Restore(ctx context.Context, data tools.Dataset) (err error) {
+	defer func(start time.Time) { observe("Restore", start, err) }(time.Now())
+	return d.next.Restore(ctx, data)
+}
+
+func (d *instrumentedDatabase) ExpireHolds(ctx context.Context) (expired int, err error) {
+	defer func(start time.Time) { observe("ExpireHolds", start, err) }(time.Now())
+	return d.next.ExpireHolds(ctx)
+}
+
+func (d *instrumentedDatabase) SetupDatabase() (err error) {
+	defer func(start time.Time) { observe("SetupDatabase", start, err) }(time.Now())
+	return d.next.SetupDatabase()
+}
+
+func (d *instrumentedDatabase) Ping(ctx context.Context) (err error) {
+	defer func(start time.Time) { observe("Ping", start, err) }(time.Now())
+	return d.next.Ping(ctx)
+}
+
+func (d *instrumentedDatabase) Close() (err error) {
+	defer func(start time.Time) { observe("Close", start, err) }(time.Now())
+	return d.next.Close()
+}
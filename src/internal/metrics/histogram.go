@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"bufio"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// HistogramVec is a family of histograms partitioned by labels. Observations
+// are counted into cumulative buckets, as Prometheus expects.
+type HistogramVec struct {
+	metricName string
+	help       string
+	labelNames []string
+	buckets    []float64
+
+	mu     sync.Mutex
+	series map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	labelValues []string
+	counts      []uint64
+	sum         float64
+	count       uint64
+}
+
+// NewHistogramVec registers a histogram with the given upper bucket bounds,
+// which must be sorted. A +Inf bucket is always added.
+func (reg *Registry) NewHistogramVec(name string, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	if !sort.Float64sAreSorted(buckets) {
+		panic("metrics: buckets of " + name + " are not sorted")
+	}
+	var h = &HistogramVec{
+		metricName: name,
+		help:       help,
+		labelNames: labelNames,
+		buckets:    buckets,
+		series:     map[string]*histogramSeries{},
+	}
+	reg.register(h)
+	return h
+}
+
+// Observe records value in the histogram identified by labelValues.
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	checkLabels(h.metricName, h.labelNames, labelValues)
+
+	var key = labelKey(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var s, ok = h.series[key]
+	if !ok {
+		s = &histogramSeries{
+			labelValues: append([]string(nil), labelValues...),
+			counts:      make([]uint64, len(h.buckets)),
+		}
+		h.series[key] = s
+	}
+
+	var i = sort.SearchFloat64s(h.buckets, value)
+	if i < len(s.counts) {
+		s.counts[i]++
+	}
+	s.sum += value
+	s.count++
+}
+
+// ObserveSince records the seconds elapsed since start.
+func (h *HistogramVec) ObserveSince(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+// Count returns how many observations the histogram identified by
+// labelValues holds.
+func (h *HistogramVec) Count(labelValues ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.series[labelKey(labelValues)]; ok {
+		return s.count
+	}
+	return 0
+}
+
+func (h *HistogramVec) name() string {
+	return h.metricName
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	writeHeader(w, h.metricName, h.help, "histogram")
+
+	var bucketLabels = append(append([]string(nil), h.labelNames...), "le")
+	for _, key := range sortedKeys(h.series) {
+		var s = h.series[key]
+		var values = append(append([]string(nil), s.labelValues...), "")
+
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			values[len(values)-1] = formatFloat(bound)
+			writeSample(w, h.metricName+"_bucket", bucketLabels, values, float64(cumulative))
+		}
+		values[len(values)-1] = formatFloat(math.Inf(1))
+		writeSample(w, h.metricName+"_bucket", bucketLabels, values, float64(s.count))
+		writeSample(w, h.metricName+"_sum", h.labelNames, s.labelValues, s.sum)
+		writeSample(w, h.metricName+"_count", h.labelNames, s.labelValues, float64(s.count))
+	}
+}
@@ -0,0 +1,36 @@
+package metrics
+
+// Metrics recorded by the API. They live in the Default registry.
+var (
+	HTTPRequests = Default.NewCounterVec(
+		"golearn_http_requests_total",
+		"HTTP requests handled, by method, chi route pattern and status code.",
+		"method", "route", "status",
+	)
+	HTTPRequestDuration = Default.NewHistogramVec(
+		"golearn_http_request_duration_seconds",
+		"HTTP request latency, by method, chi route pattern and status code.",
+		DefaultBuckets,
+		"method", "route", "status",
+	)
+	StoreCallDuration = Default.NewHistogramVec(
+		"golearn_store_call_duration_seconds",
+		"Latency of DatabaseInterface calls, by method.",
+		DefaultBuckets,
+		"method",
+	)
+	StoreCallErrors = Default.NewCounterVec(
+		"golearn_store_call_errors_total",
+		"DatabaseInterface calls that failed, by method.",
+		"method",
+	)
+	AuthFailures = Default.NewCounterVec(
+		"golearn_auth_failures_total",
+		"Requests rejected by the authorization middleware, by reason.",
+		"reason",
+	)
+)
+
+func init() {
+	Default.RegisterRuntime()
+}
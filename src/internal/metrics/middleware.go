@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi"
+	chimiddle "github.com/go-chi/chi/middleware"
+)
+
+// unmatchedRoute labels requests that no route matched, so that arbitrary
+// paths cannot create new series.
+const unmatchedRoute = "unmatched"
+
+var inFlight atomic.Int64
+
+func init() {
+	Default.NewGaugeFunc(
+		"golearn_http_requests_in_flight",
+		"HTTP requests currently being served.",
+		func() float64 { return float64(inFlight.Load()) },
+	)
+}
+
+// Middleware records the count and latency of every request under the chi
+// route pattern that served it. It must be installed on the root router.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var start = time.Now()
+		var ww = chimiddle.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		inFlight.Add(1)
+		defer inFlight.Add(-1)
+
+		next.ServeHTTP(ww, r)
+
+		var route = unmatchedRoute
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		var status = ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		var labels = []string{r.Method, route, strconv.Itoa(status)}
+		HTTPRequests.Inc(labels...)
+		HTTPRequestDuration.ObserveSince(start, labels...)
+	})
+}
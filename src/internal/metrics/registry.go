@@ -0,0 +1,150 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultBuckets are latency buckets in seconds, matching the Prometheus
+// client defaults.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// family is a named metric that can write itself in the Prometheus text
+// exposition format.
+type family interface {
+	name() string
+	write(w *bufio.Writer)
+}
+
+// Registry holds metric families and renders them for /metrics.
+type Registry struct {
+	mu       sync.Mutex
+	families []family
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Default is the registry served by Handler and used by the package-level
+// metrics.
+var Default = NewRegistry()
+
+func (reg *Registry) register(f family) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	for _, existing := range reg.families {
+		if existing.name() == f.name() {
+			panic("metrics: duplicate metric " + f.name())
+		}
+	}
+	reg.families = append(reg.families, f)
+}
+
+// WriteTo renders every registered family, sorted by name.
+func (reg *Registry) WriteTo(w io.Writer) (int64, error) {
+	reg.mu.Lock()
+	var families = append([]family(nil), reg.families...)
+	reg.mu.Unlock()
+
+	sort.Slice(families, func(i, j int) bool { return families[i].name() < families[j].name() })
+
+	var counter = &countingWriter{w: w}
+	var buf = bufio.NewWriter(counter)
+	for _, f := range families {
+		f.write(buf)
+	}
+	var err = buf.Flush()
+	return counter.n, err
+}
+
+// Handler serves the registry in the Prometheus text format.
+func (reg *Registry) Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if _, err := reg.WriteTo(w); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+// Handler serves the Default registry.
+func Handler() http.HandlerFunc {
+	return Default.Handler()
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func writeHeader(w *bufio.Writer, name string, help string, kind string) {
+	w.WriteString("# HELP " + name + " " + escapeHelp(help) + "\n")
+	w.WriteString("# TYPE " + name + " " + kind + "\n")
+}
+
+func writeSample(w *bufio.Writer, name string, labelNames []string, labelValues []string, value float64) {
+	w.WriteString(name)
+	if len(labelNames) > 0 {
+		w.WriteByte('{')
+		for i, label := range labelNames {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			w.WriteString(label + `="` + escapeLabel(labelValues[i]) + `"`)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(value))
+	w.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+// labelKey joins label values into a map key. The separator cannot appear in
+// valid UTF-8 label values.
+func labelKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+func checkLabels(name string, labelNames []string, values []string) {
+	if len(values) != len(labelNames) {
+		panic("metrics: " + name + " expects " + strconv.Itoa(len(labelNames)) + " label values, got " + strconv.Itoa(len(values)))
+	}
+}
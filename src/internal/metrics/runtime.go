@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"bufio"
+	"runtime"
+	"time"
+)
+
+// runtimeCollector reports Go runtime and process statistics, read once per
+// scrape.
+type runtimeCollector struct {
+	start time.Time
+}
+
+// RegisterRuntime adds Go runtime statistics to the registry.
+func (reg *Registry) RegisterRuntime() {
+	reg.register(&runtimeCollector{start: time.Now()})
+}
+
+func (c *runtimeCollector) name() string {
+	return "go_"
+}
+
+func (c *runtimeCollector) write(w *bufio.Writer) {
+	var stats = runtime.MemStats{}
+	runtime.ReadMemStats(&stats)
+
+	writeHeader(w, "go_info", "Information about the Go environment.", "gauge")
+	writeSample(w, "go_info", []string{"version"}, []string{runtime.Version()}, 1)
+
+	writeGauge(w, "go_goroutines", "Number of goroutines that currently exist.", float64(runtime.NumGoroutine()))
+	writeGauge(w, "go_threads", "Number of OS threads usable by Go code at once (GOMAXPROCS).", float64(runtime.GOMAXPROCS(0)))
+	writeGauge(w, "go_memstats_alloc_bytes", "Number of bytes allocated and still in use.", float64(stats.Alloc))
+	writeCounter(w, "go_memstats_alloc_bytes_total", "Total number of bytes allocated, even if freed.", float64(stats.TotalAlloc))
+	writeGauge(w, "go_memstats_sys_bytes", "Number of bytes obtained from the system.", float64(stats.Sys))
+	writeGauge(w, "go_memstats_heap_alloc_bytes", "Number of heap bytes allocated and still in use.", float64(stats.HeapAlloc))
+	writeGauge(w, "go_memstats_heap_inuse_bytes", "Number of heap bytes in in-use spans.", float64(stats.HeapInuse))
+	writeGauge(w, "go_memstats_heap_objects", "Number of allocated heap objects.", float64(stats.HeapObjects))
+	writeCounter(w, "go_memstats_mallocs_total", "Total number of heap allocations.", float64(stats.Mallocs))
+	writeCounter(w, "go_memstats_frees_total", "Total number of heap frees.", float64(stats.Frees))
+	writeCounter(w, "go_gc_cycles_total", "Number of completed GC cycles.", float64(stats.NumGC))
+	writeCounter(w, "go_gc_pause_seconds_total", "Total time spent in GC stop-the-world pauses.", float64(stats.PauseTotalNs)/1e9)
+	writeGauge(w, "process_start_time_seconds", "Start time of the process since the Unix epoch in seconds.", float64(c.start.UnixNano())/1e9)
+}
+
+func writeGauge(w *bufio.Writer, name string, help string, value float64) {
+	writeHeader(w, name, help, "gauge")
+	writeSample(w, name, nil, nil, value)
+}
+
+func writeCounter(w *bufio.Writer, name string, help string, value float64) {
+	writeHeader(w, name, help, "counter")
+	writeSample(w, name, nil, nil, value)
+}
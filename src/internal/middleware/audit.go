@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"golearn/src/internal/audit"
+	"golearn/src/internal/logging"
+	"golearn/src/internal/tenant"
+	"net"
+	"net/http"
+)
+
+// RecordAudit fills in the request's tenant, client address and request ID,
+// then records ev. Unless ev names its actor, the actor is the authenticated
+// user's ID, or the user the request log names. Events recorded before
+// authentication succeeds, such as auth.failure, set the claimed username as
+// the actor themselves.
+func RecordAudit(r *http.Request, auditLog *audit.Log, ev audit.Event) {
+	if auditLog == nil {
+		return
+	}
+	ev.Tenant, _ = tenant.FromContext(r.Context())
+	if ev.Actor == "" {
+		if loginDetails := LoginDetailsFromContext(r.Context()); loginDetails != nil {
+			ev.Actor = loginDetails.UserID
+		} else {
+			ev.Actor = logging.User(r.Context())
+		}
+	}
+	ev.IP = ClientIP(r)
+	ev.RequestID = GetRequestID(r.Context())
+	auditLog.Record(ev)
+}
+
+// ClientIP returns the host part of the request's remote address.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
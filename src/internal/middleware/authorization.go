@@ -1,45 +1,115 @@
 package middleware
 
 import (
+	"context"
+	"crypto/subtle"
 	"errors"
 	"golearn/src/api"
+	"golearn/src/internal/audit"
+	"golearn/src/internal/logging"
+	"golearn/src/internal/metrics"
 	"golearn/src/internal/tools"
 	"net/http"
+)
 
-	log "github.com/sirupsen/logrus"
+var (
+	ErrorUnauthorized = errors.New("invalid username or token")
+	ErrorForbidden    = errors.New("insufficient permissions")
 )
 
-var ErrorUnauthorized = errors.New("invalid username or token")
+type loginDetailsKey struct{}
+
+func Authorization(database tools.DatabaseInterface, auditLog *audit.Log) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var username = r.URL.Query().Get("username")
+			var token = r.Header.Get("Authorization")
+			var logger = logging.FromContext(r.Context())
+
+			if username == "" || token == "" {
+				metrics.AuthFailures.Inc("missing_credentials")
+				logger.Error(ErrorUnauthorized)
+				RecordAudit(r, auditLog, audit.Event{
+					Action:  audit.ActionAuthFailure,
+					Actor:   username,
+					Subject: username,
+					Outcome: audit.OutcomeFailure,
+					Details: map[string]string{"reason": "missing_credentials"},
+				})
+				api.RequestErrorHandler(w, r, ErrorUnauthorized)
+				return
+			}
+
+			loginDetails, err := database.GetUserLoginDetails(r.Context(), username)
+			if err != nil && !errors.Is(err, tools.ErrUserNotFound) {
+				logger.Error(err)
+				api.InternalErrorHandler(w, r)
+				return
+			}
 
-func Authorization(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		var username = r.URL.Query().Get("username")
-		var token = r.Header.Get("Authorization")
-		var err error
+			if loginDetails == nil || subtle.ConstantTimeCompare([]byte(token), []byte(loginDetails.AuthToken)) != 1 {
+				var subject = username
+				if loginDetails != nil {
+					subject = loginDetails.UserID
+				}
+				metrics.AuthFailures.Inc("invalid_credentials")
+				logger.WithField("username", username).Error(ErrorUnauthorized)
+				RecordAudit(r, auditLog, audit.Event{
+					Action:  audit.ActionAuthFailure,
+					Actor:   username,
+					Subject: subject,
+					Outcome: audit.OutcomeFailure,
+					Details: map[string]string{"reason": "invalid_credentials"},
+				})
+				api.RequestErrorHandler(w, r, ErrorUnauthorized)
+				return
+			}
 
-		if username == "" || token == "" {
-			log.Error(ErrorUnauthorized)
-			api.RequestErrorHandler(w, ErrorUnauthorized)
-			return
-		}
+			logging.SetUser(r.Context(), loginDetails.Username)
+			RecordAudit(r, auditLog, audit.Event{
+				Action:  audit.ActionAuthSuccess,
+				Actor:   loginDetails.UserID,
+				Subject: loginDetails.UserID,
+			})
+			next.ServeHTTP(w, r.WithContext(NewLoginContext(r.Context(), loginDetails)))
 
-		var database *tools.DatabaseInterface
-		database, err = tools.NewDatabase()
-		if err != nil {
-			log.Error(err)
-			api.InternalErrorHandler(w)
-			return
-		}
+		})
+	}
+}
+
+// NewLoginContext returns a copy of ctx carrying loginDetails, for
+// handlers that authenticate requests without Authorization.
+func NewLoginContext(ctx context.Context, loginDetails *tools.LoginDetails) context.Context {
+	return context.WithValue(ctx, loginDetailsKey{}, loginDetails)
+}
 
-		var loginDetails *tools.LoginDetails = (*database).GetUserLoginDetails(username)
+// LoginDetailsFromContext returns the account authenticated by
+// Authorization, or nil.
+func LoginDetailsFromContext(ctx context.Context) *tools.LoginDetails {
+	loginDetails, _ := ctx.Value(loginDetailsKey{}).(*tools.LoginDetails)
+	return loginDetails
+}
 
-		if loginDetails == nil || (token != (*loginDetails).AuthToken) {
-			log.Error(ErrorUnauthorized)
-			api.RequestErrorHandler(w, ErrorUnauthorized)
-			return
-		}
+// RequireRole rejects requests whose authenticated account lacks role. It
+// must run after Authorization.
+func RequireRole(role string, auditLog *audit.Log) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var loginDetails = LoginDetailsFromContext(r.Context())
 
-		next.ServeHTTP(w, r)
+			if loginDetails == nil || !loginDetails.HasRole(role) {
+				metrics.AuthFailures.Inc("forbidden")
+				logging.FromContext(r.Context()).WithField("role", role).Error(ErrorForbidden)
+				RecordAudit(r, auditLog, audit.Event{
+					Action:  audit.ActionAuthForbidden,
+					Outcome: audit.OutcomeFailure,
+					Details: map[string]string{"role": role, "path": r.URL.Path},
+				})
+				api.ForbiddenErrorHandler(w, r, ErrorForbidden)
+				return
+			}
 
-	})
+			next.ServeHTTP(w, r)
+		})
+	}
 }
@@ -0,0 +1,36 @@
+package middleware_test
+
+import (
+	"golearn/src/apitest"
+	"net/http"
+	"testing"
+)
+
+func TestAuthorizationFailureActor(t *testing.T) {
+	var tests = []struct {
+		name   string
+		user   apitest.User
+		reason string
+	}{
+		{name: "wrong token", user: apitest.User{Username: "alice", Token: "wrong"}, reason: "invalid_credentials"},
+		{name: "unknown user", user: apitest.User{Username: "nobody", Token: "token"}, reason: "invalid_credentials"},
+		{name: "no token", user: apitest.User{Username: "alice"}, reason: "missing_credentials"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var srv = apitest.New(t, apitest.Options{})
+			srv.CreateUser(apitest.User{Username: "alice"})
+
+			var resp = srv.Do(test.user, http.MethodGet, "/api/account/balance", nil, nil)
+			apitest.AssertErrorResponse(t, resp, http.StatusNotFound)
+
+			var entries = srv.AuditEntries(apitest.AuditFilter{Action: "auth.failure"})
+			if len(entries) != 1 {
+				t.Fatalf("auth.failure entries = %+v, want one", entries)
+			}
+			if entries[0].Actor != test.user.Username || entries[0].Details["reason"] != test.reason {
+				t.Fatalf("entry = %+v, want actor %q and reason %q", entries[0], test.user.Username, test.reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"golearn/src/internal/logging"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	chimiddle "github.com/go-chi/chi/middleware"
+	log "github.com/sirupsen/logrus"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds client-supplied request IDs so they cannot bloat
+// log lines.
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// RequestID reuses a well-formed X-Request-ID from the client or generates
+// one, echoes it in the response and stores a logger carrying it and the
+// basic request fields in the context.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var requestID = r.Header.Get(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+
+		var entry = log.WithFields(log.Fields{
+			"request_id": requestID,
+			"method":     r.Method,
+			"path":       r.URL.Path,
+			"remote":     r.RemoteAddr,
+		})
+
+		var ctx = context.WithValue(r.Context(), requestIDKey{}, requestID)
+		ctx = logging.NewContext(ctx, entry)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// GetRequestID returns the ID assigned by RequestID, or "".
+func GetRequestID(ctx context.Context) string {
+	if requestID, ok := ctx.Value(requestIDKey{}).(string); ok {
+		return requestID
+	}
+	return ""
+}
+
+// AccessLog writes one structured line per request once it has been served.
+// It must run inside RequestID.
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var start = time.Now()
+		var ww = chimiddle.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		var status = ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		var route string
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			route = rctx.RoutePattern()
+		}
+
+		var entry = logging.FromContext(r.Context()).WithFields(log.Fields{
+			"route":      route,
+			"status":     status,
+			"bytes":      ww.BytesWritten(),
+			"latency_ms": float64(time.Since(start).Microseconds()) / 1000,
+			"user":       logging.User(r.Context()),
+		})
+		if status >= http.StatusInternalServerError {
+			entry.Error("request completed")
+		} else {
+			entry.Info("request completed")
+		}
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		var ok = c == '-' || c == '_' || c == '.' || c == ':' ||
+			(c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	var b = make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().UTC().Format("20060102T150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
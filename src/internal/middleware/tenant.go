@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"golearn/src/api"
+	"golearn/src/internal/audit"
+	"golearn/src/internal/logging"
+	"golearn/src/internal/metrics"
+	"golearn/src/internal/tenant"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Tenant scopes every request to the tenant chosen by resolver and rejects
+// requests whose tenant cannot be determined. Everything after it, including
+// Authorization, only sees that tenant's accounts.
+func Tenant(resolver *tenant.Resolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tenantID, err := resolver.Resolve(r)
+			if err != nil {
+				logging.FromContext(r.Context()).Error(err)
+				api.BadRequestErrorHandler(w, r, err)
+				return
+			}
+
+			logging.AddFields(r.Context(), log.Fields{"tenant": tenantID})
+			next.ServeHTTP(w, r.WithContext(tenant.NewContext(r.Context(), tenantID)))
+		})
+	}
+}
+
+// RequireTenant rejects requests that do not belong to tenantID. Use it for
+// routes that act on every tenant at once.
+func RequireTenant(tenantID string, auditLog *audit.Log) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if current, _ := tenant.FromContext(r.Context()); current != tenantID {
+				metrics.AuthFailures.Inc("forbidden")
+				logging.FromContext(r.Context()).WithField("required_tenant", tenantID).Error(ErrorForbidden)
+				RecordAudit(r, auditLog, audit.Event{
+					Action:  audit.ActionAuthForbidden,
+					Outcome: audit.OutcomeFailure,
+					Details: map[string]string{"tenant": tenantID, "path": r.URL.Path},
+				})
+				api.ForbiddenErrorHandler(w, r, ErrorForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
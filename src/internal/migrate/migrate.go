@@ -0,0 +1,81 @@
+package migrate
+
+import (
+	"fmt"
+)
+
+// Document is persisted data in its raw, decoded JSON form. Migrations
+// rewrite it in place.
+type Document = map[string]any
+
+// Migration upgrades a Document from Version-1 to Version.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(doc Document) error
+}
+
+// NewerSchemaError reports data written by a newer binary, which this one
+// must not touch.
+type NewerSchemaError struct {
+	Data   int
+	Binary int
+}
+
+func (e *NewerSchemaError) Error() string {
+	return fmt.Sprintf("data schema version %d is newer than this binary supports (%d); upgrade golearn", e.Data, e.Binary)
+}
+
+// Registry is an ordered list of migrations numbered from 1.
+type Registry struct {
+	migrations []Migration
+}
+
+// Register appends m. Migrations must be registered in order without gaps;
+// anything else is a programming error and panics.
+func (r *Registry) Register(m Migration) {
+	if m.Version != r.Latest()+1 {
+		panic(fmt.Sprintf("migrate: migration %d (%s) registered after %d", m.Version, m.Name, r.Latest()))
+	}
+	if m.Up == nil {
+		panic(fmt.Sprintf("migrate: migration %d (%s) has no Up", m.Version, m.Name))
+	}
+	r.migrations = append(r.migrations, m)
+}
+
+// Latest is the schema version this binary writes.
+func (r *Registry) Latest() int {
+	return len(r.migrations)
+}
+
+// Pending returns the migrations data at version current still needs, or a
+// *NewerSchemaError if it is ahead of the binary.
+func (r *Registry) Pending(current int) ([]Migration, error) {
+	if current > r.Latest() {
+		return nil, &NewerSchemaError{Data: current, Binary: r.Latest()}
+	}
+	if current < 0 {
+		return nil, fmt.Errorf("invalid schema version %d", current)
+	}
+	return r.migrations[current:], nil
+}
+
+// Up applies every pending migration to doc in order and returns the new
+// version. On failure doc may be partly migrated, so callers must only
+// persist it after Up succeeds.
+func (r *Registry) Up(doc Document, current int, onApply func(Migration)) (int, error) {
+	pending, err := r.Pending(current)
+	if err != nil {
+		return current, err
+	}
+	for _, m := range pending {
+		if err := m.Up(doc); err != nil {
+			return current, fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+		current = m.Version
+		if onApply != nil {
+			onApply(m)
+		}
+	}
+	return current, nil
+}
@@ -0,0 +1,115 @@
+package migrate
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+// testRegistry records the versions it applies to a document under
+// "applied". Migration 3 fails if the document has "fail" set.
+func testRegistry() *Registry {
+	var r = &Registry{}
+	for version := 1; version <= 4; version++ {
+		r.Register(Migration{
+			Version: version,
+			Name:    "test",
+			Up: func(doc Document) error {
+				if version == 3 && doc["fail"] == true {
+					return errors.New("broken")
+				}
+				doc["applied"] = append(doc["applied"].([]int), version)
+				return nil
+			},
+		})
+	}
+	return r
+}
+
+func TestUp(t *testing.T) {
+	var tests = []struct {
+		name        string
+		current     int
+		fail        bool
+		wantVersion int
+		wantApplied []int
+		wantNewer   bool
+		wantErr     bool
+	}{
+		{name: "from scratch", current: 0, wantVersion: 4, wantApplied: []int{1, 2, 3, 4}},
+		{name: "partway", current: 2, wantVersion: 4, wantApplied: []int{3, 4}},
+		{name: "up to date", current: 4, wantVersion: 4, wantApplied: []int{}},
+		{name: "newer data", current: 5, wantVersion: 5, wantApplied: []int{}, wantNewer: true, wantErr: true},
+		{name: "negative version", current: -1, wantVersion: -1, wantApplied: []int{}, wantErr: true},
+		{name: "failing migration", current: 0, fail: true, wantVersion: 2, wantApplied: []int{1, 2}, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var doc = Document{"applied": []int{}, "fail": test.fail}
+			var seen []int
+			version, err := testRegistry().Up(doc, test.current, func(m Migration) {
+				seen = append(seen, m.Version)
+			})
+
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Up error = %v, want error %v", err, test.wantErr)
+			}
+			var newer *NewerSchemaError
+			if errors.As(err, &newer) != test.wantNewer {
+				t.Fatalf("Up error = %v, want NewerSchemaError %v", err, test.wantNewer)
+			}
+			if test.wantNewer && (newer.Data != test.current || newer.Binary != 4) {
+				t.Fatalf("NewerSchemaError = %+v", newer)
+			}
+			if version != test.wantVersion {
+				t.Errorf("version = %d, want %d", version, test.wantVersion)
+			}
+			if applied := doc["applied"].([]int); !slices.Equal(applied, test.wantApplied) {
+				t.Errorf("applied %v, want %v", applied, test.wantApplied)
+			}
+			if !slices.Equal(seen, test.wantApplied) {
+				t.Errorf("onApply saw %v, want %v", seen, test.wantApplied)
+			}
+		})
+	}
+}
+
+func TestPending(t *testing.T) {
+	var r = testRegistry()
+	for current, want := range map[int]int{0: 4, 1: 3, 4: 0} {
+		pending, err := r.Pending(current)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pending) != want {
+			t.Errorf("Pending(%d) = %d migrations, want %d", current, len(pending), want)
+		}
+		for i, m := range pending {
+			if m.Version != current+i+1 {
+				t.Errorf("Pending(%d)[%d] is version %d", current, i, m.Version)
+			}
+		}
+	}
+}
+
+func TestRegisterOutOfOrder(t *testing.T) {
+	var up = func(Document) error { return nil }
+	var tests = []struct {
+		name string
+		m    Migration
+	}{
+		{"gap", Migration{Version: 6, Up: up}},
+		{"repeat", Migration{Version: 4, Up: up}},
+		{"no up", Migration{Version: 5}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("Register did not panic")
+				}
+			}()
+			testRegistry().Register(test.m)
+		})
+	}
+}
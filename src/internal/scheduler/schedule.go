@@ -0,0 +1,175 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+// A Schedule decides when a job runs. Next returns the first run time
+// strictly after after, or the zero time if there is none.
+type Schedule interface {
+	Next(after time.Time) time.Time
+}
+
+// Parse reads a schedule: "@every <duration>" for a fixed interval, one of
+// @yearly, @monthly, @weekly, @daily and @hourly, or a cron expression of
+// five fields, minute hour day-of-month month day-of-week, in local time.
+// Each field is *, a number, a range a-b or a comma-separated list of them,
+// optionally with a /step.
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if rest, ok := strings.CutPrefix(spec, "@every "); ok {
+		interval, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil || interval < time.Second {
+			return nil, fmt.Errorf("%w %q: @every needs a duration of at least 1s", ErrInvalidSchedule, spec)
+		}
+		return Every(interval), nil
+	}
+	if expr, ok := descriptors[spec]; ok {
+		spec = expr
+	}
+
+	var fields = strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%w %q: expected 5 fields, got %d", ErrInvalidSchedule, spec, len(fields))
+	}
+	var schedule cronSchedule
+	var masks = []*uint64{&schedule.minute, &schedule.hour, &schedule.dom, &schedule.month, &schedule.dow}
+	for i, field := range fields {
+		mask, err := parseField(field, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %s: %v", ErrInvalidSchedule, spec, cronFields[i].name, err)
+		}
+		*masks[i] = mask
+	}
+	// Sunday may be written as 0 or 7.
+	if schedule.dow&(1<<7) != 0 {
+		schedule.dow |= 1
+	}
+	schedule.anyDay = fields[2] == "*" || fields[4] == "*"
+	return schedule, nil
+}
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Every runs a job at a fixed interval, counted from the previous run.
+type Every time.Duration
+
+func (e Every) Next(after time.Time) time.Time {
+	return after.Add(time.Duration(e))
+}
+
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+func parseField(field string, bounds cronField) (uint64, error) {
+	var mask uint64
+	for _, item := range strings.Split(field, ",") {
+		var step = 1
+		if base, stepText, ok := strings.Cut(item, "/"); ok {
+			var err error
+			step, err = strconv.Atoi(stepText)
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepText)
+			}
+			item = base
+		}
+
+		var low, high int
+		switch {
+		case item == "*":
+			low, high = bounds.min, bounds.max
+		case strings.Contains(item, "-"):
+			lowText, highText, _ := strings.Cut(item, "-")
+			var err error
+			if low, err = strconv.Atoi(lowText); err != nil {
+				return 0, fmt.Errorf("invalid value %q", lowText)
+			}
+			if high, err = strconv.Atoi(highText); err != nil {
+				return 0, fmt.Errorf("invalid value %q", highText)
+			}
+		default:
+			var err error
+			if low, err = strconv.Atoi(item); err != nil {
+				return 0, fmt.Errorf("invalid value %q", item)
+			}
+			high = low
+			if step > 1 {
+				high = bounds.max
+			}
+		}
+		if low > high {
+			return 0, fmt.Errorf("invalid range %q", item)
+		}
+		if low < bounds.min || high > bounds.max {
+			return 0, fmt.Errorf("%q is outside %d-%d", item, bounds.min, bounds.max)
+		}
+		for value := low; value <= high; value += step {
+			mask |= 1 << value
+		}
+	}
+	return mask, nil
+}
+
+// cronSchedule holds one bit per allowed value of each field. As in cron,
+// a day matches either day field when both are restricted.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	anyDay                        bool
+}
+
+// searchLimit bounds how far ahead Next looks for expressions that never
+// match, such as the 31st of February.
+const searchLimit = 5 * 366 * 24 * time.Hour
+
+func (c cronSchedule) Next(after time.Time) time.Time {
+	var t = after.Truncate(time.Minute).Add(time.Minute)
+	var limit = after.Add(searchLimit)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.matchDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (c cronSchedule) matchDay(t time.Time) bool {
+	var dom = c.dom&(1<<uint(t.Day())) != 0
+	var dow = c.dow&(1<<uint(t.Weekday())) != 0
+	if c.anyDay {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,103 @@
+package scheduler
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseNext(t *testing.T) {
+	// 2026-01-01 is a Thursday.
+	var after = time.Date(2026, time.January, 1, 10, 30, 15, 0, time.UTC)
+
+	var tests = []struct {
+		spec string
+		want []time.Time
+	}{
+		{"@every 5m", []time.Time{
+			time.Date(2026, time.January, 1, 10, 35, 15, 0, time.UTC),
+			time.Date(2026, time.January, 1, 10, 40, 15, 0, time.UTC),
+		}},
+		{" @every 90s ", []time.Time{time.Date(2026, time.January, 1, 10, 31, 45, 0, time.UTC)}},
+		{"* * * * *", []time.Time{
+			time.Date(2026, time.January, 1, 10, 31, 0, 0, time.UTC),
+			time.Date(2026, time.January, 1, 10, 32, 0, 0, time.UTC),
+		}},
+		{"*/20 * * * *", []time.Time{
+			time.Date(2026, time.January, 1, 10, 40, 0, 0, time.UTC),
+			time.Date(2026, time.January, 1, 11, 0, 0, 0, time.UTC),
+		}},
+		{"15,45 9-11 * * *", []time.Time{
+			time.Date(2026, time.January, 1, 10, 45, 0, 0, time.UTC),
+			time.Date(2026, time.January, 1, 11, 15, 0, 0, time.UTC),
+			time.Date(2026, time.January, 1, 11, 45, 0, 0, time.UTC),
+			time.Date(2026, time.January, 2, 9, 15, 0, 0, time.UTC),
+		}},
+		{"5/30 * * * *", []time.Time{
+			time.Date(2026, time.January, 1, 10, 35, 0, 0, time.UTC),
+			time.Date(2026, time.January, 1, 11, 5, 0, 0, time.UTC),
+		}},
+		{"@hourly", []time.Time{time.Date(2026, time.January, 1, 11, 0, 0, 0, time.UTC)}},
+		{"@daily", []time.Time{time.Date(2026, time.January, 2, 0, 0, 0, 0, time.UTC)}},
+		{"@weekly", []time.Time{time.Date(2026, time.January, 4, 0, 0, 0, 0, time.UTC)}},
+		{"@monthly", []time.Time{time.Date(2026, time.February, 1, 0, 0, 0, 0, time.UTC)}},
+		{"@yearly", []time.Time{time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC)}},
+		// Sunday as 7, and a range of weekdays.
+		{"0 12 * * 7", []time.Time{time.Date(2026, time.January, 4, 12, 0, 0, 0, time.UTC)}},
+		{"0 8 * * 1-5", []time.Time{
+			time.Date(2026, time.January, 2, 8, 0, 0, 0, time.UTC),
+			time.Date(2026, time.January, 5, 8, 0, 0, 0, time.UTC),
+		}},
+		// With both day fields restricted, either one matches.
+		{"0 0 15 * 1", []time.Time{
+			time.Date(2026, time.January, 5, 0, 0, 0, 0, time.UTC),
+			time.Date(2026, time.January, 12, 0, 0, 0, 0, time.UTC),
+			time.Date(2026, time.January, 15, 0, 0, 0, 0, time.UTC),
+			time.Date(2026, time.January, 19, 0, 0, 0, 0, time.UTC),
+		}},
+		{"0 0 29 2 *", []time.Time{time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC)}},
+		{"0 0 31 2 *", []time.Time{{}}},
+	}
+	for _, test := range tests {
+		t.Run(test.spec, func(t *testing.T) {
+			schedule, err := Parse(test.spec)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			var next = after
+			for _, want := range test.want {
+				next = schedule.Next(next)
+				if !next.Equal(want) {
+					t.Fatalf("Next = %s, want %s", next, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	var specs = []string{
+		"",
+		"@every",
+		"@every 500ms",
+		"@every soon",
+		"@fortnightly",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"a * * * *",
+		"1-b * * * *",
+	}
+	for _, spec := range specs {
+		if _, err := Parse(spec); !errors.Is(err, ErrInvalidSchedule) {
+			t.Errorf("Parse(%q) = %v, want ErrInvalidSchedule", spec, err)
+		}
+	}
+}
@@ -0,0 +1,319 @@
+// Package scheduler runs periodic background jobs inside the server process.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobExists   = errors.New("job already exists")
+	ErrJobRunning  = errors.New("job is already running")
+	ErrStopped     = errors.New("scheduler has stopped")
+)
+
+// What started a run.
+const (
+	TriggerSchedule = "schedule"
+	TriggerManual   = "manual"
+)
+
+// Outcomes of a run. A scheduled run is skipped when the previous run of
+// the same job has not finished yet.
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+	OutcomeSkipped = "skipped"
+)
+
+// Func is the work of a job. ctx is cancelled when the scheduler stops.
+type Func func(ctx context.Context) error
+
+// Run is one entry of a job's history.
+type Run struct {
+	Trigger   string
+	StartedAt time.Time
+	Duration  time.Duration
+	Outcome   string
+	Err       error
+}
+
+// Status describes a job. NextRun is zero while the job is paused or when
+// its schedule never matches again.
+type Status struct {
+	Name     string
+	Schedule string
+	Paused   bool
+	Running  bool
+	NextRun  time.Time
+	LastRun  *Run
+}
+
+type job struct {
+	name     string
+	spec     string
+	schedule Schedule
+	fn       Func
+	paused   bool
+	running  bool
+	next     time.Time
+	// history is newest first.
+	history []Run
+}
+
+// Scheduler runs jobs on their schedules while Run is active. Each job runs
+// at most once at a time: a run that comes due while the previous one is
+// still going is skipped and recorded as such. The last history runs of
+// every job are kept.
+type Scheduler struct {
+	mu      sync.Mutex
+	jobs    map[string]*job
+	history int
+	now     func() time.Time
+	wake    chan struct{}
+	runs    sync.WaitGroup
+	// ctx is the context of every run, cancelled once Run returns.
+	ctx     context.Context
+	cancel  context.CancelFunc
+	stopped bool
+}
+
+func New(history int) *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{
+		jobs:    map[string]*job{},
+		history: history,
+		now:     time.Now,
+		wake:    make(chan struct{}, 1),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// SetClock makes the scheduler read the time from now. Runs are still
+// dispatched by real timers, so a clock that does not move only affects the
+// times recorded.
+func (s *Scheduler) SetClock(now func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = now
+}
+
+// Add registers a job under name with a schedule in the syntax of Parse.
+func (s *Scheduler) Add(name string, spec string, fn Func) error {
+	schedule, err := Parse(spec)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[name]; ok {
+		return fmt.Errorf("%w: %s", ErrJobExists, name)
+	}
+	s.jobs[name] = &job{name: name, spec: spec, schedule: schedule, fn: fn}
+	s.notify()
+	return nil
+}
+
+// Run dispatches jobs until ctx is done, then cancels the runs in progress
+// and waits for them to return. Jobs can be triggered before Run starts but
+// not after it returns.
+func (s *Scheduler) Run(ctx context.Context) {
+	defer s.runs.Wait()
+
+	var timer = time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			s.mu.Lock()
+			s.stopped = true
+			s.cancel()
+			s.mu.Unlock()
+			return
+		case <-timer.C:
+		case <-s.wake:
+		}
+		timer.Reset(s.dispatch())
+	}
+}
+
+// dispatch starts every job that is due and returns how long to wait for
+// the next one.
+func (s *Scheduler) dispatch() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var now = s.now()
+	var wait = time.Hour
+	for _, job := range s.jobs {
+		if job.paused {
+			continue
+		}
+		if job.next.IsZero() {
+			job.next = job.schedule.Next(now)
+		}
+		if !job.next.IsZero() && !job.next.After(now) {
+			if job.running {
+				s.record(job, Run{Trigger: TriggerSchedule, StartedAt: now, Outcome: OutcomeSkipped})
+				log.Warnf("job %s: skipped a run, the previous one is still running", job.name)
+			} else {
+				s.start(job, TriggerSchedule)
+			}
+			job.next = job.schedule.Next(now)
+		}
+		if !job.next.IsZero() && job.next.Sub(now) < wait {
+			wait = job.next.Sub(now)
+		}
+	}
+	return wait
+}
+
+// Trigger starts a run of the named job now, whether or not it is paused.
+func (s *Scheduler) Trigger(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[name]
+	if !ok {
+		return ErrJobNotFound
+	}
+	if s.stopped {
+		return ErrStopped
+	}
+	if job.running {
+		return ErrJobRunning
+	}
+	s.start(job, TriggerManual)
+	return nil
+}
+
+// Pause stops scheduled runs of the named job until it is resumed. A run in
+// progress is not interrupted.
+func (s *Scheduler) Pause(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[name]
+	if !ok {
+		return ErrJobNotFound
+	}
+	job.paused = true
+	job.next = time.Time{}
+	return nil
+}
+
+// Resume schedules the named job again. Runs missed while it was paused
+// are not made up.
+func (s *Scheduler) Resume(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[name]
+	if !ok {
+		return ErrJobNotFound
+	}
+	job.paused = false
+	s.notify()
+	return nil
+}
+
+// Jobs describes every job, by name.
+func (s *Scheduler) Jobs() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var statuses = make([]Status, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		statuses = append(statuses, job.status())
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// Job describes the named job and returns its history, newest first.
+func (s *Scheduler) Job(name string) (Status, []Run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[name]
+	if !ok {
+		return Status{}, nil, ErrJobNotFound
+	}
+	return job.status(), append([]Run(nil), job.history...), nil
+}
+
+// start runs job in its own goroutine. s.mu must be held.
+func (s *Scheduler) start(job *job, trigger string) {
+	var ctx = s.ctx
+	var started = s.now()
+	job.running = true
+	s.runs.Add(1)
+	go func() {
+		defer s.runs.Done()
+		var err = call(ctx, job.fn)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		var run = Run{Trigger: trigger, StartedAt: started, Duration: s.now().Sub(started), Outcome: OutcomeSuccess}
+		if err != nil {
+			run.Outcome = OutcomeFailure
+			run.Err = err
+			log.Errorf("job %s: %v", job.name, err)
+		} else {
+			log.Debugf("job %s finished in %s", job.name, run.Duration)
+		}
+		job.running = false
+		s.record(job, run)
+	}()
+}
+
+// call runs fn, turning a panic into an error so that one broken job does
+// not stop the server.
+func call(ctx context.Context, fn Func) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("panic: %v", recovered)
+		}
+	}()
+	return fn(ctx)
+}
+
+// record adds run to the history of job. s.mu must be held.
+func (s *Scheduler) record(job *job, run Run) {
+	job.history = append([]Run{run}, job.history...)
+	if len(job.history) > s.history {
+		job.history = job.history[:s.history]
+	}
+}
+
+// notify wakes Run to recompute the next run times.
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (j *job) status() Status {
+	var status = Status{
+		Name:     j.name,
+		Schedule: j.spec,
+		Paused:   j.paused,
+		Running:  j.running,
+		NextRun:  j.next,
+	}
+	if len(j.history) > 0 {
+		var last = j.history[0]
+		status.LastRun = &last
+	}
+	return status
+}
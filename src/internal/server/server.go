@@ -0,0 +1,164 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golearn/src/internal/config"
+	"net"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Server owns the lifecycle of the HTTP listeners: it starts serving, waits
+// for the context to end and then drains in-flight requests.
+type Server struct {
+	http     *http.Server
+	redirect *http.Server
+	grace    time.Duration
+	delay    time.Duration
+
+	onStarted  []func()
+	onShutdown []func()
+}
+
+func New(cfg config.Server, tlsCfg config.TLS, handler http.Handler) (*Server, error) {
+	var s = &Server{
+		http: &http.Server{
+			Addr:         cfg.Addr,
+			Handler:      handler,
+			ReadTimeout:  cfg.ReadTimeout.Duration,
+			WriteTimeout: cfg.WriteTimeout.Duration,
+		},
+		grace: cfg.ShutdownTimeout.Duration,
+		delay: cfg.ShutdownDelay.Duration,
+	}
+
+	if !tlsCfg.Enabled {
+		return s, nil
+	}
+
+	tlsConfig, err := newTLSConfig(tlsCfg, cfg.Addr)
+	if err != nil {
+		return nil, err
+	}
+	s.http.TLSConfig = tlsConfig
+
+	if tlsCfg.RedirectAddr != "" {
+		s.redirect = &http.Server{
+			Addr:         tlsCfg.RedirectAddr,
+			Handler:      redirectHandler(cfg.Addr),
+			ReadTimeout:  cfg.ReadTimeout.Duration,
+			WriteTimeout: cfg.WriteTimeout.Duration,
+		}
+	}
+
+	return s, nil
+}
+
+// OnStarted registers fn to be called once every listener is accepting
+// connections.
+func (s *Server) OnStarted(fn func()) {
+	s.onStarted = append(s.onStarted, fn)
+}
+
+// OnShutdown registers fn to be called as soon as shutdown begins, before
+// the shutdown delay and before in-flight requests have drained.
+func (s *Server) OnShutdown(fn func()) {
+	s.onShutdown = append(s.onShutdown, fn)
+}
+
+// Run listens on the configured addresses and serves until ctx is done. It
+// returns an error if a listener cannot be opened, if serving fails, or if
+// in-flight requests do not finish within the grace period.
+func (s *Server) Run(ctx context.Context) error {
+	var servers = []*http.Server{s.http}
+	if s.redirect != nil {
+		servers = append(servers, s.redirect)
+	}
+
+	var listeners = make([]net.Listener, 0, len(servers))
+	for _, srv := range servers {
+		listener, err := net.Listen("tcp", srv.Addr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return fmt.Errorf("starting listener: %w", err)
+		}
+		listeners = append(listeners, listener)
+	}
+
+	var serveErr = make(chan error, len(servers))
+	for i, srv := range servers {
+		go func(srv *http.Server, listener net.Listener) {
+			if srv.TLSConfig != nil {
+				serveErr <- srv.ServeTLS(listener, "", "")
+			} else {
+				serveErr <- srv.Serve(listener)
+			}
+		}(srv, listeners[i])
+	}
+
+	if s.http.TLSConfig != nil {
+		log.Infof("listening on https://%s", listeners[0].Addr())
+	} else {
+		log.Infof("listening on http://%s", listeners[0].Addr())
+	}
+	if s.redirect != nil {
+		log.Infof("redirecting http://%s to HTTPS", listeners[1].Addr())
+	}
+	for _, fn := range s.onStarted {
+		fn()
+	}
+
+	var err error
+	select {
+	case err = <-serveErr:
+		log.Errorf("listener failed: %v", err)
+	case <-ctx.Done():
+	}
+
+	for _, fn := range s.onShutdown {
+		fn()
+	}
+	if err == nil && s.delay > 0 {
+		// Keep serving while load balancers notice the failing readiness probe.
+		log.Infof("shutting down, still serving for %s", s.delay)
+		select {
+		case err = <-serveErr:
+			log.Errorf("listener failed: %v", err)
+		case <-time.After(s.delay):
+		}
+	}
+	log.Infof("shutting down, draining in-flight requests for up to %s", s.grace)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.grace)
+	defer cancel()
+
+	var drainErr error
+	for _, srv := range servers {
+		if shutdownErr := srv.Shutdown(shutdownCtx); shutdownErr != nil {
+			log.Warnf("grace period expired, closing remaining connections: %v", shutdownErr)
+			srv.Close()
+			drainErr = fmt.Errorf("draining requests: %w", shutdownErr)
+		}
+	}
+
+	var remaining = len(servers)
+	if err != nil {
+		remaining--
+	}
+	for ; remaining > 0; remaining-- {
+		if srvErr := <-serveErr; !errors.Is(srvErr, http.ErrServerClosed) && err == nil {
+			err = srvErr
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+	return drainErr
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"golearn/src/internal/config"
+	"net"
+	"net/http"
+)
+
+// modernCipherSuites are the TLS 1.2 suites allowed by the "modern" policy:
+// forward-secret key exchange with AEAD ciphers only. TLS 1.3 suites are not
+// configurable and are always enabled.
+var modernCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+// newTLSConfig loads or generates the server certificate and applies the
+// version and cipher policy from cfg. addr is the HTTPS listen address, used
+// to name the dev certificate.
+func newTLSConfig(cfg config.TLS, addr string) (*tls.Config, error) {
+	var cert tls.Certificate
+	var err error
+
+	if cfg.CertFile != "" {
+		cert, err = tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading TLS certificate: %w", err)
+		}
+	} else {
+		cert, err = loadOrCreateDevCertificate(cfg.DevCertDir, devCertificateHosts(addr))
+		if err != nil {
+			return nil, fmt.Errorf("preparing dev certificate: %w", err)
+		}
+	}
+
+	var tlsConfig = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if cfg.MinVersion == "1.3" {
+		tlsConfig.MinVersion = tls.VersionTLS13
+	}
+	if cfg.CipherPolicy == "modern" {
+		tlsConfig.CipherSuites = modernCipherSuites
+	}
+
+	return tlsConfig, nil
+}
+
+// redirectHandler sends every request to the same path on the HTTPS listener.
+func redirectHandler(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var host = r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+		if port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+
+		var target = "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	})
+}
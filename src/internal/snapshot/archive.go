@@ -0,0 +1,303 @@
+package snapshot
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golearn/src/internal/bulk"
+	"golearn/src/internal/tenant"
+	"golearn/src/internal/tools"
+	"hash"
+	"io"
+	"sort"
+	"time"
+)
+
+// An archive is a gzip-compressed stream of JSON lines: a header with the
+// Metadata, one line per account, and a trailer with the SHA-256 of every
+// uncompressed byte before it. Version is bumped whenever the layout
+// changes; Read refuses archives from newer versions.
+const (
+	FormatName = "golearn-snapshot"
+	Version    = 1
+)
+
+var ErrChecksum = errors.New("snapshot checksum does not match its contents")
+
+// Metadata is the header of an archive.
+type Metadata struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Label     string    `json:"label,omitempty"`
+	Tenants   int       `json:"tenants"`
+	Accounts  int       `json:"accounts"`
+}
+
+type record struct {
+	Tenant   string         `json:"tenant"`
+	ID       string         `json:"id"`
+	Username string         `json:"username"`
+	Name     string         `json:"name"`
+	Token    string         `json:"token"`
+	Roles    []string       `json:"roles,omitempty"`
+	Wallets  []walletRecord `json:"wallets,omitempty"`
+	Holds    []holdRecord   `json:"holds,omitempty"`
+	Renames  []renameRecord `json:"renames,omitempty"`
+	Ledger   []ledgerRecord `json:"ledger,omitempty"`
+}
+
+type walletRecord struct {
+	Name      string `json:"name"`
+	Currency  string `json:"currency"`
+	Precision int    `json:"precision"`
+	Balance   int64  `json:"balance"`
+}
+
+type holdRecord struct {
+	ID        string    `json:"id"`
+	Wallet    string    `json:"wallet"`
+	Amount    int64     `json:"amount"`
+	Captured  int64     `json:"captured"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type renameRecord struct {
+	From string    `json:"from"`
+	To   string    `json:"to"`
+	At   time.Time `json:"at"`
+}
+
+type ledgerRecord struct {
+	Wallet    string    `json:"wallet"`
+	Kind      string    `json:"kind"`
+	Amount    int64     `json:"amount"`
+	Balance   int64     `json:"balance"`
+	At        time.Time `json:"at"`
+	Reference string    `json:"reference,omitempty"`
+}
+
+type trailer struct {
+	SHA256 string `json:"sha256"`
+}
+
+// Write encodes data as an archive. The counts in meta are filled in from
+// data.
+func Write(w io.Writer, meta Metadata, data tools.Dataset) error {
+	meta.Format = FormatName
+	meta.Version = Version
+	meta.Tenants = len(data)
+	meta.Accounts = 0
+	for _, accounts := range data {
+		meta.Accounts += len(accounts)
+	}
+
+	var zw = gzip.NewWriter(w)
+	var sum = sha256.New()
+	var encoder = json.NewEncoder(io.MultiWriter(zw, sum))
+
+	if err := encoder.Encode(meta); err != nil {
+		return err
+	}
+
+	var tenantIDs = make([]string, 0, len(data))
+	for tenantID := range data {
+		tenantIDs = append(tenantIDs, tenantID)
+	}
+	sort.Strings(tenantIDs)
+	for _, tenantID := range tenantIDs {
+		for _, account := range data[tenantID] {
+			var rec = record{
+				Tenant:   tenantID,
+				ID:       account.ID,
+				Username: account.Username,
+				Name:     account.Name,
+				Token:    account.AuthToken,
+				Roles:    account.Roles,
+			}
+			for _, wallet := range account.Wallets {
+				rec.Wallets = append(rec.Wallets, walletRecord(wallet))
+			}
+			for _, hold := range account.Holds {
+				rec.Holds = append(rec.Holds, holdRecord(hold))
+			}
+			for _, rename := range account.Renames {
+				rec.Renames = append(rec.Renames, renameRecord(rename))
+			}
+			for _, entry := range account.Ledger {
+				rec.Ledger = append(rec.Ledger, ledgerRecord(entry))
+			}
+			if err := encoder.Encode(rec); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := json.NewEncoder(zw).Encode(trailer{SHA256: hex.EncodeToString(sum.Sum(nil))}); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// ReadMetadata decodes only the header of an archive.
+func ReadMetadata(r io.Reader) (Metadata, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("not a snapshot archive: %w", err)
+	}
+	defer zr.Close()
+
+	var reader = newLineReader(zr)
+	return reader.header()
+}
+
+// Read decodes and fully validates an archive: its format and version, the
+// checksum, the counts in the header and every account. Nothing is returned
+// unless the whole archive is sound.
+func Read(r io.Reader) (Metadata, tools.Dataset, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return Metadata{}, nil, fmt.Errorf("not a snapshot archive: %w", err)
+	}
+	defer zr.Close()
+
+	var reader = newLineReader(zr)
+	meta, err := reader.header()
+	if err != nil {
+		return Metadata{}, nil, err
+	}
+
+	var data = tools.Dataset{}
+	var seen = map[string]bool{}
+	var accounts int
+	for {
+		line, err := reader.next()
+		if errors.Is(err, io.EOF) {
+			return meta, nil, errors.New("snapshot is truncated: missing trailer")
+		}
+		if err != nil {
+			return meta, nil, err
+		}
+
+		// The trailer is the only line with a sha256 field.
+		var end trailer
+		if json.Unmarshal(line, &end) == nil && end.SHA256 != "" {
+			if hex.EncodeToString(reader.sum.Sum(nil)) != end.SHA256 {
+				return meta, nil, ErrChecksum
+			}
+			if _, err := reader.next(); !errors.Is(err, io.EOF) {
+				return meta, nil, errors.New("snapshot has data after its trailer")
+			}
+			break
+		}
+		reader.sum.Write(line)
+		reader.sum.Write([]byte{'\n'})
+
+		var rec record
+		var decoder = json.NewDecoder(bytes.NewReader(line))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&rec); err != nil {
+			return meta, nil, fmt.Errorf("snapshot line %d: %w", reader.line, err)
+		}
+		if !tenant.Valid(rec.Tenant) {
+			return meta, nil, fmt.Errorf("snapshot line %d: %w %q", reader.line, tenant.ErrInvalidTenant, rec.Tenant)
+		}
+		if rec.ID == "" {
+			return meta, nil, fmt.Errorf("snapshot line %d: missing user ID", reader.line)
+		}
+		var account = tools.Account{
+			ID:        rec.ID,
+			Username:  rec.Username,
+			Name:      rec.Name,
+			AuthToken: rec.Token,
+			Roles:     rec.Roles,
+		}
+		for _, wallet := range rec.Wallets {
+			account.Wallets = append(account.Wallets, tools.Wallet(wallet))
+		}
+		for _, hold := range rec.Holds {
+			account.Holds = append(account.Holds, tools.Hold(hold))
+		}
+		if err := bulk.Validate(account); err != nil {
+			return meta, nil, fmt.Errorf("snapshot line %d: %w", reader.line, err)
+		}
+		for _, rename := range rec.Renames {
+			account.Renames = append(account.Renames, tools.Rename(rename))
+		}
+		for _, entry := range rec.Ledger {
+			account.Ledger = append(account.Ledger, tools.LedgerEntry(entry))
+		}
+		// Usernames cannot look like IDs, so both share one set.
+		for _, key := range []string{rec.Tenant + "/" + rec.Username, rec.Tenant + "/" + rec.ID} {
+			if seen[key] {
+				return meta, nil, fmt.Errorf("snapshot line %d: duplicate account %s", reader.line, key)
+			}
+			seen[key] = true
+		}
+
+		data[rec.Tenant] = append(data[rec.Tenant], account)
+		accounts++
+	}
+
+	if accounts != meta.Accounts || len(data) > meta.Tenants {
+		return meta, nil, fmt.Errorf("snapshot holds %d accounts in %d tenants, header says %d in %d",
+			accounts, len(data), meta.Accounts, meta.Tenants)
+	}
+	// Tenants without accounts have no lines but still exist.
+	return meta, data, nil
+}
+
+// lineReader reads the uncompressed lines of an archive and hashes them as
+// they are consumed.
+type lineReader struct {
+	scanner *bufio.Scanner
+	sum     hash.Hash
+	line    int
+}
+
+func newLineReader(r io.Reader) *lineReader {
+	var scanner = bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	return &lineReader{scanner: scanner, sum: sha256.New()}
+}
+
+func (r *lineReader) next() ([]byte, error) {
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return nil, fmt.Errorf("reading snapshot: %w", err)
+		}
+		return nil, io.EOF
+	}
+	r.line++
+	return r.scanner.Bytes(), nil
+}
+
+func (r *lineReader) header() (Metadata, error) {
+	line, err := r.next()
+	if errors.Is(err, io.EOF) {
+		return Metadata{}, errors.New("snapshot is empty")
+	}
+	if err != nil {
+		return Metadata{}, err
+	}
+	r.sum.Write(line)
+	r.sum.Write([]byte{'\n'})
+
+	var meta Metadata
+	if err := json.Unmarshal(line, &meta); err != nil || meta.Format != FormatName {
+		return Metadata{}, errors.New("not a snapshot archive: missing header")
+	}
+	if meta.Version > Version {
+		return meta, fmt.Errorf("snapshot version %d was written by a newer golearn (this one reads up to %d)", meta.Version, Version)
+	}
+	if meta.Version < 1 {
+		return meta, fmt.Errorf("invalid snapshot version %d", meta.Version)
+	}
+	return meta, nil
+}
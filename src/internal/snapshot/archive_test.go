@@ -0,0 +1,225 @@
+package snapshot
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"golearn/src/internal/tools"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testTime = time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+func testDataset() tools.Dataset {
+	return tools.Dataset{
+		"default": {
+			{
+				ID:        "usr_5a7c0e3b9d12f864",
+				Username:  "damien",
+				Name:      "Damien",
+				AuthToken: "ABC123",
+				Wallets: []tools.Wallet{
+					tools.PointsWallet(100),
+					{Name: "eur", Currency: "EUR", Precision: 2, Balance: 1050},
+				},
+				Holds: []tools.Hold{{
+					ID: "hld_0123456789abcdef", Wallet: "points", Amount: 20,
+					CreatedAt: testTime, ExpiresAt: testTime.Add(time.Hour),
+				}},
+				Renames: []tools.Rename{{From: "dami", To: "damien", At: testTime}},
+				Ledger: []tools.LedgerEntry{
+					{Wallet: "points", Kind: tools.LedgerCredit, Amount: 100, Balance: 100, At: testTime},
+				},
+			},
+			{ID: "usr_0000000000000001", Username: "admin", Name: "Admin", AuthToken: "JKL012", Roles: []string{tools.RoleAdmin}},
+		},
+		"acme": {
+			{ID: "usr_0000000000000002", Username: "carol", Name: "Carol", AuthToken: "PQR678", Wallets: []tools.Wallet{tools.PointsWallet(7)}},
+		},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	var archive bytes.Buffer
+	if err := Write(&archive, Metadata{CreatedAt: testTime, Label: "nightly"}, testDataset()); err != nil {
+		t.Fatal(err)
+	}
+
+	meta, data, err := Read(bytes.NewReader(archive.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want = Metadata{Format: FormatName, Version: Version, CreatedAt: testTime, Label: "nightly", Tenants: 2, Accounts: 3}
+	if meta != want {
+		t.Errorf("metadata = %+v, want %+v", meta, want)
+	}
+	if !reflect.DeepEqual(data, testDataset()) {
+		t.Errorf("dataset = %+v, want %+v", data, testDataset())
+	}
+
+	header, err := ReadMetadata(bytes.NewReader(archive.Bytes()))
+	if err != nil || header != want {
+		t.Errorf("ReadMetadata = %+v, %v; want %+v", header, err, want)
+	}
+}
+
+// rewrite applies edit to the uncompressed lines of archive. With rehash,
+// the trailer is recomputed so that only the edit itself can be rejected.
+func rewrite(t *testing.T, archive []byte, edit func(lines []string) []string, rehash bool) []byte {
+	t.Helper()
+
+	zr, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines = edit(strings.Split(strings.TrimSuffix(string(raw), "\n"), "\n"))
+	if rehash {
+		var body = strings.Join(lines[:len(lines)-1], "\n") + "\n"
+		var sum = sha256.Sum256([]byte(body))
+		lines[len(lines)-1] = `{"sha256":"` + hex.EncodeToString(sum[:]) + `"}`
+	}
+
+	var out bytes.Buffer
+	var zw = gzip.NewWriter(&out)
+	zw.Write([]byte(strings.Join(lines, "\n") + "\n"))
+	zw.Close()
+	return out.Bytes()
+}
+
+func TestReadRejects(t *testing.T) {
+	var archive bytes.Buffer
+	if err := Write(&archive, Metadata{CreatedAt: testTime}, testDataset()); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name    string
+		edit    func(lines []string) []string
+		rehash  bool
+		wantErr error
+		wantMsg string
+	}{
+		{
+			name: "edited balance",
+			edit: func(lines []string) []string {
+				lines[2] = strings.Replace(lines[2], `"balance":100`, `"balance":1000000`, 1)
+				return lines
+			},
+			wantErr: ErrChecksum,
+		},
+		{
+			name: "edited header",
+			edit: func(lines []string) []string {
+				lines[0] = strings.Replace(lines[0], `"tenants":2`, `"tenants":3`, 1)
+				return lines
+			},
+			wantErr: ErrChecksum,
+		},
+		{
+			name: "removed account",
+			edit: func(lines []string) []string {
+				return append(lines[:1], lines[2:]...)
+			},
+			wantErr: ErrChecksum,
+		},
+		{
+			name: "missing trailer",
+			edit: func(lines []string) []string {
+				return lines[:len(lines)-1]
+			},
+			wantMsg: "truncated",
+		},
+		{
+			name: "data after trailer",
+			edit: func(lines []string) []string {
+				return append(lines, lines[1])
+			},
+			wantMsg: "after its trailer",
+		},
+		{
+			name: "account count",
+			edit: func(lines []string) []string {
+				return append(lines[:1], lines[2:]...)
+			},
+			rehash:  true,
+			wantMsg: "header says 3",
+		},
+		{
+			name: "duplicate account",
+			edit: func(lines []string) []string {
+				lines[3] = strings.Replace(lines[3], `"username":"admin"`, `"username":"damien"`, 1)
+				return lines
+			},
+			rehash:  true,
+			wantMsg: "duplicate account default/damien",
+		},
+		{
+			name: "newer version",
+			edit: func(lines []string) []string {
+				lines[0] = strings.Replace(lines[0], `"version":1`, `"version":2`, 1)
+				return lines
+			},
+			rehash:  true,
+			wantMsg: "newer golearn",
+		},
+		{
+			name: "unknown field",
+			edit: func(lines []string) []string {
+				lines[1] = strings.Replace(lines[1], `{`, `{"extra":true,`, 1)
+				return lines
+			},
+			rehash:  true,
+			wantMsg: "unknown field",
+		},
+		{
+			name: "missing user ID",
+			edit: func(lines []string) []string {
+				lines[1] = strings.Replace(lines[1], `"id":"usr_0000000000000002"`, `"id":""`, 1)
+				return lines
+			},
+			rehash:  true,
+			wantMsg: "missing user ID",
+		},
+		{
+			name: "invalid account",
+			edit: func(lines []string) []string {
+				lines[1] = strings.Replace(lines[1], `"token":"PQR678"`, `"token":""`, 1)
+				return lines
+			},
+			rehash:  true,
+			wantMsg: "token must not be empty",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var edited = rewrite(t, archive.Bytes(), test.edit, test.rehash)
+			_, data, err := Read(bytes.NewReader(edited))
+			if err == nil {
+				t.Fatal("Read accepted the archive")
+			}
+			if data != nil {
+				t.Errorf("Read returned accounts along with %v", err)
+			}
+			if test.wantErr != nil && !errors.Is(err, test.wantErr) {
+				t.Errorf("Read = %v, want %v", err, test.wantErr)
+			}
+			if !strings.Contains(err.Error(), test.wantMsg) {
+				t.Errorf("Read = %v, want it to mention %q", err, test.wantMsg)
+			}
+		})
+	}
+
+	if _, _, err := Read(strings.NewReader("not gzip")); err == nil {
+		t.Error("Read accepted a file that is not gzip")
+	}
+}
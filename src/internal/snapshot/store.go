@@ -0,0 +1,192 @@
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golearn/src/internal/tools"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const fileSuffix = ".snap"
+
+var (
+	ErrNotFound     = errors.New("snapshot not found")
+	ErrInvalidName  = errors.New("invalid snapshot name")
+	ErrInvalidLabel = errors.New("label must be at most 40 lowercase letters, digits or dashes")
+)
+
+var (
+	validName  = regexp.MustCompile(`^[0-9]{8}T[0-9]{9}Z(-[a-z0-9-]+)?\.snap$`)
+	validLabel = regexp.MustCompile(`^[a-z0-9-]{0,40}$`)
+)
+
+// Info describes an archive in the snapshot directory. Err is set, and the
+// metadata empty, when its header cannot be read.
+type Info struct {
+	Name string
+	Size int64
+	Metadata
+	Err error
+}
+
+// Store keeps archives in a directory. Creating and restoring are
+// serialised so that a restore never races a snapshot of the same store.
+type Store struct {
+	dir string
+	now func() time.Time
+	mu  sync.Mutex
+}
+
+func NewStore(dir string) *Store {
+	return &Store{dir: dir, now: time.Now}
+}
+
+// SetClock makes the store date new archives by now instead of the system
+// clock.
+func (s *Store) SetClock(now func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = now
+}
+
+// Create takes a consistent snapshot of database and writes it to a new
+// archive. The file only appears under its final name once complete.
+func (s *Store) Create(ctx context.Context, database tools.DatabaseInterface, label string) (Info, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.create(ctx, database, label)
+}
+
+func (s *Store) create(ctx context.Context, database tools.DatabaseInterface, label string) (Info, error) {
+	if !validLabel.MatchString(label) {
+		return Info{}, ErrInvalidLabel
+	}
+
+	data, err := database.Snapshot(ctx)
+	if err != nil {
+		return Info{}, fmt.Errorf("reading store: %w", err)
+	}
+
+	if err = os.MkdirAll(s.dir, 0o700); err != nil {
+		return Info{}, err
+	}
+
+	var createdAt = s.now().UTC()
+	var name = strings.Replace(createdAt.Format("20060102T150405.000Z"), ".", "", 1)
+	if label != "" {
+		name += "-" + label
+	}
+	name += fileSuffix
+
+	temp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return Info{}, err
+	}
+	defer os.Remove(temp.Name())
+
+	var meta = Metadata{CreatedAt: createdAt, Label: label}
+	err = Write(temp, meta, data)
+	if err == nil {
+		err = temp.Sync()
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(temp.Name(), filepath.Join(s.dir, name))
+	}
+	if err != nil {
+		return Info{}, fmt.Errorf("writing snapshot: %w", err)
+	}
+
+	return s.info(name)
+}
+
+// List returns every archive in the directory, newest first.
+func (s *Store) List() ([]Info, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []Info{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var infos = []Info{}
+	for _, entry := range entries {
+		if entry.IsDir() || !validName.MatchString(entry.Name()) {
+			continue
+		}
+		info, err := s.info(entry.Name())
+		if err != nil && info.Name == "" {
+			continue
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name > infos[j].Name })
+	return infos, nil
+}
+
+// Restore validates the named archive completely and only then replaces the
+// contents of database with it. The current contents are first saved as a
+// "pre-restore" snapshot, returned as backup, so a restore can be undone.
+func (s *Store) Restore(ctx context.Context, database tools.DatabaseInterface, name string) (restored Info, backup Info, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	restored, err = s.info(name)
+	if err != nil {
+		return restored, backup, err
+	}
+
+	file, err := os.Open(filepath.Join(s.dir, name))
+	if err != nil {
+		return restored, backup, err
+	}
+	_, data, err := Read(file)
+	file.Close()
+	if err != nil {
+		return restored, backup, fmt.Errorf("%s: %w", name, err)
+	}
+
+	backup, err = s.create(ctx, database, "pre-restore")
+	if err != nil {
+		return restored, backup, fmt.Errorf("saving current data before restore: %w", err)
+	}
+
+	if err = database.Restore(ctx, data); err != nil {
+		return restored, backup, fmt.Errorf("restoring %s: %w", name, err)
+	}
+	return restored, backup, nil
+}
+
+// info reads the header of the named archive.
+func (s *Store) info(name string) (Info, error) {
+	if !validName.MatchString(name) {
+		return Info{}, ErrInvalidName
+	}
+
+	file, err := os.Open(filepath.Join(s.dir, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return Info{}, ErrNotFound
+	}
+	if err != nil {
+		return Info{}, err
+	}
+	defer file.Close()
+
+	var info = Info{Name: name}
+	if stat, err := file.Stat(); err == nil {
+		info.Size = stat.Size()
+	}
+	info.Metadata, info.Err = ReadMetadata(file)
+	return info, info.Err
+}
@@ -0,0 +1,100 @@
+package statement
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"golearn/src/api"
+	htmltemplate "html/template"
+	"io"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// Formats a statement can be rendered in.
+const (
+	FormatHTML = "html"
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+//go:embed templates
+var templateFiles embed.FS
+
+var funcs = map[string]any{
+	"amount":   api.FormatAmount,
+	"signed":   api.FormatSignedAmount,
+	"date":     formatTime("2 Jan 2006"),
+	"datetime": formatTime("2006-01-02 15:04 MST"),
+	// through shows the exclusive end of a period as its last day.
+	"through": func(value string) string {
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return value
+		}
+		return t.AddDate(0, 0, -1).Format("2 Jan 2006")
+	},
+}
+
+var (
+	htmlTemplate = htmltemplate.Must(htmltemplate.New("statement.html").Funcs(funcs).ParseFS(templateFiles, "templates/statement.html"))
+	textTemplate = texttemplate.Must(texttemplate.New("statement.txt").Funcs(funcs).ParseFS(templateFiles, "templates/statement.txt"))
+)
+
+// ParseFormat checks that format is supported.
+func ParseFormat(format string) (string, error) {
+	switch format = strings.ToLower(format); format {
+	case FormatHTML, FormatText, FormatJSON:
+		return format, nil
+	case "txt":
+		return FormatText, nil
+	}
+	return "", fmt.Errorf("unsupported format %q, expected html, text or json", format)
+}
+
+// ContentType is the media type of format.
+func ContentType(format string) string {
+	switch format {
+	case FormatHTML:
+		return "text/html; charset=utf-8"
+	case FormatText:
+		return "text/plain; charset=utf-8"
+	}
+	return "application/json"
+}
+
+// Filename names the file a statement is saved to, such as
+// statement-damien-2026-09.html.
+func Filename(statement api.Statement, format string) string {
+	var extension = format
+	if format == FormatText {
+		extension = "txt"
+	}
+	return "statement-" + statement.Username + "-" + statement.Period + "." + extension
+}
+
+// Render writes statement to w in format.
+func Render(w io.Writer, format string, statement api.Statement) error {
+	switch format {
+	case FormatHTML:
+		return htmlTemplate.Execute(w, statement)
+	case FormatText:
+		return textTemplate.Execute(w, statement)
+	case FormatJSON:
+		var encoder = json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(statement)
+	}
+	return fmt.Errorf("unsupported format %q", format)
+}
+
+func formatTime(layout string) func(string) string {
+	return func(value string) string {
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return value
+		}
+		return t.UTC().Format(layout)
+	}
+}
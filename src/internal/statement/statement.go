@@ -0,0 +1,116 @@
+// Package statement builds monthly account statements from the ledger and
+// renders them as HTML or plain text.
+package statement
+
+import (
+	"errors"
+	"fmt"
+	"golearn/src/api"
+	"golearn/src/internal/tools"
+	"time"
+)
+
+var (
+	ErrInvalidPeriod = errors.New("period must be a month written as YYYY-MM")
+	ErrFuturePeriod  = errors.New("period has not started yet")
+)
+
+const periodLayout = "2006-01"
+
+// Period is a calendar month in UTC.
+type Period struct {
+	start time.Time
+}
+
+func ParsePeriod(s string) (Period, error) {
+	start, err := time.Parse(periodLayout, s)
+	if err != nil {
+		return Period{}, fmt.Errorf("%w, got %q", ErrInvalidPeriod, s)
+	}
+	return Period{start: start}, nil
+}
+
+// PeriodOf returns the month that contains t.
+func PeriodOf(t time.Time) Period {
+	t = t.UTC()
+	return Period{start: time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (p Period) String() string {
+	return p.start.Format(periodLayout)
+}
+
+func (p Period) Start() time.Time {
+	return p.start
+}
+
+// End is the start of the following month.
+func (p Period) End() time.Time {
+	return p.start.AddDate(0, 1, 0)
+}
+
+func (p Period) Previous() Period {
+	return Period{start: p.start.AddDate(0, -1, 0)}
+}
+
+// Build summarises the wallets of account over period from its ledger, as
+// seen at now. Closing balances are worked back from the current ones, so
+// accounts whose early history predates the ledger still get statements;
+// their opening and closing balances are then the same. It fails with
+// ErrFuturePeriod if the period starts after now.
+func Build(account tools.Account, period Period, now time.Time) (api.Statement, error) {
+	if !period.Start().Before(now) {
+		return api.Statement{}, fmt.Errorf("%w: %s", ErrFuturePeriod, period)
+	}
+
+	var statement = api.Statement{
+		UserID:      account.ID,
+		Username:    account.Username,
+		Name:        account.Name,
+		Period:      period.String(),
+		From:        period.Start().Format(time.RFC3339Nano),
+		To:          period.End().Format(time.RFC3339Nano),
+		GeneratedAt: now.UTC().Format(time.RFC3339Nano),
+		Wallets:     make([]api.StatementWallet, 0, len(account.Wallets)),
+	}
+	for _, wallet := range account.Wallets {
+		var summary = api.StatementWallet{
+			Name:      wallet.Name,
+			Currency:  wallet.Currency,
+			Precision: wallet.Precision,
+			Closing:   wallet.Balance,
+			Entries:   []api.StatementEntry{},
+		}
+		var change int64
+		for _, entry := range account.Ledger {
+			if entry.Wallet != wallet.Name || entry.At.Before(period.Start()) {
+				continue
+			}
+			if !entry.At.Before(period.End()) {
+				summary.Closing -= entry.Amount
+				continue
+			}
+			change += entry.Amount
+			switch entry.Kind {
+			case tools.LedgerCredit:
+				summary.Earnings += entry.Amount
+			case tools.LedgerDebit, tools.LedgerCapture:
+				summary.Redemptions -= entry.Amount
+			case tools.LedgerExpiry:
+				summary.Expiries -= entry.Amount
+			default:
+				summary.Adjustments += entry.Amount
+			}
+			summary.Entries = append(summary.Entries, api.StatementEntry{
+				At:        entry.At.Format(time.RFC3339Nano),
+				Kind:      entry.Kind,
+				Amount:    entry.Amount,
+				Balance:   entry.Balance,
+				Reference: entry.Reference,
+			})
+		}
+		summary.Opening = summary.Closing - change
+		statement.Wallets = append(statement.Wallets, summary)
+	}
+	return statement, nil
+}
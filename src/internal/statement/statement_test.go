@@ -0,0 +1,70 @@
+package statement
+
+import (
+	"bytes"
+	"errors"
+	"golearn/src/internal/tools"
+	"strings"
+	"testing"
+	"time"
+)
+
+func at(day int) time.Time {
+	return time.Date(2026, time.September, day, 12, 0, 0, 0, time.UTC)
+}
+
+func TestBuild(t *testing.T) {
+	var account = tools.Account{
+		ID:       "usr_00000000000000a1",
+		Username: "alice",
+		Wallets:  []tools.Wallet{tools.PointsWallet(155)},
+		Ledger: []tools.LedgerEntry{
+			{Wallet: tools.DefaultWallet, Kind: tools.LedgerCredit, Amount: 100, Balance: 100, At: at(1).AddDate(0, -1, 0)},
+			{Wallet: tools.DefaultWallet, Kind: tools.LedgerCredit, Amount: 50, Balance: 150, At: at(2)},
+			{Wallet: tools.DefaultWallet, Kind: tools.LedgerDebit, Amount: -20, Balance: 130, At: at(3)},
+			{Wallet: tools.DefaultWallet, Kind: tools.LedgerCapture, Amount: -5, Balance: 125, At: at(4)},
+			{Wallet: tools.DefaultWallet, Kind: tools.LedgerExpiry, Amount: -15, Balance: 110, At: at(5)},
+			{Wallet: tools.DefaultWallet, Kind: tools.LedgerAdjustment, Amount: 30, Balance: 140, At: at(6), Reference: "goodwill"},
+			{Wallet: tools.DefaultWallet, Kind: tools.LedgerCredit, Amount: 15, Balance: 155, At: at(1).AddDate(0, 1, 0)},
+		},
+	}
+	period, err := ParsePeriod("2026-09")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statement, err := Build(account, period, at(1).AddDate(0, 2, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got = statement.Wallets[0]
+	if got.Opening != 100 || got.Earnings != 50 || got.Redemptions != 25 || got.Expiries != 15 ||
+		got.Adjustments != 30 || got.Closing != 140 || len(got.Entries) != 5 {
+		t.Fatalf("summary = %+v", got)
+	}
+	if got.Opening+got.Earnings-got.Redemptions-got.Expiries+got.Adjustments != got.Closing {
+		t.Fatalf("the summary does not add up to the closing balance: %+v", got)
+	}
+
+	if _, err := Build(account, period, period.Start()); !errors.Is(err, ErrFuturePeriod) {
+		t.Fatalf("Build before the period = %v, want ErrFuturePeriod", err)
+	}
+}
+
+func TestRenderExpiries(t *testing.T) {
+	var account = tools.Account{ID: "usr_00000000000000a1", Username: "alice", Wallets: []tools.Wallet{tools.PointsWallet(10)}}
+	statement, err := Build(account, PeriodOf(at(1)), at(30))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Expiries are shown even when there were none.
+	for _, format := range []string{FormatHTML, FormatText} {
+		var out bytes.Buffer
+		if err := Render(&out, format, statement); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(out.String(), "Expiries") {
+			t.Errorf("%s statement has no expiries line:\n%s", format, out.String())
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golearn/src/internal/config"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrNoTenant       = errors.New("no tenant in context")
+	ErrUnknownTenant  = errors.New("tenant could not be determined")
+	ErrInvalidTenant  = errors.New("invalid tenant id")
+	ErrTenantMismatch = errors.New("tenant header does not match host")
+)
+
+// validID restricts tenant IDs to DNS-label-like strings so they are safe in
+// hosts, keys, logs and file names.
+var validID = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+func Valid(id string) bool {
+	return validID.MatchString(id)
+}
+
+type contextKey struct{}
+
+// NewContext returns a context scoped to tenant id.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the tenant the context is scoped to.
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(contextKey{}).(string)
+	return id, ok && id != ""
+}
+
+// MustFromContext returns the tenant of ctx or ErrNoTenant. Stores call it
+// so that an unscoped call fails instead of reading another tenant's data.
+func MustFromContext(ctx context.Context) (string, error) {
+	id, ok := FromContext(ctx)
+	if !ok {
+		return "", ErrNoTenant
+	}
+	return id, nil
+}
+
+// Resolver determines the tenant of a request from its host or the tenant
+// header, falling back to the default tenant.
+type Resolver struct {
+	header        string
+	hostSuffix    string
+	hosts         map[string]string
+	defaultTenant string
+}
+
+// NewResolver checks the tenant IDs in cfg, which config.Validate cannot
+// since config does not know what a valid tenant ID is.
+func NewResolver(cfg config.Tenancy) (*Resolver, error) {
+	if cfg.DefaultTenant != "" && !Valid(cfg.DefaultTenant) {
+		return nil, fmt.Errorf("tenancy.default_tenant: %w %q", ErrInvalidTenant, cfg.DefaultTenant)
+	}
+	var r = &Resolver{
+		header:        cfg.Header,
+		hostSuffix:    strings.ToLower(strings.TrimPrefix(cfg.HostSuffix, ".")),
+		hosts:         map[string]string{},
+		defaultTenant: cfg.DefaultTenant,
+	}
+	for _, pair := range cfg.Hosts {
+		host, id, ok := strings.Cut(pair, "=")
+		if !ok || !Valid(id) {
+			return nil, fmt.Errorf("tenancy.hosts: %q is not host=tenant", pair)
+		}
+		r.hosts[strings.ToLower(host)] = id
+	}
+	return r, nil
+}
+
+// Resolve returns the tenant of r. A tenant derived from the host cannot be
+// overridden by the header.
+func (res *Resolver) Resolve(r *http.Request) (string, error) {
+	return res.resolve(r, r.Header.Get(res.header))
+}
+
+// ResolveClaim is Resolve with claimed in place of the tenant header, for
+// clients that cannot set headers, such as browsers submitting a form.
+func (res *Resolver) ResolveClaim(r *http.Request, claimed string) (string, error) {
+	return res.resolve(r, claimed)
+}
+
+func (res *Resolver) resolve(r *http.Request, claimed string) (string, error) {
+	var fromHost = res.fromHost(r.Host)
+	var fromHeader = strings.TrimSpace(claimed)
+
+	if fromHeader != "" && !Valid(fromHeader) {
+		return "", ErrInvalidTenant
+	}
+
+	switch {
+	case fromHost != "" && fromHeader != "" && fromHost != fromHeader:
+		return "", ErrTenantMismatch
+	case fromHost != "":
+		return fromHost, nil
+	case fromHeader != "":
+		return fromHeader, nil
+	case res.defaultTenant != "":
+		return res.defaultTenant, nil
+	}
+	return "", ErrUnknownTenant
+}
+
+func (res *Resolver) fromHost(hostport string) string {
+	var host = strings.ToLower(hostport)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	if id, ok := res.hosts[host]; ok {
+		return id
+	}
+	if res.hostSuffix != "" {
+		if sub, ok := strings.CutSuffix(host, "."+res.hostSuffix); ok && Valid(sub) {
+			return sub
+		}
+	}
+	return ""
+}
@@ -0,0 +1,87 @@
+package tenant
+
+import (
+	"errors"
+	"golearn/src/internal/config"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNewResolver(t *testing.T) {
+	var tests = []struct {
+		name    string
+		cfg     config.Tenancy
+		wantErr string
+	}{
+		{name: "valid", cfg: config.Tenancy{DefaultTenant: "default", Hosts: []string{"acme.example=acme"}}},
+		{name: "no default tenant", cfg: config.Tenancy{}},
+		{name: "invalid default tenant", cfg: config.Tenancy{DefaultTenant: "Not A Tenant"}, wantErr: "tenancy.default_tenant"},
+		{name: "invalid host tenant", cfg: config.Tenancy{Hosts: []string{"acme.example=ACME"}}, wantErr: "tenancy.hosts"},
+		{name: "host without tenant", cfg: config.Tenancy{Hosts: []string{"acme.example"}}, wantErr: "tenancy.hosts"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewResolver(test.cfg)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Fatalf("NewResolver = %v, want an error mentioning %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	resolver, err := NewResolver(config.Tenancy{
+		Header:        "X-Tenant-ID",
+		HostSuffix:    ".example.com",
+		Hosts:         []string{"loyalty.acme.test=acme"},
+		DefaultTenant: "default",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name    string
+		host    string
+		header  string
+		want    string
+		wantErr error
+	}{
+		{name: "default", host: "localhost:9276", want: "default"},
+		{name: "header", host: "localhost", header: "acme", want: "acme"},
+		{name: "listed host", host: "LOYALTY.acme.test:443", want: "acme"},
+		{name: "subdomain", host: "globex.example.com", want: "globex"},
+		{name: "host and matching header", host: "globex.example.com", header: "globex", want: "globex"},
+		{name: "host and other header", host: "globex.example.com", header: "acme", wantErr: ErrTenantMismatch},
+		{name: "invalid header", host: "localhost", header: "../etc", wantErr: ErrInvalidTenant},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var r = httptest.NewRequest("GET", "/", nil)
+			r.Host = test.host
+			if test.header != "" {
+				r.Header.Set("X-Tenant-ID", test.header)
+			}
+			got, err := resolver.Resolve(r)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("Resolve = %v, want %v", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Fatalf("Resolve = %q, want %q", got, test.want)
+			}
+		})
+	}
+
+	// Without a default tenant one must be given.
+	resolver, _ = NewResolver(config.Tenancy{Header: "X-Tenant-ID"})
+	if _, err := resolver.Resolve(httptest.NewRequest("GET", "/", nil)); !errors.Is(err, ErrUnknownTenant) {
+		t.Fatalf("Resolve without a tenant = %v, want ErrUnknownTenant", err)
+	}
+}
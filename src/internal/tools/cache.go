@@ -0,0 +1,417 @@
+package tools
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"golearn/src/internal/config"
+	"golearn/src/internal/tenant"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CacheStats are the cumulative counters of a CachedDatabase.
+type CacheStats struct {
+	LoginHits   uint64
+	LoginMisses uint64
+	PointHits   uint64
+	PointMisses uint64
+	Evictions   uint64
+	Entries     int
+}
+
+// CachedDatabase is a DatabaseInterface decorator that keeps recent lookups
+// in bounded LRU caches. Unknown users are cached for the negative TTL, and
+// balance updates invalidate the cached point details.
+type CachedDatabase struct {
+	next   DatabaseInterface
+	logins *lruCache[LoginDetails]
+	points *lruCache[PointDetails]
+	now    func() time.Time
+
+	loginTTL    time.Duration
+	pointTTL    time.Duration
+	negativeTTL time.Duration
+}
+
+func NewCachedDatabase(next DatabaseInterface, cfg config.Cache) *CachedDatabase {
+	return &CachedDatabase{
+		next:        next,
+		logins:      newLRUCache[LoginDetails](cfg.MaxEntries),
+		points:      newLRUCache[PointDetails](cfg.MaxEntries),
+		now:         time.Now,
+		loginTTL:    cfg.LoginTTL.Duration,
+		pointTTL:    cfg.PointTTL.Duration,
+		negativeTTL: cfg.NegativeTTL.Duration,
+	}
+}
+
+// SetClock makes the cache expire entries by now instead of the system
+// clock. Call it before the cache is used.
+func (d *CachedDatabase) SetClock(now func() time.Time) {
+	d.now = now
+}
+
+func (d *CachedDatabase) GetUserLoginDetails(ctx context.Context, user string) (*LoginDetails, error) {
+	return cachedLookup(ctx, d, d.logins, d.loginTTL, user, d.next.GetUserLoginDetails, copyLoginDetails)
+}
+
+// GetUserPointDetails caches point details by user ID only, so that a
+// balance update invalidates them whichever name the user was looked up by.
+// Holds that expired while the details were cached are dropped on the way
+// out, as the store itself would.
+func (d *CachedDatabase) GetUserPointDetails(ctx context.Context, user string) (*PointDetails, error) {
+	id, err := d.resolve(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	details, err := cachedLookup(ctx, d, d.points, d.pointTTL, id, d.next.GetUserPointDetails, copyPointDetails)
+	if err != nil {
+		return nil, err
+	}
+	details.Holds = activeHolds(details.Holds, d.now())
+	return details, nil
+}
+
+func (d *CachedDatabase) UpdateUserBalance(ctx context.Context, user string, wallet string, delta int64) (*PointDetails, error) {
+	tenantID, err := tenant.MustFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if id, err := d.resolve(ctx, user); err == nil {
+		// Invalidate on failure too: the write may have been applied even
+		// though the caller did not see the result.
+		defer d.InvalidateBalance(tenantID, id)
+	}
+	return d.next.UpdateUserBalance(ctx, user, wallet, delta)
+}
+
+func (d *CachedDatabase) AdjustBalance(ctx context.Context, user string, wallet string, delta int64, reason string) (*PointDetails, error) {
+	tenantID, err := tenant.MustFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if id, err := d.resolve(ctx, user); err == nil {
+		defer d.InvalidateBalance(tenantID, id)
+	}
+	return d.next.AdjustBalance(ctx, user, wallet, delta, reason)
+}
+
+func (d *CachedDatabase) OpenWallet(ctx context.Context, user string, wallet Wallet) (*PointDetails, error) {
+	tenantID, err := tenant.MustFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if id, err := d.resolve(ctx, user); err == nil {
+		defer d.InvalidateBalance(tenantID, id)
+	}
+	return d.next.OpenWallet(ctx, user, wallet)
+}
+
+func (d *CachedDatabase) PlaceHold(ctx context.Context, user string, wallet string, amount int64, ttl time.Duration) (*Hold, *PointDetails, error) {
+	tenantID, err := tenant.MustFromContext(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if id, err := d.resolve(ctx, user); err == nil {
+		defer d.InvalidateBalance(tenantID, id)
+	}
+	return d.next.PlaceHold(ctx, user, wallet, amount, ttl)
+}
+
+func (d *CachedDatabase) CaptureHold(ctx context.Context, user string, holdID string, amount int64) (*Hold, *PointDetails, error) {
+	tenantID, err := tenant.MustFromContext(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if id, err := d.resolve(ctx, user); err == nil {
+		defer d.InvalidateBalance(tenantID, id)
+	}
+	return d.next.CaptureHold(ctx, user, holdID, amount)
+}
+
+func (d *CachedDatabase) ReleaseHold(ctx context.Context, user string, holdID string) (*Hold, *PointDetails, error) {
+	tenantID, err := tenant.MustFromContext(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if id, err := d.resolve(ctx, user); err == nil {
+		defer d.InvalidateBalance(tenantID, id)
+	}
+	return d.next.ReleaseHold(ctx, user, holdID)
+}
+
+// ExpireHolds drops every cached balance once holds have expired, since
+// cached point details would otherwise list them until they expire too.
+// The store spans every tenant, so the whole point cache goes.
+func (d *CachedDatabase) ExpireHolds(ctx context.Context) (int, error) {
+	count, err := d.next.ExpireHolds(ctx)
+	if count > 0 {
+		d.points.clear()
+	}
+	return count, err
+}
+
+func (d *CachedDatabase) GetAccount(ctx context.Context, user string) (*Account, error) {
+	return d.next.GetAccount(ctx, user)
+}
+
+func (d *CachedDatabase) RenameUser(ctx context.Context, user string, username string) (*LoginDetails, error) {
+	tenantID, err := tenant.MustFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Invalidate(tenantID, append(d.keys(ctx, user), username)...)
+	return d.next.RenameUser(ctx, user, username)
+}
+
+func (d *CachedDatabase) ListAccounts(ctx context.Context, fn func(Account) error) error {
+	return d.next.ListAccounts(ctx, fn)
+}
+
+func (d *CachedDatabase) PutAccount(ctx context.Context, account Account) error {
+	tenantID, err := tenant.MustFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer d.Invalidate(tenantID, d.accountKeys(ctx, account)...)
+	return d.next.PutAccount(ctx, account)
+}
+
+func (d *CachedDatabase) PutAccounts(ctx context.Context, accounts []Account) ([]error, error) {
+	tenantID, err := tenant.MustFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, account := range accounts {
+		keys = append(keys, d.accountKeys(ctx, account)...)
+	}
+	defer d.Invalidate(tenantID, keys...)
+	return d.next.PutAccounts(ctx, accounts)
+}
+
+// accountKeys are the cache keys that PutAccount with account may change.
+func (d *CachedDatabase) accountKeys(ctx context.Context, account Account) []string {
+	var keys = []string{account.Username}
+	if account.ID != "" {
+		return append(keys, d.keys(ctx, account.ID)...)
+	}
+	return append(keys, d.keys(ctx, account.Username)...)
+}
+
+func (d *CachedDatabase) Snapshot(ctx context.Context) (Dataset, error) {
+	return d.next.Snapshot(ctx)
+}
+
+func (d *CachedDatabase) Restore(ctx context.Context, data Dataset) error {
+	defer d.InvalidateAll()
+	return d.next.Restore(ctx, data)
+}
+
+func (d *CachedDatabase) SetupDatabase() error {
+	return d.next.SetupDatabase()
+}
+
+func (d *CachedDatabase) Ping(ctx context.Context) error {
+	return d.next.Ping(ctx)
+}
+
+func (d *CachedDatabase) Close() error {
+	return d.next.Close()
+}
+
+// Invalidate drops everything cached under each of users, IDs or usernames,
+// in tenantID. Call it whenever the data of a user changes outside this
+// decorator, naming its ID and every username involved.
+func (d *CachedDatabase) Invalidate(tenantID string, users ...string) {
+	for _, user := range users {
+		d.logins.remove(tenantKey(tenantID, user))
+		d.points.remove(tenantKey(tenantID, user))
+	}
+}
+
+// InvalidateBalance drops the cached point details of the user ID in
+// tenantID.
+func (d *CachedDatabase) InvalidateBalance(tenantID string, id string) {
+	d.points.remove(tenantKey(tenantID, id))
+}
+
+// resolve returns the ID of user, using the login cache for usernames.
+func (d *CachedDatabase) resolve(ctx context.Context, user string) (string, error) {
+	if IsUserID(user) {
+		return user, nil
+	}
+	loginDetails, err := d.GetUserLoginDetails(ctx, user)
+	if err != nil {
+		return "", err
+	}
+	return loginDetails.UserID, nil
+}
+
+// keys returns user and, if it exists, its ID and current username: every
+// key a change to the user must invalidate.
+func (d *CachedDatabase) keys(ctx context.Context, user string) []string {
+	var keys = []string{user}
+	if loginDetails, err := d.GetUserLoginDetails(ctx, user); err == nil {
+		keys = append(keys, loginDetails.UserID, loginDetails.Username)
+	}
+	return keys
+}
+
+// InvalidateAll empties both caches.
+func (d *CachedDatabase) InvalidateAll() {
+	d.logins.clear()
+	d.points.clear()
+}
+
+func (d *CachedDatabase) Stats() CacheStats {
+	return CacheStats{
+		LoginHits:   d.logins.hits.Load(),
+		LoginMisses: d.logins.misses.Load(),
+		PointHits:   d.points.hits.Load(),
+		PointMisses: d.points.misses.Load(),
+		Evictions:   d.logins.evictions.Load() + d.points.evictions.Load(),
+		Entries:     d.logins.len() + d.points.len(),
+	}
+}
+
+// cachedLookup serves user from cache or loads it with fetch. Entries are
+// keyed by tenant and user ID or username. Only successful results and
+// ErrUserNotFound are cached. Values are stored and returned through clone
+// so that callers never share slices with the cache.
+func cachedLookup[T any](ctx context.Context, d *CachedDatabase, cache *lruCache[T], ttl time.Duration, user string,
+	fetch func(context.Context, string) (*T, error), clone func(T) *T) (*T, error) {
+	tenantID, err := tenant.MustFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var key = tenantKey(tenantID, user)
+
+	var now = d.now()
+	if value, found, ok := cache.get(key, now); ok {
+		if !found {
+			return nil, ErrUserNotFound
+		}
+		return clone(value), nil
+	}
+
+	var generation = cache.generation()
+	value, err := fetch(ctx, user)
+	switch {
+	case err == nil:
+		cache.put(key, *clone(*value), true, now.Add(ttl), generation)
+	case errors.Is(err, ErrUserNotFound) && d.negativeTTL > 0:
+		var zero T
+		cache.put(key, zero, false, now.Add(d.negativeTTL), generation)
+	}
+	return value, err
+}
+
+type lruEntry[T any] struct {
+	key     string
+	value   T
+	found   bool
+	expires time.Time
+}
+
+// lruCache is a size-bounded, expiring cache. Every invalidation bumps the
+// generation so that a lookup started before it cannot store a stale value.
+type lruCache[T any] struct {
+	mu         sync.Mutex
+	maxEntries int
+	order      *list.List
+	items      map[string]*list.Element
+	gen        uint64
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+}
+
+func newLRUCache[T any](maxEntries int) *lruCache[T] {
+	return &lruCache[T]{
+		maxEntries: maxEntries,
+		order:      list.New(),
+		items:      map[string]*list.Element{},
+	}
+}
+
+// get returns the cached value, whether the user exists, and whether there
+// was a live entry at all.
+func (c *lruCache[T]) get(key string, now time.Time) (value T, found bool, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var element, exists = c.items[key]
+	if !exists {
+		c.misses.Add(1)
+		return value, false, false
+	}
+	var entry = element.Value.(*lruEntry[T])
+	if !now.Before(entry.expires) {
+		c.order.Remove(element)
+		delete(c.items, key)
+		c.misses.Add(1)
+		return value, false, false
+	}
+
+	c.order.MoveToFront(element)
+	c.hits.Add(1)
+	return entry.value, entry.found, true
+}
+
+func (c *lruCache[T]) put(key string, value T, found bool, expires time.Time, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.gen || c.maxEntries <= 0 {
+		return
+	}
+
+	var entry = &lruEntry[T]{key: key, value: value, found: found, expires: expires}
+	if element, exists := c.items[key]; exists {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.maxEntries {
+		var oldest = c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry[T]).key)
+		c.evictions.Add(1)
+	}
+}
+
+func (c *lruCache[T]) generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gen
+}
+
+func (c *lruCache[T]) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	if element, exists := c.items[key]; exists {
+		c.order.Remove(element)
+		delete(c.items, key)
+	}
+}
+
+func (c *lruCache[T]) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	c.order.Init()
+	c.items = map[string]*list.Element{}
+}
+
+func (c *lruCache[T]) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
@@ -0,0 +1,323 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"golearn/src/internal/config"
+	"testing"
+	"time"
+)
+
+func newTestCache(t *testing.T, next DatabaseInterface, clock *testClock) *CachedDatabase {
+	t.Helper()
+	var cache = NewCachedDatabase(next, config.Cache{
+		Enabled:     true,
+		LoginTTL:    config.Duration{Duration: time.Minute},
+		PointTTL:    config.Duration{Duration: 30 * time.Second},
+		NegativeTTL: config.Duration{Duration: 10 * time.Second},
+		MaxEntries:  100,
+	})
+	cache.SetClock(clock.Now)
+	return cache
+}
+
+func TestLRUCache(t *testing.T) {
+	var now = time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+	var later = now.Add(time.Minute)
+	var c = newLRUCache[int](2)
+
+	c.put("a", 1, true, later, c.generation())
+	c.put("b", 2, true, later, c.generation())
+	if _, _, ok := c.get("a", now); !ok {
+		t.Fatal("a is missing")
+	}
+	// b is now the least recently used.
+	c.put("c", 3, true, later, c.generation())
+	if _, _, ok := c.get("b", now); ok {
+		t.Error("b was not evicted")
+	}
+	if value, _, ok := c.get("c", now); !ok || value != 3 {
+		t.Errorf("c = %d, %v; want 3", value, ok)
+	}
+	if _, _, ok := c.get("a", later); ok {
+		t.Error("a did not expire")
+	}
+	if c.evictions.Load() != 1 {
+		t.Errorf("evictions = %d, want 1", c.evictions.Load())
+	}
+
+	var tests = []struct {
+		name       string
+		invalidate func()
+	}{
+		{"remove", func() { c.remove("unrelated") }},
+		{"clear", c.clear},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// A lookup that started before an invalidation may have read
+			// data the invalidation was for, so it must not be stored.
+			var generation = c.generation()
+			test.invalidate()
+			c.put("d", 4, true, later, generation)
+			if _, _, ok := c.get("d", now); ok {
+				t.Fatal("stored a value from an earlier generation")
+			}
+			c.put("d", 4, true, later, c.generation())
+			if _, _, ok := c.get("d", now); !ok {
+				t.Fatal("did not store a value from the current generation")
+			}
+		})
+	}
+}
+
+func TestCachedDatabaseInvalidation(t *testing.T) {
+	var tests = []struct {
+		name   string
+		change func(ctx context.Context, d *CachedDatabase) error
+		user   string
+		check  func(details *PointDetails) bool
+	}{
+		{
+			name: "credit",
+			change: func(ctx context.Context, d *CachedDatabase) error {
+				_, err := d.UpdateUserBalance(ctx, "alice", DefaultWallet, 5)
+				return err
+			},
+			check: func(details *PointDetails) bool { return details.Wallets[0].Balance == 105 },
+		},
+		{
+			name: "adjustment",
+			change: func(ctx context.Context, d *CachedDatabase) error {
+				_, err := d.AdjustBalance(ctx, "alice", DefaultWallet, -30, "refund")
+				return err
+			},
+			check: func(details *PointDetails) bool { return details.Wallets[0].Balance == 70 },
+		},
+		{
+			name: "new wallet",
+			change: func(ctx context.Context, d *CachedDatabase) error {
+				_, err := d.OpenWallet(ctx, "alice", Wallet{Name: "usd", Currency: "USD", Precision: 2})
+				return err
+			},
+			check: func(details *PointDetails) bool { return len(details.Wallets) == 3 },
+		},
+		{
+			name: "hold",
+			change: func(ctx context.Context, d *CachedDatabase) error {
+				_, _, err := d.PlaceHold(ctx, "alice", DefaultWallet, 10, time.Hour)
+				return err
+			},
+			check: func(details *PointDetails) bool { return details.Available(DefaultWallet) == 90 },
+		},
+		{
+			name: "rename",
+			change: func(ctx context.Context, d *CachedDatabase) error {
+				_, err := d.RenameUser(ctx, "alice", "alicia")
+				return err
+			},
+			user:  "alicia",
+			check: func(details *PointDetails) bool { return details.Username == "alicia" },
+		},
+		{
+			name: "put account",
+			change: func(ctx context.Context, d *CachedDatabase) error {
+				account, err := d.GetAccount(ctx, "alice")
+				if err != nil {
+					return err
+				}
+				account.Wallets[0].Balance = 1
+				return d.PutAccount(ctx, *account)
+			},
+			check: func(details *PointDetails) bool { return details.Wallets[0].Balance == 1 },
+		},
+		{
+			name: "restore",
+			change: func(ctx context.Context, d *CachedDatabase) error {
+				data, err := d.Snapshot(ctx)
+				if err != nil {
+					return err
+				}
+				data["default"][0].Wallets[0].Balance = 2
+				return d.Restore(ctx, data)
+			},
+			check: func(details *PointDetails) bool { return details.Wallets[0].Balance == 2 },
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store, ctx, clock := newTestStore(t)
+			var cache = newTestCache(t, store, clock)
+			for range 2 {
+				if _, err := cache.GetUserPointDetails(ctx, "alice"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if stats := cache.Stats(); stats.PointHits != 1 {
+				t.Fatalf("point hits = %d, want 1", stats.PointHits)
+			}
+
+			if err := test.change(ctx, cache); err != nil {
+				t.Fatal(err)
+			}
+			var user = test.user
+			if user == "" {
+				user = "alice"
+			}
+			details, err := cache.GetUserPointDetails(ctx, user)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !test.check(details) {
+				t.Fatalf("stale point details after the change: %+v", details)
+			}
+		})
+	}
+}
+
+func TestCachedDatabaseRenameForgetsOldName(t *testing.T) {
+	store, ctx, clock := newTestStore(t)
+	var cache = newTestCache(t, store, clock)
+	if _, err := cache.GetUserLoginDetails(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.RenameUser(ctx, "alice", "alicia"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.GetUserLoginDetails(ctx, "alice"); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("old username = %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestCachedDatabaseNegativeTTL(t *testing.T) {
+	store, ctx, clock := newTestStore(t)
+	var cache = newTestCache(t, store, clock)
+	for range 2 {
+		if _, err := cache.GetUserLoginDetails(ctx, "bob"); !errors.Is(err, ErrUserNotFound) {
+			t.Fatalf("bob = %v, want ErrUserNotFound", err)
+		}
+	}
+	if stats := cache.Stats(); stats.LoginHits != 1 {
+		t.Fatalf("login hits = %d, want the second miss served from cache", stats.LoginHits)
+	}
+
+	// An account created elsewhere shows up once the negative entry expires.
+	if err := store.PutAccount(ctx, Account{Username: "bob", Name: "Bob", AuthToken: "bob"}); err != nil {
+		t.Fatal(err)
+	}
+	clock.now = clock.now.Add(10 * time.Second)
+	if _, err := cache.GetUserLoginDetails(ctx, "bob"); err != nil {
+		t.Fatalf("bob after the negative TTL: %v", err)
+	}
+}
+
+func TestCachedDatabaseCopies(t *testing.T) {
+	store, ctx, clock := newTestStore(t)
+	var cache = newTestCache(t, store, clock)
+	details, err := cache.GetUserPointDetails(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	details.Wallets[0].Balance = 999
+
+	details, err = cache.GetUserPointDetails(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if details.Wallets[0].Balance != 100 {
+		t.Fatalf("a caller's change reached the cache: balance %d", details.Wallets[0].Balance)
+	}
+}
+
+func TestCachedDatabaseExpireHolds(t *testing.T) {
+	store, ctx, clock := newTestStore(t)
+	var cache = newTestCache(t, store, clock)
+	if _, _, err := cache.PlaceHold(ctx, "alice", DefaultWallet, 10, 10*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.GetUserPointDetails(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The sweep drops the cached details along with the hold.
+	clock.now = clock.now.Add(20 * time.Second)
+	if expired, err := cache.ExpireHolds(ctx); err != nil || expired != 1 {
+		t.Fatalf("ExpireHolds = %d, %v; want 1", expired, err)
+	}
+	details, err := cache.GetUserPointDetails(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(details.Holds) != 0 {
+		t.Fatalf("holds after the sweep = %+v, want none", details.Holds)
+	}
+}
+
+func TestCachedDatabaseExpiredHold(t *testing.T) {
+	store, ctx, clock := newTestStore(t)
+	var cache = newTestCache(t, store, clock)
+	if _, _, err := cache.PlaceHold(ctx, "alice", DefaultWallet, 10, 10*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.GetUserPointDetails(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Still within the point TTL, but past the hold's expiry and before
+	// any sweep.
+	clock.now = clock.now.Add(20 * time.Second)
+	details, err := cache.GetUserPointDetails(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := cache.Stats(); stats.PointHits != 1 {
+		t.Fatalf("point hits = %d, want the details served from cache", stats.PointHits)
+	}
+	if len(details.Holds) != 0 || details.Available(DefaultWallet) != 100 {
+		t.Fatalf("got holds %+v and %d available, want the expired hold gone", details.Holds, details.Available(DefaultWallet))
+	}
+}
+
+// slowStore holds up the first point lookup after reading, until released.
+type slowStore struct {
+	DatabaseInterface
+	read    chan struct{}
+	release chan struct{}
+}
+
+func (s *slowStore) GetUserPointDetails(ctx context.Context, user string) (*PointDetails, error) {
+	details, err := s.DatabaseInterface.GetUserPointDetails(ctx, user)
+	if s.read != nil {
+		close(s.read)
+		s.read = nil
+		<-s.release
+	}
+	return details, err
+}
+
+func TestCachedDatabaseStaleLookup(t *testing.T) {
+	store, ctx, clock := newTestStore(t)
+	var slow = &slowStore{DatabaseInterface: store, read: make(chan struct{}), release: make(chan struct{})}
+	var cache = newTestCache(t, slow, clock)
+	var read = slow.read
+
+	var done = make(chan struct{})
+	go func() {
+		defer close(done)
+		cache.GetUserPointDetails(ctx, "usr_00000000000000a1")
+	}()
+	<-read
+	if _, err := cache.UpdateUserBalance(ctx, "usr_00000000000000a1", DefaultWallet, 5); err != nil {
+		t.Fatal(err)
+	}
+	close(slow.release)
+	<-done
+
+	details, err := cache.GetUserPointDetails(ctx, "usr_00000000000000a1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if details.Wallets[0].Balance != 105 {
+		t.Fatalf("balance = %d, want 105: the slow lookup cached its stale result", details.Wallets[0].Balance)
+	}
+}
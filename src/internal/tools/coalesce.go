@@ -0,0 +1,212 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"golearn/src/internal/tenant"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// flightGroup merges concurrent calls with the same key into one execution
+// of fn, in the style of singleflight. The shared call runs with a context
+// that keeps the first caller's values but is only cancelled once every
+// waiting caller has given up, so one caller cancelling does not fail the
+// others.
+type flightGroup[T any] struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall[T]
+
+	executions atomic.Uint64
+	shared     atomic.Uint64
+}
+
+type flightCall[T any] struct {
+	done    chan struct{}
+	value   T
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// Do returns the result of fn for key, either by running it or by waiting for
+// a call already in flight. If ctx ends first, Do returns ctx.Err() while the
+// call carries on for any remaining callers.
+func (g *flightGroup[T]) Do(ctx context.Context, key string, fn func(context.Context) (T, error)) (T, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = map[string]*flightCall[T]{}
+	}
+	var call, inFlight = g.calls[key]
+	if inFlight {
+		call.waiters++
+		g.shared.Add(1)
+	} else {
+		callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		call = &flightCall[T]{done: make(chan struct{}), waiters: 1, cancel: cancel}
+		g.calls[key] = call
+		g.executions.Add(1)
+		go g.run(callCtx, key, call, fn)
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.value, call.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		call.waiters--
+		if call.waiters == 0 {
+			// Forget the call before cancelling it, so that a later caller
+			// starts a fresh one rather than joining a cancelled one.
+			if g.calls[key] == call {
+				delete(g.calls, key)
+			}
+			call.cancel()
+		}
+		g.mu.Unlock()
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+func (g *flightGroup[T]) run(ctx context.Context, key string, call *flightCall[T], fn func(context.Context) (T, error)) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			call.err = fmt.Errorf("coalesced call panicked: %v", recovered)
+		}
+		g.mu.Lock()
+		if g.calls[key] == call {
+			delete(g.calls, key)
+		}
+		g.mu.Unlock()
+		call.cancel()
+		close(call.done)
+	}()
+
+	call.value, call.err = fn(ctx)
+}
+
+// CoalesceStats count backend lookups and the callers that shared them.
+type CoalesceStats struct {
+	Executions uint64
+	Shared     uint64
+}
+
+// CoalescedDatabase is a DatabaseInterface decorator that merges concurrent
+// identical lookups into one backend call. Writes are passed straight
+// through.
+type CoalescedDatabase struct {
+	next   DatabaseInterface
+	logins flightGroup[*LoginDetails]
+	points flightGroup[*PointDetails]
+}
+
+func NewCoalescedDatabase(next DatabaseInterface) *CoalescedDatabase {
+	return &CoalescedDatabase{next: next}
+}
+
+func (d *CoalescedDatabase) GetUserLoginDetails(ctx context.Context, user string) (*LoginDetails, error) {
+	tenantID, err := tenant.MustFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	loginDetails, err := d.logins.Do(ctx, tenantKey(tenantID, user), func(ctx context.Context) (*LoginDetails, error) {
+		return d.next.GetUserLoginDetails(ctx, user)
+	})
+	if loginDetails == nil {
+		return nil, err
+	}
+	// Each caller gets its own copy of the shared result, slices included.
+	return copyLoginDetails(*loginDetails), err
+}
+
+func (d *CoalescedDatabase) GetUserPointDetails(ctx context.Context, user string) (*PointDetails, error) {
+	tenantID, err := tenant.MustFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pointDetails, err := d.points.Do(ctx, tenantKey(tenantID, user), func(ctx context.Context) (*PointDetails, error) {
+		return d.next.GetUserPointDetails(ctx, user)
+	})
+	if pointDetails == nil {
+		return nil, err
+	}
+	return copyPointDetails(*pointDetails), err
+}
+
+func (d *CoalescedDatabase) UpdateUserBalance(ctx context.Context, user string, wallet string, delta int64) (*PointDetails, error) {
+	return d.next.UpdateUserBalance(ctx, user, wallet, delta)
+}
+
+func (d *CoalescedDatabase) AdjustBalance(ctx context.Context, user string, wallet string, delta int64, reason string) (*PointDetails, error) {
+	return d.next.AdjustBalance(ctx, user, wallet, delta, reason)
+}
+
+func (d *CoalescedDatabase) OpenWallet(ctx context.Context, user string, wallet Wallet) (*PointDetails, error) {
+	return d.next.OpenWallet(ctx, user, wallet)
+}
+
+func (d *CoalescedDatabase) PlaceHold(ctx context.Context, user string, wallet string, amount int64, ttl time.Duration) (*Hold, *PointDetails, error) {
+	return d.next.PlaceHold(ctx, user, wallet, amount, ttl)
+}
+
+func (d *CoalescedDatabase) CaptureHold(ctx context.Context, user string, holdID string, amount int64) (*Hold, *PointDetails, error) {
+	return d.next.CaptureHold(ctx, user, holdID, amount)
+}
+
+func (d *CoalescedDatabase) ReleaseHold(ctx context.Context, user string, holdID string) (*Hold, *PointDetails, error) {
+	return d.next.ReleaseHold(ctx, user, holdID)
+}
+
+func (d *CoalescedDatabase) ExpireHolds(ctx context.Context) (int, error) {
+	return d.next.ExpireHolds(ctx)
+}
+
+func (d *CoalescedDatabase) GetAccount(ctx context.Context, user string) (*Account, error) {
+	return d.next.GetAccount(ctx, user)
+}
+
+func (d *CoalescedDatabase) RenameUser(ctx context.Context, user string, username string) (*LoginDetails, error) {
+	return d.next.RenameUser(ctx, user, username)
+}
+
+func (d *CoalescedDatabase) ListAccounts(ctx context.Context, fn func(Account) error) error {
+	return d.next.ListAccounts(ctx, fn)
+}
+
+func (d *CoalescedDatabase) PutAccount(ctx context.Context, account Account) error {
+	return d.next.PutAccount(ctx, account)
+}
+
+func (d *CoalescedDatabase) PutAccounts(ctx context.Context, accounts []Account) ([]error, error) {
+	return d.next.PutAccounts(ctx, accounts)
+}
+
+func (d *CoalescedDatabase) Snapshot(ctx context.Context) (Dataset, error) {
+	return d.next.Snapshot(ctx)
+}
+
+func (d *CoalescedDatabase) Restore(ctx context.Context, data Dataset) error {
+	return d.next.Restore(ctx, data)
+}
+
+func (d *CoalescedDatabase) SetupDatabase() error {
+	return d.next.SetupDatabase()
+}
+
+func (d *CoalescedDatabase) Ping(ctx context.Context) error {
+	return d.next.Ping(ctx)
+}
+
+func (d *CoalescedDatabase) Close() error {
+	return d.next.Close()
+}
+
+func (d *CoalescedDatabase) Stats() CoalesceStats {
+	return CoalesceStats{
+		Executions: d.logins.executions.Load() + d.points.executions.Load(),
+		Shared:     d.logins.shared.Load() + d.points.shared.Load(),
+	}
+}
@@ -0,0 +1,181 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitFor polls cond until it holds, failing the test after a second.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	var deadline = time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestFlightGroupShares(t *testing.T) {
+	var g flightGroup[int]
+	var release = make(chan struct{})
+	var fn = func(ctx context.Context) (int, error) {
+		<-release
+		return 42, nil
+	}
+
+	const callers = 5
+	var results = make(chan int, callers)
+	for range callers {
+		go func() {
+			value, _ := g.Do(context.Background(), "key", fn)
+			results <- value
+		}()
+	}
+	waitFor(t, "callers to join", func() bool { return g.shared.Load() == callers-1 })
+	close(release)
+
+	for range callers {
+		if value := <-results; value != 42 {
+			t.Fatalf("got %d, want 42", value)
+		}
+	}
+	if executions := g.executions.Load(); executions != 1 {
+		t.Fatalf("executions = %d, want 1", executions)
+	}
+
+	// The call is forgotten once done, so the next one runs again.
+	g.Do(context.Background(), "key", fn)
+	if executions := g.executions.Load(); executions != 2 {
+		t.Fatalf("executions = %d, want 2", executions)
+	}
+}
+
+func TestFlightGroupCancel(t *testing.T) {
+	var tests = []struct {
+		name   string
+		cancel int
+		// Whether the shared call sees its context cancelled.
+		cancelled bool
+	}{
+		{name: "one of two callers", cancel: 1, cancelled: false},
+		{name: "every caller", cancel: 2, cancelled: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var g flightGroup[int]
+			var release = make(chan struct{})
+			var sawCancel = make(chan bool, 1)
+			var fn = func(ctx context.Context) (int, error) {
+				select {
+				case <-release:
+					sawCancel <- false
+					return 42, nil
+				case <-ctx.Done():
+					sawCancel <- true
+					return 0, ctx.Err()
+				}
+			}
+
+			var cancels []context.CancelFunc
+			var wg sync.WaitGroup
+			var errs = make([]error, 2)
+			for i := range 2 {
+				var ctx, cancel = context.WithCancel(context.Background())
+				if i < test.cancel {
+					cancels = append(cancels, cancel)
+				} else {
+					defer cancel()
+				}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, errs[i] = g.Do(ctx, "key", fn)
+				}()
+			}
+			waitFor(t, "callers to join", func() bool { return g.shared.Load() == 1 })
+
+			for _, cancel := range cancels {
+				cancel()
+			}
+			if test.cancelled {
+				if !<-sawCancel {
+					t.Fatal("the shared call was not cancelled")
+				}
+			} else {
+				// Let the remaining caller's result arrive only after the
+				// cancelled caller has given up.
+				waitFor(t, "the cancelled caller", func() bool {
+					g.mu.Lock()
+					defer g.mu.Unlock()
+					return g.calls["key"] != nil && g.calls["key"].waiters == 1
+				})
+				close(release)
+				if <-sawCancel {
+					t.Fatal("one caller cancelling cancelled the shared call")
+				}
+			}
+			wg.Wait()
+
+			for i, err := range errs {
+				if want := i < test.cancel; errors.Is(err, context.Canceled) != want {
+					t.Errorf("caller %d got %v, want cancelled %v", i, err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFlightGroupPanic(t *testing.T) {
+	var g flightGroup[int]
+	_, err := g.Do(context.Background(), "key", func(ctx context.Context) (int, error) {
+		panic("boom")
+	})
+	if err == nil || !strings.Contains(err.Error(), "panicked: boom") {
+		t.Fatalf("got %v, want the panic as an error", err)
+	}
+}
+
+// gatedStore holds up point lookups until released.
+type gatedStore struct {
+	DatabaseInterface
+	release chan struct{}
+}
+
+func (s *gatedStore) GetUserPointDetails(ctx context.Context, user string) (*PointDetails, error) {
+	<-s.release
+	return s.DatabaseInterface.GetUserPointDetails(ctx, user)
+}
+
+func TestCoalescedDatabaseCopies(t *testing.T) {
+	store, ctx, _ := newTestStore(t)
+	var gated = &gatedStore{DatabaseInterface: store, release: make(chan struct{})}
+	var database = NewCoalescedDatabase(gated)
+
+	var results = make(chan *PointDetails, 2)
+	for range 2 {
+		go func() {
+			details, err := database.GetUserPointDetails(ctx, "alice")
+			if err != nil {
+				t.Error(err)
+			}
+			results <- details
+		}()
+	}
+	waitFor(t, "the lookups to merge", func() bool { return database.Stats().Shared == 1 })
+	close(gated.release)
+
+	var first, second = <-results, <-results
+	first.Wallets[0].Balance = 999
+	if second.Wallets[0].Balance != 100 {
+		t.Fatalf("callers share the result: balance %d", second.Wallets[0].Balance)
+	}
+	if stats := database.Stats(); stats.Executions != 1 {
+		t.Fatalf("executions = %d, want 1", stats.Executions)
+	}
+}
@@ -1,27 +1,165 @@
 package tools
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"golearn/src/internal/config"
+	"time"
+
 	log "github.com/sirupsen/logrus"
 )
 
+var (
+	ErrUserNotFound      = errors.New("user not found")
+	ErrInsufficientFunds = errors.New("insufficient balance")
+	ErrBalanceOverflow   = errors.New("balance would exceed the largest representable amount")
+)
+
+// RoleAdmin grants access to the /api/accounts administration routes.
+const RoleAdmin = "admin"
+
+// LoginDetails identify an account. UserID never changes; Username is its
+// current alias and Name its display name.
 type LoginDetails struct {
-	AuthToken string
+	UserID    string
 	Username  string
+	Name      string
+	AuthToken string
+	Roles     []string
+}
+
+func (l LoginDetails) HasRole(role string) bool {
+	for _, r := range l.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
 }
 
+// PointDetails are the ledger balances of an account, the default wallet
+// first, and its active holds.
 type PointDetails struct {
+	UserID   string
 	Username string
-	Balance  int64
+	Wallets  []Wallet
+	Holds    []Hold
 }
 
+// Account is everything stored about one user, as read and written in bulk.
+// Wallets always include the default wallet, which comes first. Holds
+// lists the active holds and Renames earlier usernames, oldest first.
+type Account struct {
+	ID        string
+	Username  string
+	Name      string
+	AuthToken string
+	Roles     []string
+	Wallets   []Wallet
+	Holds     []Hold
+	Renames   []Rename
+	// Ledger lists the changes to the wallet balances, oldest first.
+	Ledger []LedgerEntry
+}
+
+// Dataset is a copy of every tenant's accounts, keyed by tenant ID.
+type Dataset map[string][]Account
+
+// DatabaseInterface is the account store. Account methods are scoped to the
+// tenant of ctx (see tenant.NewContext) and fail with tenant.ErrNoTenant
+// without one, so no caller can reach another tenant's accounts. They find
+// the user by ID or by current username.
 type DatabaseInterface interface {
-	GetUserLoginDetails(username string) *LoginDetails
-	GetUserPointDetails(username string) *PointDetails
+	GetUserLoginDetails(ctx context.Context, user string) (*LoginDetails, error)
+	GetUserPointDetails(ctx context.Context, user string) (*PointDetails, error)
+	// UpdateUserBalance adds delta to the balance of the user's wallet. It
+	// fails with ErrWalletNotFound if the user has no such wallet, with
+	// ErrInsufficientFunds if a debit exceeds the available balance and with
+	// ErrBalanceOverflow if a credit would take the balance past MaxInt64.
+	UpdateUserBalance(ctx context.Context, user string, wallet string, delta int64) (*PointDetails, error)
+	// AdjustBalance is UpdateUserBalance for corrections made by staff: the
+	// change is recorded in the ledger as an adjustment with reason as its
+	// reference. It fails with ErrInvalidAdjustment if delta is zero or
+	// reason is blank.
+	AdjustBalance(ctx context.Context, user string, wallet string, delta int64, reason string) (*PointDetails, error)
+	// PlaceHold reserves amount of the available balance of the user's
+	// wallet for ttl. It fails with ErrInvalidHoldAmount unless amount is
+	// positive and with ErrInsufficientFunds if not enough is available.
+	PlaceHold(ctx context.Context, user string, wallet string, amount int64, ttl time.Duration) (*Hold, *PointDetails, error)
+	// CaptureHold debits amount of an active hold from its wallet, or all
+	// of what it still holds if amount is 0. The hold ends once nothing is
+	// left. It fails with ErrHoldNotFound if the user has no such active
+	// hold, with ErrHoldExceeded if amount is more than it holds and with
+	// ErrInvalidHoldAmount if amount is negative.
+	CaptureHold(ctx context.Context, user string, holdID string, amount int64) (*Hold, *PointDetails, error)
+	// ReleaseHold ends an active hold without debiting anything. It fails
+	// with ErrHoldNotFound if the user has no such active hold.
+	ReleaseHold(ctx context.Context, user string, holdID string) (*Hold, *PointDetails, error)
+	// OpenWallet adds an empty wallet to the user's account. It fails with
+	// ErrWalletExists if the user already has a wallet of that name.
+	OpenWallet(ctx context.Context, user string, wallet Wallet) (*PointDetails, error)
+	// GetAccount returns everything stored about the user, including its
+	// rename history and ledger.
+	GetAccount(ctx context.Context, user string) (*Account, error)
+	// RenameUser changes the user's username, recording the old one in its
+	// history. It fails with ErrUsernameTaken if another account of the
+	// tenant has the new username.
+	RenameUser(ctx context.Context, user string, username string) (*LoginDetails, error)
+	// ListAccounts calls fn for every account of the tenant in username
+	// order, stopping at the first error fn returns.
+	ListAccounts(ctx context.Context, fn func(Account) error) error
+	// PutAccount creates account or replaces the account with its ID. An
+	// account without an ID replaces the one with its username, or is
+	// created with a new ID. A changed username is recorded as a rename.
+	// An account without the default wallet is given an empty one. The
+	// holds and ledger of a replaced account are kept, and those of a new
+	// one ignored; differences in wallet balances are recorded as ledger
+	// adjustments.
+	PutAccount(ctx context.Context, account Account) error
+	// PutAccounts is PutAccount for a batch, written in order so that later
+	// accounts see earlier ones, and persisted once. It returns the error of
+	// each account that was rejected, or nil in its place, and fails as a
+	// whole, keeping none of the batch, if the batch cannot be persisted.
+	PutAccounts(ctx context.Context, accounts []Account) ([]error, error)
+	// Snapshot returns a consistent copy of all tenants' accounts. Unlike
+	// the other account methods it ignores the tenant of ctx.
+	Snapshot(ctx context.Context) (Dataset, error)
+	// Restore atomically replaces all tenants' accounts with data.
+	// Accounts without an ID are given one.
+	Restore(ctx context.Context, data Dataset) error
+	// ExpireHolds deletes the expired holds of every tenant and returns how
+	// many there were. Expired holds already reserve nothing; this only
+	// keeps them from piling up in storage. Like Snapshot it ignores the
+	// tenant of ctx.
+	ExpireHolds(ctx context.Context) (int, error)
 	SetupDatabase() error
+	Ping(ctx context.Context) error
+	Close() error
 }
 
-func NewDatabase() (*DatabaseInterface, error) {
-	var database DatabaseInterface = &mockDatabase{}
+func NewDatabase(cfg config.Database) (*DatabaseInterface, error) {
+	var database DatabaseInterface
+
+	switch cfg.Driver {
+	case "mock":
+		data, err := LoadFixtures(cfg.Fixtures)
+		if err != nil {
+			return nil, err
+		}
+		scenario, err := LoadMockScenario(cfg.MockScenario, cfg.MockLatency.Duration)
+		if err != nil {
+			return nil, err
+		}
+		database, err = newMockDatabase(data, scenario)
+		if err != nil {
+			return nil, err
+		}
+	case "file":
+		database = newFileDatabase(cfg.File, cfg.AutoMigrate, cfg.Fixtures)
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", cfg.Driver)
+	}
 
 	var err error = database.SetupDatabase()
 
@@ -32,3 +170,10 @@ func NewDatabase() (*DatabaseInterface, error) {
 
 	return &database, nil
 }
+
+// tenantKey identifies a user ID or username within tenantID for caches and
+// coalescing. Tenant IDs cannot contain "/", so keys of different tenants
+// never collide.
+func tenantKey(tenantID string, user string) string {
+	return tenantID + "/" + user
+}
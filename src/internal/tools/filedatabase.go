@@ -0,0 +1,332 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golearn/src/internal/migrate"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// fileDocument is the data file at the latest schema version. Accounts are
+// keyed by tenant, then user ID, and wallets by name.
+type fileDocument struct {
+	SchemaVersion int                               `json:"schema_version"`
+	Tenants       map[string]map[string]fileAccount `json:"tenants"`
+}
+
+type fileAccount struct {
+	Username string                `json:"username"`
+	Name     string                `json:"name"`
+	Token    string                `json:"token"`
+	Roles    []string              `json:"roles"`
+	Wallets  map[string]fileWallet `json:"wallets"`
+	Holds    []fileHold            `json:"holds"`
+	Renames  []fileRename          `json:"renames"`
+	Ledger   []fileLedgerEntry     `json:"ledger"`
+}
+
+type fileWallet struct {
+	Currency  string `json:"currency"`
+	Precision int    `json:"precision"`
+	Balance   int64  `json:"balance"`
+}
+
+type fileHold struct {
+	ID        string    `json:"id"`
+	Wallet    string    `json:"wallet"`
+	Amount    int64     `json:"amount"`
+	Captured  int64     `json:"captured"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type fileRename struct {
+	From string    `json:"from"`
+	To   string    `json:"to"`
+	At   time.Time `json:"at"`
+}
+
+type fileLedgerEntry struct {
+	Wallet    string    `json:"wallet"`
+	Kind      string    `json:"kind"`
+	Amount    int64     `json:"amount"`
+	Balance   int64     `json:"balance"`
+	At        time.Time `json:"at"`
+	Reference string    `json:"reference,omitempty"`
+}
+
+// fileDatabase keeps every account in memory and rewrites its JSON data
+// file after each change.
+type fileDatabase struct {
+	*memoryStore
+	path        string
+	autoMigrate bool
+	fixtures    string
+}
+
+func newFileDatabase(path string, autoMigrate bool, fixtures string) *fileDatabase {
+	var store, _ = newMemoryStore(Dataset{})
+	return &fileDatabase{memoryStore: store, path: path, autoMigrate: autoMigrate, fixtures: fixtures}
+}
+
+// SetupDatabase loads the data file, creating it with the fixture accounts
+// if it does not exist. Data at an older schema version is migrated when
+// autoMigrate is set and refused otherwise; data at a newer version is
+// always refused.
+func (d *fileDatabase) SetupDatabase() error {
+	version, pending, err := FileSchemaStatus(d.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		data, err := LoadFixtures(d.fixtures)
+		if err != nil {
+			return err
+		}
+		log.Infof("creating data file %s at schema version %d", d.path, FileMigrations.Latest())
+		if d.memoryStore, err = newMemoryStore(data); err != nil {
+			return err
+		}
+		d.persist = d.save
+		return d.save()
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(pending) > 0 {
+		if !d.autoMigrate {
+			return fmt.Errorf("data file %s is at schema version %d, %d migrations behind; run `golearn migrate up` or enable database.auto_migrate",
+				d.path, version, len(pending))
+		}
+		if _, _, err = MigrateFile(d.path, logMigration); err != nil {
+			return err
+		}
+	}
+
+	data, err := readDataFile(d.path)
+	if err != nil {
+		return err
+	}
+	if d.memoryStore, err = newMemoryStore(data); err != nil {
+		return fmt.Errorf("data file %s: %w", d.path, err)
+	}
+	d.persist = d.save
+	return nil
+}
+
+// save writes every account to the data file. It runs with the store's lock
+// held.
+func (d *fileDatabase) save() error {
+	var doc = fileDocument{
+		SchemaVersion: FileMigrations.Latest(),
+		Tenants:       map[string]map[string]fileAccount{},
+	}
+	for tenantID, accounts := range d.dataset() {
+		doc.Tenants[tenantID] = map[string]fileAccount{}
+		for _, account := range accounts {
+			var roles = account.Roles
+			if roles == nil {
+				roles = []string{}
+			}
+			var wallets = map[string]fileWallet{}
+			for _, wallet := range account.Wallets {
+				wallets[wallet.Name] = fileWallet{Currency: wallet.Currency, Precision: wallet.Precision, Balance: wallet.Balance}
+			}
+			var holds = make([]fileHold, 0, len(account.Holds))
+			for _, hold := range account.Holds {
+				holds = append(holds, fileHold(hold))
+			}
+			var renames = make([]fileRename, 0, len(account.Renames))
+			for _, rename := range account.Renames {
+				renames = append(renames, fileRename(rename))
+			}
+			var ledger = make([]fileLedgerEntry, 0, len(account.Ledger))
+			for _, entry := range account.Ledger {
+				ledger = append(ledger, fileLedgerEntry(entry))
+			}
+			doc.Tenants[tenantID][account.ID] = fileAccount{
+				Username: account.Username,
+				Name:     account.Name,
+				Token:    account.AuthToken,
+				Roles:    roles,
+				Wallets:  wallets,
+				Holds:    holds,
+				Renames:  renames,
+				Ledger:   ledger,
+			}
+		}
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(d.path, append(data, '\n'))
+}
+
+func (d *fileDatabase) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+func (d *fileDatabase) Close() error {
+	return nil
+}
+
+// FileSchemaStatus returns the schema version of the data file at path and
+// the migrations it still needs, or a *migrate.NewerSchemaError when it was
+// written by a newer binary.
+func FileSchemaStatus(path string) (int, []migrate.Migration, error) {
+	_, version, err := readRawDataFile(path)
+	if err != nil {
+		return 0, nil, err
+	}
+	pending, err := FileMigrations.Pending(version)
+	return version, pending, err
+}
+
+// MigrateFile upgrades the data file at path to the latest schema version.
+// The original is kept as path.v<from>.bak, and the file is only replaced
+// once every migration has succeeded.
+func MigrateFile(path string, onApply func(migrate.Migration)) (from int, to int, err error) {
+	doc, from, err := readRawDataFile(path)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	to, err = FileMigrations.Up(doc, from, onApply)
+	if err != nil || to == from {
+		return from, to, err
+	}
+	doc["schema_version"] = to
+
+	original, err := os.ReadFile(path)
+	if err != nil {
+		return from, from, err
+	}
+	if err = writeFileAtomic(fmt.Sprintf("%s.v%d.bak", path, from), original); err != nil {
+		return from, from, fmt.Errorf("backing up data file: %w", err)
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return from, from, err
+	}
+	if err = writeFileAtomic(path, append(data, '\n')); err != nil {
+		return from, from, err
+	}
+	return from, to, nil
+}
+
+func logMigration(m migrate.Migration) {
+	log.Infof("applied migration %d: %s", m.Version, m.Name)
+}
+
+// readRawDataFile decodes the data file without assuming its schema. A file
+// without a schema_version is at version 0.
+func readRawDataFile(path string) (migrate.Document, int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var doc = migrate.Document{}
+	if len(bytes.TrimSpace(data)) > 0 {
+		if err = json.Unmarshal(data, &doc); err != nil {
+			return nil, 0, fmt.Errorf("data file %s: %w", path, err)
+		}
+	}
+
+	var version int
+	if raw, ok := doc["schema_version"]; ok {
+		number, ok := raw.(float64)
+		if !ok || number != float64(int(number)) {
+			return nil, 0, fmt.Errorf("data file %s: schema_version must be an integer", path)
+		}
+		version = int(number)
+	}
+	return doc, version, nil
+}
+
+// readDataFile decodes a data file at the latest schema version.
+func readDataFile(path string) (Dataset, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc fileDocument
+	var decoder = json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("data file %s: %w", path, err)
+	}
+	if doc.SchemaVersion != FileMigrations.Latest() {
+		return nil, fmt.Errorf("data file %s: expected schema version %d, found %d", path, FileMigrations.Latest(), doc.SchemaVersion)
+	}
+
+	var data = Dataset{}
+	for tenantID, accounts := range doc.Tenants {
+		data[tenantID] = []Account{}
+		for id, account := range accounts {
+			if !IsUserID(id) {
+				return nil, fmt.Errorf("data file %s: tenant %s: %w, got %q", path, tenantID, ErrInvalidUserID, id)
+			}
+			var wallets []Wallet
+			for name, wallet := range account.Wallets {
+				wallets = append(wallets, Wallet{Name: name, Currency: wallet.Currency, Precision: wallet.Precision, Balance: wallet.Balance})
+			}
+			var holds []Hold
+			for _, hold := range account.Holds {
+				holds = append(holds, Hold(hold))
+			}
+			var renames []Rename
+			for _, rename := range account.Renames {
+				renames = append(renames, Rename(rename))
+			}
+			var ledger []LedgerEntry
+			for _, entry := range account.Ledger {
+				ledger = append(ledger, LedgerEntry(entry))
+			}
+			data[tenantID] = append(data[tenantID], Account{
+				ID:        id,
+				Username:  account.Username,
+				Name:      account.Name,
+				AuthToken: account.Token,
+				Roles:     account.Roles,
+				Wallets:   wallets,
+				Holds:     holds,
+				Renames:   renames,
+				Ledger:    ledger,
+			})
+		}
+	}
+	return data, nil
+}
+
+// writeFileAtomic replaces path with data so that readers and crashes only
+// ever see the old or the new contents.
+func writeFileAtomic(path string, data []byte) error {
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	_, err = temp.Write(data)
+	if err == nil {
+		err = temp.Sync()
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), path)
+}
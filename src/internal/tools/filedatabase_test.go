@@ -0,0 +1,168 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golearn/src/internal/migrate"
+	"golearn/src/internal/tenant"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func writeDataFile(t *testing.T, content string) string {
+	t.Helper()
+	var path = filepath.Join(t.TempDir(), "data.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestMigrateFile(t *testing.T) {
+	// An empty file predates every schema version.
+	var path = writeDataFile(t, "")
+
+	var applied []int
+	from, to, err := MigrateFile(path, func(m migrate.Migration) { applied = append(applied, m.Version) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != 0 || to != FileMigrations.Latest() {
+		t.Fatalf("migrated from %d to %d, want 0 to %d", from, to, FileMigrations.Latest())
+	}
+	var want []int
+	for version := 1; version <= FileMigrations.Latest(); version++ {
+		want = append(want, version)
+	}
+	if !slices.Equal(applied, want) {
+		t.Fatalf("applied %v, want %v", applied, want)
+	}
+	if _, err := os.Stat(path + ".v0.bak"); err != nil {
+		t.Fatalf("no backup of the original: %v", err)
+	}
+
+	var database = newFileDatabase(path, false, "")
+	if err := database.SetupDatabase(); err != nil {
+		t.Fatal(err)
+	}
+	var ctx = tenant.NewContext(context.Background(), "default")
+	if err := database.PutAccount(ctx, Account{Username: "alice", AuthToken: "token"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readDataFile(path); err != nil {
+		t.Fatalf("the migrated file does not read back: %v", err)
+	}
+
+	// Migrating again changes nothing.
+	from, to, err = MigrateFile(path, nil)
+	if err != nil || from != to {
+		t.Fatalf("second migration went from %d to %d: %v", from, to, err)
+	}
+}
+
+func TestSetupDatabaseSchema(t *testing.T) {
+	var tests = []struct {
+		name        string
+		content     string
+		autoMigrate bool
+		wantErr     string
+		wantNewer   bool
+	}{
+		{name: "old without auto-migrate", content: "{}", wantErr: "migrations behind"},
+		{name: "old with auto-migrate", content: "{}", autoMigrate: true},
+		{
+			name:        "newer",
+			content:     fmt.Sprintf(`{"schema_version": %d, "tenants": {}}`, FileMigrations.Latest()+1),
+			autoMigrate: true,
+			wantErr:     "newer than this binary",
+			wantNewer:   true,
+		},
+		{name: "fractional version", content: `{"schema_version": 1.5}`, wantErr: "must be an integer"},
+		{name: "current", content: fmt.Sprintf(`{"schema_version": %d, "tenants": {}}`, FileMigrations.Latest())},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var path = writeDataFile(t, test.content)
+			err := newFileDatabase(path, test.autoMigrate, "").SetupDatabase()
+
+			if test.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Fatalf("SetupDatabase = %v, want an error mentioning %q", err, test.wantErr)
+			}
+			var newer *migrate.NewerSchemaError
+			if errors.As(err, &newer) != test.wantNewer {
+				t.Fatalf("SetupDatabase = %v, want NewerSchemaError %v", err, test.wantNewer)
+			}
+			// A refused file is left as it was.
+			if data, _ := os.ReadFile(path); string(data) != test.content {
+				t.Fatalf("the data file changed to %s", data)
+			}
+		})
+	}
+}
+
+func TestPutAccounts(t *testing.T) {
+	var tests = []struct {
+		name    string
+		saveErr error
+		wantErr bool
+		// Whether each account is rejected, and the accounts kept.
+		rejected []bool
+		want     []string
+	}{
+		{name: "saved once", rejected: []bool{false, true, false}, want: []string{"alice", "bob", "carol"}},
+		{name: "save fails", saveErr: errors.New("disk full"), wantErr: true, want: []string{"alice"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store, err := newMemoryStore(Dataset{"default": {{Username: "alice", AuthToken: "a"}}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var saves int
+			store.persist = func() error {
+				saves++
+				return test.saveErr
+			}
+			var ctx = tenant.NewContext(context.Background(), "default")
+
+			errs, err := store.PutAccounts(ctx, []Account{
+				{Username: "bob", AuthToken: "b"},
+				{ID: NewUserID(), Username: "alice", AuthToken: "c"},
+				{Username: "carol", AuthToken: "c"},
+			})
+			if (err != nil) != test.wantErr {
+				t.Fatalf("PutAccounts error = %v, want error %v", err, test.wantErr)
+			}
+			if saves != 1 {
+				t.Fatalf("saved %d times, want once", saves)
+			}
+			for i, rejected := range test.rejected {
+				if (errs[i] != nil) != rejected {
+					t.Errorf("account %d error = %v, want rejected %v", i, errs[i], rejected)
+				}
+			}
+			if !test.wantErr && !errors.Is(errs[1], ErrUsernameTaken) {
+				t.Errorf("a second alice = %v, want ErrUsernameTaken", errs[1])
+			}
+
+			var usernames []string
+			store.ListAccounts(ctx, func(account Account) error {
+				usernames = append(usernames, account.Username)
+				return nil
+			})
+			if !slices.Equal(usernames, test.want) {
+				t.Fatalf("accounts = %v, want %v", usernames, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package tools
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"golearn/src/internal/tenant"
+	"os"
+)
+
+//go:embed fixtures/accounts.json
+var fixtureFiles embed.FS
+
+// fixtureAccount is one account in a fixture file. A fixture file maps
+// tenant IDs to their accounts. Balance is that of the default wallet;
+// Wallets lists any others.
+type fixtureAccount struct {
+	ID       string          `json:"id"`
+	Username string          `json:"username"`
+	Name     string          `json:"name"`
+	Token    string          `json:"token"`
+	Roles    []string        `json:"roles"`
+	Balance  int64           `json:"balance"`
+	Wallets  []fixtureWallet `json:"wallets"`
+}
+
+type fixtureWallet struct {
+	Name      string `json:"name"`
+	Currency  string `json:"currency"`
+	Precision int    `json:"precision"`
+	Balance   int64  `json:"balance"`
+}
+
+// LoadFixtures reads the accounts of a fixture file, or the built-in sample
+// accounts when path is empty. Accounts without an ID are given one when
+// they are stored.
+func LoadFixtures(path string) (Dataset, error) {
+	var raw []byte
+	var err error
+	if path == "" {
+		raw, err = fixtureFiles.ReadFile("fixtures/accounts.json")
+	} else {
+		raw, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading fixtures: %w", err)
+	}
+
+	var tenants map[string][]fixtureAccount
+	var decoder = json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err = decoder.Decode(&tenants); err != nil {
+		return nil, fmt.Errorf("fixtures %s: %w", path, err)
+	}
+
+	var data = Dataset{}
+	for tenantID, accounts := range tenants {
+		if !tenant.Valid(tenantID) {
+			return nil, fmt.Errorf("fixtures %s: %w: %q", path, tenant.ErrInvalidTenant, tenantID)
+		}
+		var seen = map[string]bool{}
+		for _, account := range accounts {
+			if account.Username == "" || account.Token == "" {
+				return nil, fmt.Errorf("fixtures %s: tenant %s: every account needs a username and token", path, tenantID)
+			}
+			if err := ValidateUsername(account.Username); err != nil {
+				return nil, fmt.Errorf("fixtures %s: tenant %s: %w", path, tenantID, err)
+			}
+			if account.ID != "" && !IsUserID(account.ID) {
+				return nil, fmt.Errorf("fixtures %s: tenant %s: %w, got %q", path, tenantID, ErrInvalidUserID, account.ID)
+			}
+			if seen[account.Username] || (account.ID != "" && seen[account.ID]) {
+				return nil, fmt.Errorf("fixtures %s: tenant %s: duplicate account %q", path, tenantID, account.Username)
+			}
+			seen[account.Username] = true
+			seen[account.ID] = true
+
+			var wallets = []Wallet{PointsWallet(account.Balance)}
+			for _, wallet := range account.Wallets {
+				if wallet.Name == DefaultWallet {
+					return nil, fmt.Errorf("fixtures %s: tenant %s: account %s: the %s wallet is set by balance", path, tenantID, account.Username, DefaultWallet)
+				}
+				wallets = append(wallets, Wallet(wallet))
+			}
+			if err := ValidateWallets(wallets); err != nil {
+				return nil, fmt.Errorf("fixtures %s: tenant %s: account %s: %w", path, tenantID, account.Username, err)
+			}
+
+			var name = account.Name
+			if name == "" {
+				name = account.Username
+			}
+			data[tenantID] = append(data[tenantID], Account{
+				ID:        account.ID,
+				Username:  account.Username,
+				Name:      name,
+				AuthToken: account.Token,
+				Roles:     account.Roles,
+				Wallets:   wallets,
+			})
+		}
+	}
+	return data, nil
+}
@@ -0,0 +1,99 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+var (
+	ErrHoldNotFound      = errors.New("hold not found")
+	ErrHoldExceeded      = errors.New("amount exceeds the held amount")
+	ErrInvalidHoldAmount = errors.New("hold amounts must be positive")
+)
+
+var validHoldID = regexp.MustCompile(`^hld_[0-9a-f]{16}$`)
+
+// Hold reserves Amount minor units of a wallet until ExpiresAt, for example
+// while an order is pending. Held units are not available to spend but stay
+// in the ledger balance until they are captured; Captured counts those
+// captured so far. A hold ends when it is captured in full, released or
+// expires.
+type Hold struct {
+	ID        string
+	Wallet    string
+	Amount    int64
+	Captured  int64
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+func NewHoldID() string {
+	return randomID("hld_")
+}
+
+func IsHoldID(s string) bool {
+	return validHoldID.MatchString(s)
+}
+
+// Active reports whether the hold still reserves anything at now.
+func (h Hold) Active(now time.Time) bool {
+	return h.Amount > 0 && now.Before(h.ExpiresAt)
+}
+
+// Available is the balance of the named wallet less its holds.
+func (p PointDetails) Available(wallet string) int64 {
+	var w, _ = p.Wallet(wallet)
+	return w.Balance - heldAmount(p.Holds, wallet)
+}
+
+// ValidateHolds checks that every hold has a valid ID, is unique, holds a
+// positive amount and belongs to one of wallets.
+func ValidateHolds(wallets []Wallet, holds []Hold) error {
+	var seen = map[string]bool{}
+	for _, hold := range holds {
+		switch {
+		case !IsHoldID(hold.ID):
+			return fmt.Errorf("hold ID must be hld_ followed by 16 hex digits, got %q", hold.ID)
+		case seen[hold.ID]:
+			return fmt.Errorf("duplicate hold %s", hold.ID)
+		case hold.Amount <= 0 || hold.Captured < 0:
+			return fmt.Errorf("hold %s: amount must be positive", hold.ID)
+		case walletIndex(wallets, hold.Wallet) < 0:
+			return fmt.Errorf("hold %s: %w %q", hold.ID, ErrWalletNotFound, hold.Wallet)
+		}
+		seen[hold.ID] = true
+	}
+	return nil
+}
+
+// activeHolds returns a copy of the holds still active at now.
+func activeHolds(holds []Hold, now time.Time) []Hold {
+	var active []Hold
+	for _, hold := range holds {
+		if hold.Active(now) {
+			active = append(active, hold)
+		}
+	}
+	return active
+}
+
+func heldAmount(holds []Hold, wallet string) int64 {
+	var held int64
+	for _, hold := range holds {
+		if hold.Wallet == wallet {
+			held += hold.Amount
+		}
+	}
+	return held
+}
+
+func holdIndex(holds []Hold, id string) int {
+	for i, hold := range holds {
+		if hold.ID == id {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,114 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHolds(t *testing.T) {
+	var capture = func(amount int64) func(context.Context, DatabaseInterface, string) error {
+		return func(ctx context.Context, s DatabaseInterface, id string) error {
+			_, _, err := s.CaptureHold(ctx, "alice", id, amount)
+			return err
+		}
+	}
+	var release = func(ctx context.Context, s DatabaseInterface, id string) error {
+		_, _, err := s.ReleaseHold(ctx, "alice", id)
+		return err
+	}
+
+	var tests = []struct {
+		name    string
+		wallet  string
+		amount  int64
+		advance time.Duration
+		then    func(ctx context.Context, s DatabaseInterface, id string) error
+		wantErr error
+		// The points balance and the amount still available afterwards.
+		balance, available int64
+	}{
+		{name: "place", amount: 40, balance: 100, available: 60},
+		{name: "place all", amount: 100, balance: 100, available: 0},
+		{name: "place more than available", amount: 101, wantErr: ErrInsufficientFunds, balance: 100, available: 100},
+		{name: "place nothing", amount: 0, wantErr: ErrInvalidHoldAmount, balance: 100, available: 100},
+		{name: "place negative", amount: -5, wantErr: ErrInvalidHoldAmount, balance: 100, available: 100},
+		{name: "unknown wallet", wallet: "usd", amount: 5, wantErr: ErrWalletNotFound, balance: 100, available: 100},
+		{name: "other wallet", wallet: "eur", amount: 500, balance: 100, available: 100},
+		{name: "partial capture", amount: 40, then: capture(15), balance: 85, available: 60},
+		{name: "full capture", amount: 40, then: capture(0), balance: 60, available: 60},
+		{name: "capture too much", amount: 40, then: capture(41), wantErr: ErrHoldExceeded, balance: 100, available: 60},
+		{name: "capture negative", amount: 40, then: capture(-1), wantErr: ErrInvalidHoldAmount, balance: 100, available: 60},
+		{name: "release", amount: 40, then: release, balance: 100, available: 100},
+		{name: "expired", amount: 40, advance: 2 * time.Minute, balance: 100, available: 100},
+		{name: "capture expired", amount: 40, advance: 2 * time.Minute, then: capture(10), wantErr: ErrHoldNotFound, balance: 100, available: 100},
+		{name: "release expired", amount: 40, advance: 2 * time.Minute, then: release, wantErr: ErrHoldNotFound, balance: 100, available: 100},
+		{name: "capture before expiry", amount: 40, advance: 59 * time.Second, then: capture(40), balance: 60, available: 60},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store, ctx, clock := newTestStore(t)
+			if test.wallet == "" {
+				test.wallet = DefaultWallet
+			}
+
+			hold, _, err := store.PlaceHold(ctx, "alice", test.wallet, test.amount, time.Minute)
+			if err == nil {
+				clock.now = clock.now.Add(test.advance)
+				if test.then != nil {
+					err = test.then(ctx, store, hold.ID)
+				}
+			}
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("got error %v, want %v", err, test.wantErr)
+			}
+
+			details, err := store.GetUserPointDetails(ctx, "alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var points, _ = details.Wallet(DefaultWallet)
+			if points.Balance != test.balance || details.Available(DefaultWallet) != test.available {
+				t.Fatalf("balance %d, available %d; want %d and %d",
+					points.Balance, details.Available(DefaultWallet), test.balance, test.available)
+			}
+		})
+	}
+}
+
+func TestExpireHolds(t *testing.T) {
+	store, ctx, clock := newTestStore(t)
+	for _, ttl := range []time.Duration{time.Minute, time.Minute, time.Hour} {
+		if _, _, err := store.PlaceHold(ctx, "alice", DefaultWallet, 10, ttl); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	clock.now = clock.now.Add(2 * time.Minute)
+	// Expired holds stop counting at once, before they are deleted.
+	details, err := store.GetUserPointDetails(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(details.Holds) != 1 || details.Available(DefaultWallet) != 90 {
+		t.Fatalf("got %d holds and %d available, want 1 and 90", len(details.Holds), details.Available(DefaultWallet))
+	}
+
+	for _, want := range []int{2, 0} {
+		expired, err := store.ExpireHolds(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expired != want {
+			t.Fatalf("ExpireHolds = %d, want %d", expired, want)
+		}
+	}
+	account, err := store.GetAccount(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(account.Holds) != 1 {
+		t.Fatalf("stored holds = %+v, want the one that has not expired", account.Holds)
+	}
+}
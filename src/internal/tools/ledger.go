@@ -0,0 +1,62 @@
+package tools
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrInvalidAdjustment = errors.New("an adjustment needs a non-zero amount and a reason")
+
+// Kinds of ledger entries.
+const (
+	LedgerCredit     = "credit"
+	LedgerDebit      = "debit"
+	LedgerCapture    = "capture"
+	LedgerAdjustment = "adjustment"
+	LedgerExpiry     = "expiry"
+)
+
+// LedgerEntry records one change to the balance of a wallet. Amount is
+// signed and Balance is the balance of the wallet after the change.
+// Reference ties the entry to what caused it, such as the captured hold.
+type LedgerEntry struct {
+	Wallet    string
+	Kind      string
+	Amount    int64
+	Balance   int64
+	At        time.Time
+	Reference string
+}
+
+// ReferenceImport marks the adjustments made when an account is written
+// whole by PutAccount, as a bulk import does.
+const ReferenceImport = "import"
+
+// ledgerEntry describes a change of delta to wallet i of account, which
+// must already have been applied.
+func ledgerEntry(account Account, i int, kind string, delta int64, at time.Time, reference string) LedgerEntry {
+	return LedgerEntry{
+		Wallet:    account.Wallets[i].Name,
+		Kind:      kind,
+		Amount:    delta,
+		Balance:   account.Wallets[i].Balance,
+		At:        at.UTC(),
+		Reference: reference,
+	}
+}
+
+// adjustments describes how the wallet balances of account differ from
+// those of previous, as adjustment entries.
+func adjustments(previous []Wallet, account Account, at time.Time) []LedgerEntry {
+	var entries []LedgerEntry
+	for i, wallet := range account.Wallets {
+		var before int64
+		if j := walletIndex(previous, wallet.Name); j >= 0 {
+			before = previous[j].Balance
+		}
+		if wallet.Balance != before {
+			entries = append(entries, ledgerEntry(account, i, LedgerAdjustment, wallet.Balance-before, at, ReferenceImport))
+		}
+	}
+	return entries
+}
@@ -0,0 +1,668 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"golearn/src/internal/tenant"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryStore keeps accounts by tenant, then user ID, with an index from
+// username to ID. The same username may exist in several tenants as
+// unrelated accounts. It implements the account methods of
+// DatabaseInterface for the mock and file databases.
+type memoryStore struct {
+	mu      sync.RWMutex
+	tenants map[string]*tenantAccounts
+	now     func() time.Time
+	// persist, when set, is called with the write lock held after every
+	// change. If it fails the change is rolled back.
+	persist func() error
+}
+
+type tenantAccounts struct {
+	byID map[string]Account
+	ids  map[string]string
+}
+
+func newMemoryStore(data Dataset) (*memoryStore, error) {
+	tenants, err := buildTenants(data)
+	if err != nil {
+		return nil, err
+	}
+	return &memoryStore{tenants: tenants, now: time.Now}, nil
+}
+
+// buildTenants indexes data, giving accounts without an ID a new one and
+// accounts without the default wallet an empty one.
+func buildTenants(data Dataset) (map[string]*tenantAccounts, error) {
+	var tenants = map[string]*tenantAccounts{}
+	for tenantID, accounts := range data {
+		var t = newTenantAccounts()
+		for _, account := range accounts {
+			account = copyAccount(account)
+			if account.ID == "" {
+				account.ID = NewUserID()
+			}
+			wallets, err := normalizeWallets(account.Wallets)
+			if err != nil {
+				return nil, fmt.Errorf("tenant %s: account %s: %w", tenantID, account.Username, err)
+			}
+			account.Wallets = wallets
+			if err = ValidateHolds(account.Wallets, account.Holds); err != nil {
+				return nil, fmt.Errorf("tenant %s: account %s: %w", tenantID, account.Username, err)
+			}
+			if _, dup := t.byID[account.ID]; dup {
+				return nil, fmt.Errorf("tenant %s: duplicate user ID %s", tenantID, account.ID)
+			}
+			if _, dup := t.ids[account.Username]; dup {
+				return nil, fmt.Errorf("tenant %s: duplicate username %q", tenantID, account.Username)
+			}
+			t.byID[account.ID] = account
+			t.ids[account.Username] = account.ID
+		}
+		tenants[tenantID] = t
+	}
+	return tenants, nil
+}
+
+func newTenantAccounts() *tenantAccounts {
+	return &tenantAccounts{byID: map[string]Account{}, ids: map[string]string{}}
+}
+
+// SetClock makes the store read the time from now, for renames, holds and
+// the ledger.
+// Call it before the store is used.
+func (s *memoryStore) SetClock(now func() time.Time) {
+	s.now = now
+}
+
+func (s *memoryStore) GetUserLoginDetails(ctx context.Context, user string) (*LoginDetails, error) {
+	account, err := s.get(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	return &LoginDetails{
+		UserID:    account.ID,
+		Username:  account.Username,
+		Name:      account.Name,
+		AuthToken: account.AuthToken,
+		Roles:     account.Roles,
+	}, nil
+}
+
+func (s *memoryStore) GetUserPointDetails(ctx context.Context, user string) (*PointDetails, error) {
+	account, err := s.get(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	return pointDetails(account), nil
+}
+
+func (s *memoryStore) GetAccount(ctx context.Context, user string) (*Account, error) {
+	account, err := s.get(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func (s *memoryStore) UpdateUserBalance(ctx context.Context, user string, wallet string, delta int64) (*PointDetails, error) {
+	var kind = LedgerCredit
+	if delta < 0 {
+		kind = LedgerDebit
+	}
+	return s.changeBalance(ctx, user, wallet, delta, kind, "")
+}
+
+func (s *memoryStore) AdjustBalance(ctx context.Context, user string, wallet string, delta int64, reason string) (*PointDetails, error) {
+	reason = strings.TrimSpace(reason)
+	if delta == 0 || reason == "" {
+		return nil, ErrInvalidAdjustment
+	}
+	return s.changeBalance(ctx, user, wallet, delta, LedgerAdjustment, reason)
+}
+
+// changeBalance adds delta to the user's wallet and records it in the
+// ledger as kind.
+func (s *memoryStore) changeBalance(ctx context.Context, user string, wallet string, delta int64, kind string, reference string) (*PointDetails, error) {
+	tenantID, err := tenant.MustFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, ok := s.find(tenantID, user)
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	var account = s.live(previous)
+	var i = walletIndex(account.Wallets, wallet)
+	if i < 0 {
+		return nil, ErrWalletNotFound
+	}
+	if delta < 0 && account.Wallets[i].Balance-heldAmount(account.Holds, wallet)+delta < 0 {
+		return nil, ErrInsufficientFunds
+	}
+	if delta > 0 && account.Wallets[i].Balance > math.MaxInt64-delta {
+		return nil, ErrBalanceOverflow
+	}
+
+	account.Wallets[i].Balance += delta
+	account.Ledger = append(account.Ledger, ledgerEntry(account, i, kind, delta, s.now(), reference))
+	s.set(tenantID, account)
+	if err := s.save(); err != nil {
+		s.set(tenantID, previous)
+		return nil, err
+	}
+
+	return pointDetails(account), nil
+}
+
+func (s *memoryStore) OpenWallet(ctx context.Context, user string, wallet Wallet) (*PointDetails, error) {
+	tenantID, err := tenant.MustFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	wallet.Balance = 0
+	if err = wallet.Validate(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, ok := s.find(tenantID, user)
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	var account = s.live(previous)
+	if walletIndex(account.Wallets, wallet.Name) >= 0 {
+		return nil, ErrWalletExists
+	}
+
+	account.Wallets = append(account.Wallets, wallet)
+	sortWallets(account.Wallets)
+	s.set(tenantID, account)
+	if err := s.save(); err != nil {
+		s.set(tenantID, previous)
+		return nil, err
+	}
+
+	return pointDetails(account), nil
+}
+
+func (s *memoryStore) PlaceHold(ctx context.Context, user string, wallet string, amount int64, ttl time.Duration) (*Hold, *PointDetails, error) {
+	tenantID, err := tenant.MustFromContext(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if amount <= 0 {
+		return nil, nil, ErrInvalidHoldAmount
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, ok := s.find(tenantID, user)
+	if !ok {
+		return nil, nil, ErrUserNotFound
+	}
+	var account = s.live(previous)
+	var i = walletIndex(account.Wallets, wallet)
+	if i < 0 {
+		return nil, nil, ErrWalletNotFound
+	}
+	if account.Wallets[i].Balance-heldAmount(account.Holds, wallet) < amount {
+		return nil, nil, ErrInsufficientFunds
+	}
+
+	var now = s.now().UTC()
+	var hold = Hold{ID: NewHoldID(), Wallet: wallet, Amount: amount, CreatedAt: now, ExpiresAt: now.Add(ttl)}
+	account.Holds = append(account.Holds, hold)
+	s.set(tenantID, account)
+	if err := s.save(); err != nil {
+		s.set(tenantID, previous)
+		return nil, nil, err
+	}
+
+	return &hold, pointDetails(account), nil
+}
+
+func (s *memoryStore) CaptureHold(ctx context.Context, user string, holdID string, amount int64) (*Hold, *PointDetails, error) {
+	if amount < 0 {
+		return nil, nil, ErrInvalidHoldAmount
+	}
+	return s.changeHold(ctx, user, holdID, func(account *Account, hold *Hold) (bool, error) {
+		if amount == 0 {
+			amount = hold.Amount
+		}
+		if amount > hold.Amount {
+			return false, ErrHoldExceeded
+		}
+		var i = walletIndex(account.Wallets, hold.Wallet)
+		if i < 0 {
+			return false, ErrWalletNotFound
+		}
+		account.Wallets[i].Balance -= amount
+		account.Ledger = append(account.Ledger, ledgerEntry(*account, i, LedgerCapture, -amount, s.now(), hold.ID))
+		hold.Amount -= amount
+		hold.Captured += amount
+		return hold.Amount > 0, nil
+	})
+}
+
+func (s *memoryStore) ReleaseHold(ctx context.Context, user string, holdID string) (*Hold, *PointDetails, error) {
+	return s.changeHold(ctx, user, holdID, func(account *Account, hold *Hold) (bool, error) {
+		return false, nil
+	})
+}
+
+// changeHold applies change to an active hold of the user and its account,
+// then keeps the hold if change says so and drops it otherwise.
+func (s *memoryStore) changeHold(ctx context.Context, user string, holdID string, change func(*Account, *Hold) (bool, error)) (*Hold, *PointDetails, error) {
+	tenantID, err := tenant.MustFromContext(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, ok := s.find(tenantID, user)
+	if !ok {
+		return nil, nil, ErrUserNotFound
+	}
+	var account = s.live(previous)
+	var i = holdIndex(account.Holds, holdID)
+	if i < 0 {
+		return nil, nil, ErrHoldNotFound
+	}
+
+	var hold = account.Holds[i]
+	keep, err := change(&account, &hold)
+	if err != nil {
+		return nil, nil, err
+	}
+	if keep {
+		account.Holds[i] = hold
+	} else {
+		account.Holds = append(account.Holds[:i], account.Holds[i+1:]...)
+	}
+	s.set(tenantID, account)
+	if err := s.save(); err != nil {
+		s.set(tenantID, previous)
+		return nil, nil, err
+	}
+
+	return &hold, pointDetails(account), nil
+}
+
+func (s *memoryStore) RenameUser(ctx context.Context, user string, username string) (*LoginDetails, error) {
+	tenantID, err := tenant.MustFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = ValidateUsername(username); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	account, ok := s.find(tenantID, user)
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	if account.Username != username {
+		if _, taken := s.tenants[tenantID].ids[username]; taken {
+			return nil, ErrUsernameTaken
+		}
+
+		var previous = account
+		account = copyAccount(account)
+		account.Renames = append(account.Renames, Rename{From: account.Username, To: username, At: s.now().UTC()})
+		account.Username = username
+		s.set(tenantID, account)
+		if err := s.save(); err != nil {
+			s.set(tenantID, previous)
+			return nil, err
+		}
+	}
+
+	return &LoginDetails{
+		UserID:    account.ID,
+		Username:  account.Username,
+		Name:      account.Name,
+		AuthToken: account.AuthToken,
+		Roles:     append([]string(nil), account.Roles...),
+	}, nil
+}
+
+func (s *memoryStore) ListAccounts(ctx context.Context, fn func(Account) error) error {
+	tenantID, err := tenant.MustFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.RLock()
+	var usernames []string
+	var ids = map[string]string{}
+	if t := s.tenants[tenantID]; t != nil {
+		usernames = make([]string, 0, len(t.ids))
+		for username, id := range t.ids {
+			usernames = append(usernames, username)
+			ids[username] = id
+		}
+	}
+	s.mu.RUnlock()
+	sort.Strings(usernames)
+
+	// fn runs without the lock held so that slow consumers do not block
+	// writers; accounts removed meanwhile are skipped.
+	for _, username := range usernames {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		s.mu.RLock()
+		account, ok := s.find(tenantID, ids[username])
+		s.mu.RUnlock()
+		if !ok {
+			continue
+		}
+		if err := fn(s.live(account)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) PutAccount(ctx context.Context, account Account) error {
+	tenantID, err := tenant.MustFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	undo, err := s.putAccount(tenantID, account)
+	if err != nil {
+		return err
+	}
+	if err := s.save(); err != nil {
+		undo()
+		return err
+	}
+	return nil
+}
+
+func (s *memoryStore) PutAccounts(ctx context.Context, accounts []Account) ([]error, error) {
+	tenantID, err := tenant.MustFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs = make([]error, len(accounts))
+	var undos []func()
+	for i, account := range accounts {
+		undo, err := s.putAccount(tenantID, account)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		undos = append(undos, undo)
+	}
+	if len(undos) == 0 {
+		return errs, nil
+	}
+	if err := s.save(); err != nil {
+		for i := len(undos) - 1; i >= 0; i-- {
+			undos[i]()
+		}
+		return nil, err
+	}
+	return errs, nil
+}
+
+// putAccount applies PutAccount without saving and returns a function that
+// reverts it. The caller must hold the write lock and, when reverting
+// several changes, revert them in reverse order.
+func (s *memoryStore) putAccount(tenantID string, account Account) (func(), error) {
+	if err := ValidateUsername(account.Username); err != nil {
+		return nil, err
+	}
+	if account.ID != "" && !IsUserID(account.ID) {
+		return nil, ErrInvalidUserID
+	}
+	account = copyAccount(account)
+	var err error
+	if account.Wallets, err = normalizeWallets(account.Wallets); err != nil {
+		return nil, err
+	}
+
+	var created = s.tenants[tenantID] == nil
+	if created {
+		s.tenants[tenantID] = newTenantAccounts()
+	}
+	var t = s.tenants[tenantID]
+
+	var previous Account
+	var existed bool
+	if account.ID != "" {
+		previous, existed = t.byID[account.ID]
+	} else if id, ok := t.ids[account.Username]; ok {
+		previous, existed = t.byID[id], true
+		account.ID = id
+	} else {
+		account.ID = NewUserID()
+	}
+	if owner, taken := t.ids[account.Username]; taken && owner != account.ID {
+		if created {
+			delete(s.tenants, tenantID)
+		}
+		return nil, ErrUsernameTaken
+	}
+	account.Holds = nil
+	account.Ledger = nil
+	var before []Wallet
+	if existed {
+		before = previous.Wallets
+		account.Ledger = copyAccount(previous).Ledger
+		for _, hold := range s.live(previous).Holds {
+			if walletIndex(account.Wallets, hold.Wallet) >= 0 {
+				account.Holds = append(account.Holds, hold)
+			}
+		}
+		account.Renames = copyAccount(previous).Renames
+		if previous.Username != account.Username {
+			account.Renames = append(account.Renames, Rename{From: previous.Username, To: account.Username, At: s.now().UTC()})
+		}
+	}
+	account.Ledger = append(account.Ledger, adjustments(before, account, s.now())...)
+
+	s.set(tenantID, account)
+	return func() {
+		switch {
+		case created:
+			delete(s.tenants, tenantID)
+		case existed:
+			s.set(tenantID, previous)
+		default:
+			delete(t.byID, account.ID)
+			delete(t.ids, account.Username)
+		}
+	}, nil
+}
+
+func (s *memoryStore) Snapshot(ctx context.Context) (Dataset, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.dataset(), nil
+}
+
+func (s *memoryStore) Restore(ctx context.Context, data Dataset) error {
+	// Build the new index first so the swap itself cannot fail half way.
+	tenants, err := buildTenants(data)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var previous = s.tenants
+	s.tenants = tenants
+	if err := s.save(); err != nil {
+		s.tenants = previous
+		return err
+	}
+	return nil
+}
+
+func (s *memoryStore) ExpireHolds(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var previous = map[string][]Account{}
+	var expired int
+	for tenantID, t := range s.tenants {
+		for id, account := range t.byID {
+			var live = s.live(account)
+			if len(live.Holds) == len(account.Holds) {
+				continue
+			}
+			expired += len(account.Holds) - len(live.Holds)
+			previous[tenantID] = append(previous[tenantID], account)
+			t.byID[id] = live
+		}
+	}
+	if expired == 0 {
+		return 0, nil
+	}
+	if err := s.save(); err != nil {
+		for tenantID, accounts := range previous {
+			for _, account := range accounts {
+				s.set(tenantID, account)
+			}
+		}
+		return 0, err
+	}
+	return expired, nil
+}
+
+// get copies the user of the context's tenant.
+func (s *memoryStore) get(ctx context.Context, user string) (Account, error) {
+	tenantID, err := tenant.MustFromContext(ctx)
+	if err != nil {
+		return Account{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	account, ok := s.find(tenantID, user)
+	if !ok {
+		return Account{}, ErrUserNotFound
+	}
+	return s.live(account), nil
+}
+
+// find looks user up by ID, then by username. The caller must hold the
+// lock and must not modify the slices of the result.
+func (s *memoryStore) find(tenantID string, user string) (Account, bool) {
+	var t = s.tenants[tenantID]
+	if t == nil {
+		return Account{}, false
+	}
+	if account, ok := t.byID[user]; ok {
+		return account, true
+	}
+	if id, ok := t.ids[user]; ok {
+		return t.byID[id], true
+	}
+	return Account{}, false
+}
+
+// set stores account under its ID and reindexes its username. The caller
+// must hold the write lock.
+func (s *memoryStore) set(tenantID string, account Account) {
+	var t = s.tenants[tenantID]
+	if current, ok := t.byID[account.ID]; ok && current.Username != account.Username {
+		delete(t.ids, current.Username)
+	}
+	t.byID[account.ID] = account
+	t.ids[account.Username] = account.ID
+}
+
+// dataset copies every account. The caller must hold the lock.
+func (s *memoryStore) dataset() Dataset {
+	var data = Dataset{}
+	for tenantID, t := range s.tenants {
+		var accounts = make([]Account, 0, len(t.byID))
+		for _, account := range t.byID {
+			accounts = append(accounts, s.live(account))
+		}
+		sort.Slice(accounts, func(i, j int) bool { return accounts[i].Username < accounts[j].Username })
+		data[tenantID] = accounts
+	}
+	return data
+}
+
+func (s *memoryStore) save() error {
+	if s.persist == nil {
+		return nil
+	}
+	return s.persist()
+}
+
+// copyAccount copies account so that its slices are not shared.
+func copyAccount(account Account) Account {
+	account.Roles = append([]string(nil), account.Roles...)
+	account.Wallets = append([]Wallet(nil), account.Wallets...)
+	account.Holds = append([]Hold(nil), account.Holds...)
+	account.Renames = append([]Rename(nil), account.Renames...)
+	account.Ledger = append([]LedgerEntry(nil), account.Ledger...)
+	return account
+}
+
+// copyLoginDetails copies details so that its roles are not shared.
+func copyLoginDetails(details LoginDetails) *LoginDetails {
+	details.Roles = append([]string(nil), details.Roles...)
+	return &details
+}
+
+// copyPointDetails copies details so that its wallets and holds are not
+// shared.
+func copyPointDetails(details PointDetails) *PointDetails {
+	details.Wallets = append([]Wallet(nil), details.Wallets...)
+	details.Holds = append([]Hold(nil), details.Holds...)
+	return &details
+}
+
+// live copies account without its expired holds. Holds are only dropped
+// from the store when the account is next written.
+func (s *memoryStore) live(account Account) Account {
+	account = copyAccount(account)
+	account.Holds = activeHolds(account.Holds, s.now())
+	return account
+}
+
+func pointDetails(account Account) *PointDetails {
+	return &PointDetails{
+		UserID:   account.ID,
+		Username: account.Username,
+		Wallets:  append([]Wallet(nil), account.Wallets...),
+		Holds:    append([]Hold(nil), account.Holds...),
+	}
+}
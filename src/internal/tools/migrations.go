@@ -0,0 +1,24 @@
+package tools
+
+import (
+	"golearn/src/internal/migrate"
+)
+
+// FileMigrations upgrade the data file of the file database. Add new ones
+// at the end; never change one that has been released.
+var FileMigrations = &migrate.Registry{}
+
+func init() {
+	// Version 1 is the layout the file database shipped with, described by
+	// fileDocument. Before it there is only the empty file.
+	FileMigrations.Register(migrate.Migration{
+		Version: 1,
+		Name:    "initial layout",
+		Up: func(doc migrate.Document) error {
+			if _, ok := doc["tenants"]; !ok {
+				doc["tenants"] = map[string]any{}
+			}
+			return nil
+		},
+	})
+}
@@ -1,65 +1,271 @@
 package tools
 
 import (
+	"context"
+	"math/rand/v2"
+	"sync"
 	"time"
 )
 
-type mockDatabase struct{}
+// mockDatabase is an in-memory store that injects the latency and failures
+// of its MockScenario into every call.
+type mockDatabase struct {
+	*memoryStore
 
-var mockLoginDetails = map[string]LoginDetails{
-	"damien": {
-		AuthToken: "ABC123",
-		Username:  "bob",
-	},
-	"bella": {
-		AuthToken: "DEF456",
-		Username:  "jane",
-	},
-	"addison": {
-		AuthToken: "GHI789",
-		Username:  "john",
-	},
+	mu       sync.Mutex
+	scenario MockScenario
+	scripts  map[string][]string
+	rng      *rand.Rand
 }
 
-var mockPointDetails = map[string]PointDetails{
-	"damien": {
-		Username: "bob",
-		Balance:  100,
-	},
-	"bella": {
-		Username: "jane",
-		Balance:  200,
-	},
-	"addison": {
-		Username: "john",
-		Balance:  300,
-	},
+// MockController adjusts a running mock database.
+type MockController interface {
+	// Scenario returns the current scenario, with only the script steps
+	// that have not run yet.
+	Scenario() MockScenario
+	// SetScenario validates scenario and replaces the current one.
+	SetScenario(scenario MockScenario) error
+	// SetClock makes the store read the time from now, for renames and
+	// hold expiry.
+	SetClock(now func() time.Time)
 }
 
-func (d *mockDatabase) GetUserLoginDetails(username string) *LoginDetails {
-	time.Sleep(time.Second * 1)
+// NewMockDatabase returns a mock database holding data and running
+// scenario. The result is also a MockController.
+func NewMockDatabase(data Dataset, scenario MockScenario) (DatabaseInterface, error) {
+	database, err := newMockDatabase(data, scenario)
+	if err != nil {
+		return nil, err
+	}
+	return database, nil
+}
+
+func newMockDatabase(data Dataset, scenario MockScenario) (*mockDatabase, error) {
+	store, err := newMemoryStore(data)
+	if err != nil {
+		return nil, err
+	}
+	var d = &mockDatabase{memoryStore: store}
+	return d, d.SetScenario(scenario)
+}
+
+func (d *mockDatabase) Scenario() MockScenario {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var scenario = d.scenario
+	scenario.Scripts = map[string][]string{}
+	for method, steps := range d.scripts {
+		if len(steps) > 0 {
+			scenario.Scripts[method] = append([]string(nil), steps...)
+		}
+	}
+	return scenario
+}
+
+func (d *mockDatabase) SetScenario(scenario MockScenario) error {
+	if err := scenario.Validate(); err != nil {
+		return err
+	}
+
+	var seed = scenario.Seed
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	var scripts = map[string][]string{}
+	for method, steps := range scenario.Scripts {
+		scripts[method] = append([]string(nil), steps...)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.scenario = scenario
+	d.scripts = scripts
+	d.rng = rand.New(rand.NewPCG(seed, seed))
+	return nil
+}
+
+func (d *mockDatabase) GetUserLoginDetails(ctx context.Context, user string) (*LoginDetails, error) {
+	if err := d.fault(ctx, "GetUserLoginDetails", user); err != nil {
+		return nil, err
+	}
+	return d.memoryStore.GetUserLoginDetails(ctx, user)
+}
+
+func (d *mockDatabase) GetUserPointDetails(ctx context.Context, user string) (*PointDetails, error) {
+	if err := d.fault(ctx, "GetUserPointDetails", user); err != nil {
+		return nil, err
+	}
+	return d.memoryStore.GetUserPointDetails(ctx, user)
+}
+
+func (d *mockDatabase) UpdateUserBalance(ctx context.Context, user string, wallet string, delta int64) (*PointDetails, error) {
+	if err := d.fault(ctx, "UpdateUserBalance", user); err != nil {
+		return nil, err
+	}
+	return d.memoryStore.UpdateUserBalance(ctx, user, wallet, delta)
+}
+
+func (d *mockDatabase) AdjustBalance(ctx context.Context, user string, wallet string, delta int64, reason string) (*PointDetails, error) {
+	if err := d.fault(ctx, "AdjustBalance", user); err != nil {
+		return nil, err
+	}
+	return d.memoryStore.AdjustBalance(ctx, user, wallet, delta, reason)
+}
+
+func (d *mockDatabase) OpenWallet(ctx context.Context, user string, wallet Wallet) (*PointDetails, error) {
+	if err := d.fault(ctx, "OpenWallet", user); err != nil {
+		return nil, err
+	}
+	return d.memoryStore.OpenWallet(ctx, user, wallet)
+}
+
+func (d *mockDatabase) PlaceHold(ctx context.Context, user string, wallet string, amount int64, ttl time.Duration) (*Hold, *PointDetails, error) {
+	if err := d.fault(ctx, "PlaceHold", user); err != nil {
+		return nil, nil, err
+	}
+	return d.memoryStore.PlaceHold(ctx, user, wallet, amount, ttl)
+}
+
+func (d *mockDatabase) CaptureHold(ctx context.Context, user string, holdID string, amount int64) (*Hold, *PointDetails, error) {
+	if err := d.fault(ctx, "CaptureHold", user); err != nil {
+		return nil, nil, err
+	}
+	return d.memoryStore.CaptureHold(ctx, user, holdID, amount)
+}
+
+func (d *mockDatabase) ReleaseHold(ctx context.Context, user string, holdID string) (*Hold, *PointDetails, error) {
+	if err := d.fault(ctx, "ReleaseHold", user); err != nil {
+		return nil, nil, err
+	}
+	return d.memoryStore.ReleaseHold(ctx, user, holdID)
+}
+
+func (d *mockDatabase) ExpireHolds(ctx context.Context) (int, error) {
+	if err := d.fault(ctx, "ExpireHolds", ""); err != nil {
+		return 0, err
+	}
+	return d.memoryStore.ExpireHolds(ctx)
+}
+
+func (d *mockDatabase) GetAccount(ctx context.Context, user string) (*Account, error) {
+	if err := d.fault(ctx, "GetAccount", user); err != nil {
+		return nil, err
+	}
+	return d.memoryStore.GetAccount(ctx, user)
+}
+
+func (d *mockDatabase) RenameUser(ctx context.Context, user string, username string) (*LoginDetails, error) {
+	if err := d.fault(ctx, "RenameUser", user); err != nil {
+		return nil, err
+	}
+	return d.memoryStore.RenameUser(ctx, user, username)
+}
 
-	var clientData = LoginDetails{}
-	clientData, ok := mockLoginDetails[username]
-	if !ok {
-		return nil
+func (d *mockDatabase) ListAccounts(ctx context.Context, fn func(Account) error) error {
+	if err := d.fault(ctx, "ListAccounts", ""); err != nil {
+		return err
 	}
+	return d.memoryStore.ListAccounts(ctx, fn)
+}
 
-	return &clientData
+func (d *mockDatabase) PutAccount(ctx context.Context, account Account) error {
+	if err := d.fault(ctx, "PutAccount", account.Username); err != nil {
+		return err
+	}
+	return d.memoryStore.PutAccount(ctx, account)
 }
 
-func (d *mockDatabase) GetUserPointDetails(username string) *PointDetails {
-	time.Sleep(time.Second * 1)
+func (d *mockDatabase) PutAccounts(ctx context.Context, accounts []Account) ([]error, error) {
+	if err := d.fault(ctx, "PutAccounts", ""); err != nil {
+		return nil, err
+	}
+	return d.memoryStore.PutAccounts(ctx, accounts)
+}
 
-	var pointData = PointDetails{}
-	pointData, ok := mockPointDetails[username]
-	if !ok {
-		return nil
+func (d *mockDatabase) Snapshot(ctx context.Context) (Dataset, error) {
+	if err := d.fault(ctx, "Snapshot", ""); err != nil {
+		return nil, err
 	}
+	return d.memoryStore.Snapshot(ctx)
+}
 
-	return &pointData
+func (d *mockDatabase) Restore(ctx context.Context, data Dataset) error {
+	if err := d.fault(ctx, "Restore", ""); err != nil {
+		return err
+	}
+	return d.memoryStore.Restore(ctx, data)
 }
 
 func (d *mockDatabase) SetupDatabase() error {
 	return nil
 }
+
+func (d *mockDatabase) Ping(ctx context.Context) error {
+	return d.fault(ctx, "Ping", "")
+}
+
+// fault simulates the round trip to a real database, waiting and failing as
+// the scenario says for method and user, an ID or username. It gives up
+// early if ctx is done.
+func (d *mockDatabase) fault(ctx context.Context, method string, user string) error {
+	var delay, fault = d.plan(method, user)
+	if fault == FaultTimeout {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	var timer = time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	switch fault {
+	case FaultError:
+		return ErrInjected
+	case FaultNotFound:
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// plan decides the delay and fault of one call: the next script step of
+// method if there is one, otherwise a draw from the resolved Faults.
+func (d *mockDatabase) plan(method string, user string) (time.Duration, string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if steps := d.scripts[method]; len(steps) > 0 {
+		d.scripts[method] = steps[1:]
+		fault, delay, _ := parseStep(steps[0])
+		return delay, fault
+	}
+
+	var faults = d.scenario.Default
+	if methodFaults, ok := d.scenario.Methods[method]; ok {
+		faults = faults.overlay(methodFaults)
+	}
+	if userFaults, ok := d.scenario.Users[user]; ok && user != "" {
+		faults = faults.overlay(userFaults)
+	}
+
+	var delay time.Duration
+	if faults.Latency != nil {
+		delay = faults.Latency.sample(d.rng)
+	}
+	if faults.ErrorRate != nil && d.rng.Float64() < *faults.ErrorRate {
+		if faults.Error == "" {
+			return delay, FaultError
+		}
+		return delay, faults.Error
+	}
+	return delay, FaultOK
+}
+
+func (d *mockDatabase) Close() error {
+	return nil
+}
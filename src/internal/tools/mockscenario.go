@@ -0,0 +1,215 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golearn/src/internal/config"
+	"math/rand/v2"
+	"os"
+	"sort"
+	"time"
+)
+
+// ErrInjected is the failure the mock database injects for the "error"
+// fault.
+var ErrInjected = errors.New("injected database failure")
+
+// mockMethods are the calls a MockScenario can target.
+var mockMethods = map[string]bool{
+	"GetUserLoginDetails": true,
+	"GetUserPointDetails": true,
+	"UpdateUserBalance":   true,
+	"AdjustBalance":       true,
+	"OpenWallet":          true,
+	"PlaceHold":           true,
+	"CaptureHold":         true,
+	"ReleaseHold":         true,
+	"GetAccount":          true,
+	"RenameUser":          true,
+	"ListAccounts":        true,
+	"PutAccount":          true,
+	"PutAccounts":         true,
+	"Snapshot":            true,
+	"Restore":             true,
+	"ExpireHolds":         true,
+	"Ping":                true,
+}
+
+// Faults the mock database can inject, in scripts and as Faults.Error.
+const (
+	FaultOK       = "ok"
+	FaultError    = "error"
+	FaultNotFound = "not_found"
+	// FaultTimeout blocks until the caller's context is done.
+	FaultTimeout = "timeout"
+)
+
+// MockScenario configures the latency and failures of the mock database.
+// Settings are resolved per call: Users, for calls about that user ID or
+// username as given by the caller, then Methods, then Default. Scripts are consumed first, one step per call
+// of the method, and may be a fault name or a duration to wait before
+// succeeding. A non-zero Seed makes random choices repeatable.
+type MockScenario struct {
+	Seed    uint64              `json:"seed,omitempty"`
+	Default Faults              `json:"default"`
+	Methods map[string]Faults   `json:"methods,omitempty"`
+	Users   map[string]Faults   `json:"users,omitempty"`
+	Scripts map[string][]string `json:"scripts,omitempty"`
+}
+
+// Faults are the settings of one level of a MockScenario. Unset fields fall
+// through to the next level.
+type Faults struct {
+	Latency *Latency `json:"latency,omitempty"`
+	// ErrorRate is the probability, from 0 to 1, that a call fails with
+	// Error ("error" by default).
+	ErrorRate *float64 `json:"error_rate,omitempty"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// Latency is a distribution of call durations: "fixed" uses Duration,
+// "uniform" ranges from Min to Max, "normal" uses Mean and StdDev, and
+// "exponential" uses Mean.
+type Latency struct {
+	Distribution string          `json:"distribution"`
+	Duration     config.Duration `json:"duration,omitzero"`
+	Min          config.Duration `json:"min,omitzero"`
+	Max          config.Duration `json:"max,omitzero"`
+	Mean         config.Duration `json:"mean,omitzero"`
+	StdDev       config.Duration `json:"stddev,omitzero"`
+}
+
+// LoadMockScenario reads a scenario file. An empty path gives the default
+// scenario: every call takes latency and succeeds.
+func LoadMockScenario(path string, latency time.Duration) (MockScenario, error) {
+	var scenario = MockScenario{}
+	if path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return scenario, fmt.Errorf("reading mock scenario: %w", err)
+		}
+		var decoder = json.NewDecoder(bytes.NewReader(raw))
+		decoder.DisallowUnknownFields()
+		if err = decoder.Decode(&scenario); err != nil {
+			return scenario, fmt.Errorf("mock scenario %s: %w", path, err)
+		}
+	}
+	if scenario.Default.Latency == nil {
+		scenario.Default.Latency = &Latency{Distribution: "fixed", Duration: config.Duration{Duration: latency}}
+	}
+	return scenario, scenario.Validate()
+}
+
+// Validate reports every invalid setting at once.
+func (s MockScenario) Validate() error {
+	var errs []error
+	errs = append(errs, s.Default.validate("default")...)
+
+	var methods = sortedKeys(s.Methods)
+	for _, method := range methods {
+		if !mockMethods[method] {
+			errs = append(errs, fmt.Errorf("methods: unknown method %q", method))
+		}
+		errs = append(errs, s.Methods[method].validate("methods."+method)...)
+	}
+	for _, username := range sortedKeys(s.Users) {
+		errs = append(errs, s.Users[username].validate("users."+username)...)
+	}
+	for _, method := range sortedKeys(s.Scripts) {
+		if !mockMethods[method] {
+			errs = append(errs, fmt.Errorf("scripts: unknown method %q", method))
+		}
+		for i, step := range s.Scripts[method] {
+			if _, _, err := parseStep(step); err != nil {
+				errs = append(errs, fmt.Errorf("scripts.%s[%d]: %w", method, i, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (f Faults) validate(name string) []error {
+	var errs []error
+	if f.ErrorRate != nil && (*f.ErrorRate < 0 || *f.ErrorRate > 1) {
+		errs = append(errs, fmt.Errorf("%s.error_rate: must be between 0 and 1", name))
+	}
+	if f.Error != "" && f.Error != FaultError && f.Error != FaultNotFound && f.Error != FaultTimeout {
+		errs = append(errs, fmt.Errorf("%s.error: must be error, not_found or timeout", name))
+	}
+	if f.Latency != nil {
+		var l = f.Latency
+		switch l.Distribution {
+		case "fixed", "exponential", "normal":
+		case "uniform":
+			if l.Max.Duration < l.Min.Duration {
+				errs = append(errs, fmt.Errorf("%s.latency: max must not be below min", name))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("%s.latency.distribution: must be fixed, uniform, normal or exponential", name))
+		}
+		for _, d := range []time.Duration{l.Duration.Duration, l.Min.Duration, l.Max.Duration, l.Mean.Duration, l.StdDev.Duration} {
+			if d < 0 {
+				errs = append(errs, fmt.Errorf("%s.latency: durations must not be negative", name))
+				break
+			}
+		}
+	}
+	return errs
+}
+
+// overlay returns f with the fields set in more specific settings o.
+func (f Faults) overlay(o Faults) Faults {
+	if o.Latency != nil {
+		f.Latency = o.Latency
+	}
+	if o.ErrorRate != nil {
+		f.ErrorRate = o.ErrorRate
+	}
+	if o.Error != "" {
+		f.Error = o.Error
+	}
+	return f
+}
+
+// parseStep decodes a script step into a fault or a delay.
+func parseStep(step string) (fault string, delay time.Duration, err error) {
+	switch step {
+	case FaultOK, FaultError, FaultNotFound, FaultTimeout:
+		return step, 0, nil
+	}
+	delay, err = time.ParseDuration(step)
+	if err != nil || delay < 0 {
+		return "", 0, fmt.Errorf("step %q must be ok, error, not_found, timeout or a duration", step)
+	}
+	return FaultOK, delay, nil
+}
+
+// sample draws a duration from l.
+func (l *Latency) sample(rng *rand.Rand) time.Duration {
+	var d time.Duration
+	switch l.Distribution {
+	case "fixed":
+		d = l.Duration.Duration
+	case "uniform":
+		d = l.Min.Duration
+		if spread := l.Max.Duration - l.Min.Duration; spread > 0 {
+			d += time.Duration(rng.Int64N(int64(spread) + 1))
+		}
+	case "normal":
+		d = l.Mean.Duration + time.Duration(rng.NormFloat64()*float64(l.StdDev.Duration))
+	case "exponential":
+		d = time.Duration(rng.ExpFloat64() * float64(l.Mean.Duration))
+	}
+	return max(d, 0)
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	var keys = make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,38 @@
+package tools
+
+import (
+	"context"
+	"golearn/src/internal/tenant"
+	"testing"
+	"time"
+)
+
+// testClock is a settable time source for stores and caches.
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+// newTestStore returns a mock store without faults holding alice, with 100
+// points and 500 EUR cents, in the default tenant, and a context scoped to
+// that tenant.
+func newTestStore(t *testing.T) (*mockDatabase, context.Context, *testClock) {
+	t.Helper()
+
+	store, err := newMockDatabase(Dataset{"default": {{
+		ID:        "usr_00000000000000a1",
+		Username:  "alice",
+		Name:      "Alice",
+		AuthToken: "token",
+		Wallets:   []Wallet{PointsWallet(100), {Name: "eur", Currency: "EUR", Precision: 2, Balance: 500}},
+	}}}, MockScenario{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var clock = &testClock{now: time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)}
+	store.SetClock(clock.Now)
+	return store, tenant.NewContext(context.Background(), "default"), clock
+}
@@ -0,0 +1,54 @@
+package tools
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+var (
+	ErrUsernameTaken   = errors.New("username is already taken")
+	ErrInvalidUsername = errors.New("username must be 1-64 letters, digits or ._@- characters and not a user ID")
+	ErrInvalidUserID   = errors.New("user ID must be usr_ followed by 16 hex digits")
+)
+
+// User IDs never change. Usernames may not take the form of an ID, so
+// either can be used to look up an account.
+var (
+	validUserID   = regexp.MustCompile(`^usr_[0-9a-f]{16}$`)
+	validUsername = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._@-]{0,63}$`)
+)
+
+// Rename records a change of username. The account keeps its ID, so
+// records that reference the ID stay attached to it.
+type Rename struct {
+	From string
+	To   string
+	At   time.Time
+}
+
+// NewUserID returns a random user ID.
+func NewUserID() string {
+	return randomID("usr_")
+}
+
+// randomID returns prefix followed by 16 random hex digits.
+func randomID(prefix string) string {
+	var raw = make([]byte, 8)
+	rand.Read(raw)
+	return prefix + hex.EncodeToString(raw)
+}
+
+func IsUserID(s string) bool {
+	return validUserID.MatchString(s)
+}
+
+func ValidateUsername(username string) error {
+	if !validUsername.MatchString(username) || IsUserID(username) {
+		return fmt.Errorf("%w, got %q", ErrInvalidUsername, username)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// Every account has the default wallet, holding its base points. Other
+// wallets hold partner currencies such as airline miles or store credit.
+const (
+	DefaultWallet   = "points"
+	DefaultCurrency = "PTS"
+	MaxPrecision    = 8
+)
+
+var (
+	ErrWalletNotFound = errors.New("wallet not found")
+	ErrWalletExists   = errors.New("wallet already exists")
+	ErrInvalidWallet  = errors.New("invalid wallet")
+)
+
+var (
+	validWalletName = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+	validCurrency   = regexp.MustCompile(`^[A-Z][A-Z0-9]{2,9}$`)
+)
+
+// Wallet is the balance of an account in one currency. Balance counts minor
+// units: at Precision 2, a Balance of 1050 is 10.50.
+type Wallet struct {
+	Name      string
+	Currency  string
+	Precision int
+	Balance   int64
+}
+
+// PointsWallet returns the default wallet holding balance.
+func PointsWallet(balance int64) Wallet {
+	return Wallet{Name: DefaultWallet, Currency: DefaultCurrency, Balance: balance}
+}
+
+// Validate checks the name, currency code and precision of w and that its
+// balance is not negative.
+func (w Wallet) Validate() error {
+	var problem string
+	switch {
+	case !validWalletName.MatchString(w.Name):
+		problem = fmt.Sprintf("name %q must be 1-32 lower case letters, digits, _ or -, starting with a letter", w.Name)
+	case !validCurrency.MatchString(w.Currency):
+		problem = fmt.Sprintf("currency %q must be 3-10 upper case letters or digits, starting with a letter", w.Currency)
+	case w.Precision < 0 || w.Precision > MaxPrecision:
+		problem = fmt.Sprintf("precision %d must be between 0 and %d", w.Precision, MaxPrecision)
+	case w.Balance < 0:
+		problem = fmt.Sprintf("balance %d must not be negative", w.Balance)
+	default:
+		return nil
+	}
+	return fmt.Errorf("%w %s: %s", ErrInvalidWallet, w.Name, problem)
+}
+
+func (a Account) Wallet(name string) (Wallet, bool) {
+	return findWallet(a.Wallets, name)
+}
+
+func (p PointDetails) Wallet(name string) (Wallet, bool) {
+	return findWallet(p.Wallets, name)
+}
+
+func findWallet(wallets []Wallet, name string) (Wallet, bool) {
+	if i := walletIndex(wallets, name); i >= 0 {
+		return wallets[i], true
+	}
+	return Wallet{}, false
+}
+
+func walletIndex(wallets []Wallet, name string) int {
+	for i, wallet := range wallets {
+		if wallet.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// ValidateWallets checks every wallet and that no two share a name.
+func ValidateWallets(wallets []Wallet) error {
+	var seen = map[string]bool{}
+	for _, wallet := range wallets {
+		if err := wallet.Validate(); err != nil {
+			return err
+		}
+		if seen[wallet.Name] {
+			return fmt.Errorf("%w %s: duplicate wallet", ErrInvalidWallet, wallet.Name)
+		}
+		seen[wallet.Name] = true
+	}
+	return nil
+}
+
+// normalizeWallets validates wallets and returns a copy with the default
+// wallet first, adding an empty one if it is missing, and the others in
+// name order.
+func normalizeWallets(wallets []Wallet) ([]Wallet, error) {
+	if err := ValidateWallets(wallets); err != nil {
+		return nil, err
+	}
+	var normalized = append(make([]Wallet, 0, len(wallets)+1), wallets...)
+	if walletIndex(normalized, DefaultWallet) < 0 {
+		normalized = append(normalized, PointsWallet(0))
+	}
+	sortWallets(normalized)
+	return normalized, nil
+}
+
+func sortWallets(wallets []Wallet) {
+	sort.Slice(wallets, func(i, j int) bool {
+		if (wallets[i].Name == DefaultWallet) != (wallets[j].Name == DefaultWallet) {
+			return wallets[i].Name == DefaultWallet
+		}
+		return wallets[i].Name < wallets[j].Name
+	})
+}
@@ -0,0 +1,197 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"golearn/src/internal/tools"
+	"time"
+)
+
+// tracedDatabase records a client span for every call to the wrapped store.
+type tracedDatabase struct {
+	next tools.DatabaseInterface
+}
+
+func TraceDatabase(database tools.DatabaseInterface) tools.DatabaseInterface {
+	return &tracedDatabase{next: database}
+}
+
+func startStoreSpan(ctx context.Context, method string, user string) (context.Context, *Span) {
+	ctx, span := Start(ctx, "store."+method, KindClient)
+	span.SetAttribute("db.method", method)
+	if user != "" {
+		span.SetAttribute("db.user", user)
+	}
+	return ctx, span
+}
+
+// endStoreSpan ends span, treating unknown users and rejected updates as
+// results rather than failures.
+func endStoreSpan(span *Span, err error) {
+	switch {
+	case errors.Is(err, tools.ErrUserNotFound):
+		span.SetAttribute("db.found", false)
+	case errors.Is(err, tools.ErrInsufficientFunds), errors.Is(err, tools.ErrUsernameTaken),
+		errors.Is(err, tools.ErrWalletNotFound), errors.Is(err, tools.ErrWalletExists),
+		errors.Is(err, tools.ErrHoldNotFound), errors.Is(err, tools.ErrHoldExceeded),
+		errors.Is(err, tools.ErrInvalidAdjustment), errors.Is(err, tools.ErrInvalidHoldAmount),
+		errors.Is(err, tools.ErrBalanceOverflow):
+		span.SetAttribute("db.rejected", err.Error())
+	default:
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+func (d *tracedDatabase) GetUserLoginDetails(ctx context.Context, user string) (*tools.LoginDetails, error) {
+	ctx, span := startStoreSpan(ctx, "GetUserLoginDetails", user)
+	loginDetails, err := d.next.GetUserLoginDetails(ctx, user)
+	endStoreSpan(span, err)
+	return loginDetails, err
+}
+
+func (d *tracedDatabase) GetUserPointDetails(ctx context.Context, user string) (*tools.PointDetails, error) {
+	ctx, span := startStoreSpan(ctx, "GetUserPointDetails", user)
+	pointDetails, err := d.next.GetUserPointDetails(ctx, user)
+	endStoreSpan(span, err)
+	return pointDetails, err
+}
+
+func (d *tracedDatabase) UpdateUserBalance(ctx context.Context, user string, wallet string, delta int64) (*tools.PointDetails, error) {
+	ctx, span := startStoreSpan(ctx, "UpdateUserBalance", user)
+	span.SetAttribute("db.wallet", wallet)
+	span.SetAttribute("db.delta", delta)
+	pointDetails, err := d.next.UpdateUserBalance(ctx, user, wallet, delta)
+	endStoreSpan(span, err)
+	return pointDetails, err
+}
+
+func (d *tracedDatabase) AdjustBalance(ctx context.Context, user string, wallet string, delta int64, reason string) (*tools.PointDetails, error) {
+	ctx, span := startStoreSpan(ctx, "AdjustBalance", user)
+	span.SetAttribute("db.wallet", wallet)
+	span.SetAttribute("db.delta", delta)
+	pointDetails, err := d.next.AdjustBalance(ctx, user, wallet, delta, reason)
+	endStoreSpan(span, err)
+	return pointDetails, err
+}
+
+func (d *tracedDatabase) OpenWallet(ctx context.Context, user string, wallet tools.Wallet) (*tools.PointDetails, error) {
+	ctx, span := startStoreSpan(ctx, "OpenWallet", user)
+	span.SetAttribute("db.wallet", wallet.Name)
+	span.SetAttribute("db.currency", wallet.Currency)
+	pointDetails, err := d.next.OpenWallet(ctx, user, wallet)
+	endStoreSpan(span, err)
+	return pointDetails, err
+}
+
+func (d *tracedDatabase) PlaceHold(ctx context.Context, user string, wallet string, amount int64, ttl time.Duration) (*tools.Hold, *tools.PointDetails, error) {
+	ctx, span := startStoreSpan(ctx, "PlaceHold", user)
+	span.SetAttribute("db.wallet", wallet)
+	span.SetAttribute("db.amount", amount)
+	span.SetAttribute("db.ttl", ttl.String())
+	hold, pointDetails, err := d.next.PlaceHold(ctx, user, wallet, amount, ttl)
+	if hold != nil {
+		span.SetAttribute("db.hold", hold.ID)
+	}
+	endStoreSpan(span, err)
+	return hold, pointDetails, err
+}
+
+func (d *tracedDatabase) CaptureHold(ctx context.Context, user string, holdID string, amount int64) (*tools.Hold, *tools.PointDetails, error) {
+	ctx, span := startStoreSpan(ctx, "CaptureHold", user)
+	span.SetAttribute("db.hold", holdID)
+	span.SetAttribute("db.amount", amount)
+	hold, pointDetails, err := d.next.CaptureHold(ctx, user, holdID, amount)
+	endStoreSpan(span, err)
+	return hold, pointDetails, err
+}
+
+func (d *tracedDatabase) ReleaseHold(ctx context.Context, user string, holdID string) (*tools.Hold, *tools.PointDetails, error) {
+	ctx, span := startStoreSpan(ctx, "ReleaseHold", user)
+	span.SetAttribute("db.hold", holdID)
+	hold, pointDetails, err := d.next.ReleaseHold(ctx, user, holdID)
+	endStoreSpan(span, err)
+	return hold, pointDetails, err
+}
+
+func (d *tracedDatabase) GetAccount(ctx context.Context, user string) (*tools.Account, error) {
+	ctx, span := startStoreSpan(ctx, "GetAccount", user)
+	account, err := d.next.GetAccount(ctx, user)
+	endStoreSpan(span, err)
+	return account, err
+}
+
+func (d *tracedDatabase) RenameUser(ctx context.Context, user string, username string) (*tools.LoginDetails, error) {
+	ctx, span := startStoreSpan(ctx, "RenameUser", user)
+	span.SetAttribute("db.new_username", username)
+	loginDetails, err := d.next.RenameUser(ctx, user, username)
+	endStoreSpan(span, err)
+	return loginDetails, err
+}
+
+func (d *tracedDatabase) ListAccounts(ctx context.Context, fn func(tools.Account) error) error {
+	ctx, span := startStoreSpan(ctx, "ListAccounts", "")
+	var err = d.next.ListAccounts(ctx, fn)
+	endStoreSpan(span, err)
+	return err
+}
+
+func (d *tracedDatabase) PutAccount(ctx context.Context, account tools.Account) error {
+	ctx, span := startStoreSpan(ctx, "PutAccount", account.Username)
+	if account.ID != "" {
+		span.SetAttribute("db.user_id", account.ID)
+	}
+	var err = d.next.PutAccount(ctx, account)
+	endStoreSpan(span, err)
+	return err
+}
+
+func (d *tracedDatabase) PutAccounts(ctx context.Context, accounts []tools.Account) ([]error, error) {
+	ctx, span := startStoreSpan(ctx, "PutAccounts", "")
+	span.SetAttribute("db.accounts", len(accounts))
+	errs, err := d.next.PutAccounts(ctx, accounts)
+	endStoreSpan(span, err)
+	return errs, err
+}
+
+func (d *tracedDatabase) Snapshot(ctx context.Context) (tools.Dataset, error) {
+	ctx, span := startStoreSpan(ctx, "Snapshot", "")
+	data, err := d.next.Snapshot(ctx)
+	endStoreSpan(span, err)
+	return data, err
+}
+
+func (d *tracedDatabase) Restore(ctx context.Context, data tools.Dataset) error {
+	ctx, span := startStoreSpan(ctx, "Restore", "")
+	var err = d.next.Restore(ctx, data)
+	endStoreSpan(span, err)
+	return err
+}
+
+func (d *tracedDatabase) ExpireHolds(ctx context.Context) (int, error) {
+	ctx, span := startStoreSpan(ctx, "ExpireHolds", "")
+	expired, err := d.next.ExpireHolds(ctx)
+	span.SetAttribute("db.expired", expired)
+	endStoreSpan(span, err)
+	return expired, err
+}
+
+func (d *tracedDatabase) SetupDatabase() error {
+	return d.next.SetupDatabase()
+}
+
+// Ping is only traced as part of an existing trace, so that readiness probes
+// do not start a new trace each time.
+func (d *tracedDatabase) Ping(ctx context.Context) error {
+	if SpanFromContext(ctx) == nil {
+		return d.next.Ping(ctx)
+	}
+	ctx, span := startStoreSpan(ctx, "Ping", "")
+	var err = d.next.Ping(ctx)
+	endStoreSpan(span, err)
+	return err
+}
+
+func (d *tracedDatabase) Close() error {
+	return d.next.Close()
+}
@@ -0,0 +1,167 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Exporter receives finished spans. Export must not block the caller for
+// long; Shutdown flushes anything still buffered.
+type Exporter interface {
+	Export(span SpanData)
+	Shutdown(ctx context.Context) error
+}
+
+// JSONLinesExporter writes each span as one JSON object per line.
+type JSONLinesExporter struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+	closer  io.Closer
+}
+
+// NewJSONLinesExporter writes to w. If w is also an io.Closer it is closed on
+// Shutdown.
+func NewJSONLinesExporter(w io.Writer) *JSONLinesExporter {
+	var e = &JSONLinesExporter{encoder: json.NewEncoder(w)}
+	if closer, ok := w.(io.Closer); ok {
+		e.closer = closer
+	}
+	return e
+}
+
+func (e *JSONLinesExporter) Export(span SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.encoder.Encode(span); err != nil {
+		log.Warnf("tracing: writing span: %v", err)
+	}
+}
+
+func (e *JSONLinesExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closer != nil {
+		return e.closer.Close()
+	}
+	return nil
+}
+
+// CollectorExporter batches spans and POSTs them as a JSON array to a
+// collector endpoint from a background goroutine. Spans are dropped rather
+// than blocking requests when the queue is full.
+type CollectorExporter struct {
+	endpoint  string
+	client    *http.Client
+	batchSize int
+	interval  time.Duration
+
+	queue   chan SpanData
+	flushes chan chan struct{}
+	done    chan struct{}
+	dropped atomic.Int64
+	once    sync.Once
+}
+
+func NewCollectorExporter(endpoint string, batchSize int, interval time.Duration) *CollectorExporter {
+	var e = &CollectorExporter{
+		endpoint:  endpoint,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		batchSize: batchSize,
+		interval:  interval,
+		queue:     make(chan SpanData, batchSize*4),
+		flushes:   make(chan chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+func (e *CollectorExporter) Export(span SpanData) {
+	select {
+	case e.queue <- span:
+	default:
+		e.dropped.Add(1)
+	}
+}
+
+// Dropped returns how many spans were discarded because the queue was full.
+func (e *CollectorExporter) Dropped() int64 {
+	return e.dropped.Load()
+}
+
+func (e *CollectorExporter) Shutdown(ctx context.Context) error {
+	var flushed = make(chan struct{})
+	var err error
+	e.once.Do(func() {
+		select {
+		case e.flushes <- flushed:
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		}
+		select {
+		case <-flushed:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	})
+	return err
+}
+
+func (e *CollectorExporter) run() {
+	var ticker = time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	var batch = make([]SpanData, 0, e.batchSize)
+	for {
+		select {
+		case span := <-e.queue:
+			batch = append(batch, span)
+			if len(batch) >= e.batchSize {
+				batch = e.send(batch)
+			}
+		case <-ticker.C:
+			batch = e.send(batch)
+		case flushed := <-e.flushes:
+			for len(e.queue) > 0 {
+				batch = append(batch, <-e.queue)
+			}
+			e.send(batch)
+			close(flushed)
+			return
+		}
+	}
+}
+
+// send posts batch and returns it emptied for reuse.
+func (e *CollectorExporter) send(batch []SpanData) []SpanData {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	body, err := json.Marshal(batch)
+	if err == nil {
+		var resp *http.Response
+		resp, err = e.client.Post(e.endpoint, "application/json", bytes.NewReader(body))
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode >= 300 {
+				err = fmt.Errorf("collector returned %s", resp.Status)
+			}
+		}
+	}
+	if err != nil {
+		log.Warnf("tracing: exporting %d spans: %v", len(batch), err)
+	}
+
+	return batch[:0]
+}
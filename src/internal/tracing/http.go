@@ -0,0 +1,114 @@
+package tracing
+
+import (
+	"golearn/src/internal/logging"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	chimiddle "github.com/go-chi/chi/middleware"
+	log "github.com/sirupsen/logrus"
+)
+
+// Middleware continues the trace from an incoming traceparent header, or
+// starts one, and records a server span named after the chi route pattern.
+// The trace ID is added to the request's log fields.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ctx = r.Context()
+		if sc, err := ParseTraceparent(r.Header.Get(TraceparentHeader)); err == nil {
+			ctx = ContextWithRemote(ctx, sc)
+		}
+
+		ctx, span := Start(ctx, "HTTP "+r.Method, KindServer)
+		defer span.End()
+		logging.AddFields(ctx, log.Fields{"trace_id": span.Context().TraceID.String()})
+
+		var ww = chimiddle.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		var status = ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if rctx := chi.RouteContext(ctx); rctx != nil && rctx.RoutePattern() != "" {
+			span.SetName("HTTP " + r.Method + " " + rctx.RoutePattern())
+			span.SetAttribute("http.route", rctx.RoutePattern())
+		}
+		span.SetAttribute("http.method", r.Method)
+		span.SetAttribute("http.target", r.URL.Path)
+		span.SetAttribute("http.status_code", status)
+		if status >= http.StatusInternalServerError {
+			span.RecordError(errorStatus(status))
+		}
+	})
+}
+
+// WrapMiddleware records a span covering only mw's own work: the span ends
+// when mw passes the request on, and later handlers stay children of the
+// enclosing span.
+func WrapMiddleware(name string, mw func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var parent = SpanFromContext(r.Context())
+			ctx, span := Start(r.Context(), name, KindInternal)
+			defer span.End()
+
+			var handoff = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				span.End()
+				var ctx = r.Context()
+				if parent != nil {
+					ctx = ContextWithSpan(ctx, parent)
+				}
+				next.ServeHTTP(w, r.WithContext(ctx))
+			})
+
+			mw(handoff).ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Inject writes the current span context of r's context into its headers.
+func Inject(r *http.Request) {
+	if span := SpanFromContext(r.Context()); span != nil {
+		r.Header.Set(TraceparentHeader, span.Context().Traceparent())
+	}
+}
+
+// Transport records a client span for every outgoing request and propagates
+// the trace with a traceparent header.
+type Transport struct {
+	Base http.RoundTripper
+}
+
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	var base = t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	ctx, span := Start(r.Context(), "HTTP "+r.Method+" "+r.URL.Host, KindClient)
+	defer span.End()
+	span.SetAttribute("http.method", r.Method)
+	span.SetAttribute("http.url", r.URL.String())
+
+	var outgoing = r.Clone(ctx)
+	Inject(outgoing)
+
+	resp, err := base.RoundTrip(outgoing)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	span.SetAttribute("http.status_code", resp.StatusCode)
+	if resp.StatusCode >= http.StatusInternalServerError {
+		span.RecordError(errorStatus(resp.StatusCode))
+	}
+	return resp, nil
+}
+
+type errorStatus int
+
+func (e errorStatus) Error() string {
+	return "HTTP " + strconv.Itoa(int(e)) + " " + http.StatusText(int(e))
+}
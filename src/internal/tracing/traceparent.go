@@ -0,0 +1,121 @@
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// TraceparentHeader is the W3C Trace Context propagation header.
+const TraceparentHeader = "traceparent"
+
+const flagSampled = 0x01
+
+var ErrInvalidTraceparent = errors.New("invalid traceparent header")
+
+type TraceID [16]byte
+
+type SpanID [8]byte
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+
+func (t TraceID) IsValid() bool { return t != TraceID{} }
+
+func (s SpanID) String() string { return hex.EncodeToString(s[:]) }
+
+func (s SpanID) IsValid() bool { return s != SpanID{} }
+
+// SpanContext is the part of a span that crosses process boundaries.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+	// Remote is true when the context was received from another process.
+	Remote bool
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Traceparent formats sc as a version 00 traceparent header value.
+func (sc SpanContext) Traceparent() string {
+	var flags = "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// ParseTraceparent parses a traceparent header value. Future versions are
+// accepted as long as they start with the version 00 fields, as the
+// specification requires.
+func ParseTraceparent(value string) (SpanContext, error) {
+	value = strings.TrimSpace(value)
+	if len(value) < 55 {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+
+	var version, ok = decodeHex(value[0:2], 1)
+	if !ok || version[0] == 0xff {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	if version[0] == 0 && len(value) != 55 {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	if len(value) > 55 && value[55] != '-' {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	if value[2] != '-' || value[35] != '-' || value[52] != '-' {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+
+	var sc = SpanContext{Remote: true}
+	traceID, ok := decodeHex(value[3:35], 16)
+	if !ok {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	spanID, ok := decodeHex(value[36:52], 8)
+	if !ok {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	flags, ok := decodeHex(value[53:55], 1)
+	if !ok {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+
+	copy(sc.TraceID[:], traceID)
+	copy(sc.SpanID[:], spanID)
+	sc.Sampled = flags[0]&flagSampled != 0
+
+	if !sc.IsValid() {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	return sc, nil
+}
+
+// decodeHex decodes exactly n bytes of lowercase hex, as traceparent requires.
+func decodeHex(s string, n int) ([]byte, bool) {
+	if len(s) != 2*n || strings.ToLower(s) != s {
+		return nil, false
+	}
+	b, err := hex.DecodeString(s)
+	return b, err == nil
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id
+}
@@ -0,0 +1,225 @@
+package tracing
+
+import (
+	"context"
+	"encoding/binary"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	KindInternal = "internal"
+	KindServer   = "server"
+	KindClient   = "client"
+)
+
+// Tracer creates spans and hands finished, sampled spans to its exporter.
+// A Tracer without an exporter still propagates trace context but records
+// nothing.
+type Tracer struct {
+	service     string
+	exporter    Exporter
+	sampleRatio float64
+}
+
+func NewTracer(service string, exporter Exporter, sampleRatio float64) *Tracer {
+	return &Tracer{service: service, exporter: exporter, sampleRatio: sampleRatio}
+}
+
+var defaultTracer atomic.Pointer[Tracer]
+
+func init() {
+	defaultTracer.Store(NewTracer("golearn", nil, 0))
+}
+
+// SetDefault replaces the tracer used by the package-level functions.
+func SetDefault(t *Tracer) {
+	defaultTracer.Store(t)
+}
+
+func Default() *Tracer {
+	return defaultTracer.Load()
+}
+
+// Start begins a span with the default tracer.
+func Start(ctx context.Context, name string, kind string) (context.Context, *Span) {
+	return Default().Start(ctx, name, kind)
+}
+
+// Start begins a span named name. Its parent is the span in ctx, or else the
+// remote span context stored by ContextWithRemote. Without either, a new
+// trace begins and is sampled according to the tracer's ratio.
+func (t *Tracer) Start(ctx context.Context, name string, kind string) (context.Context, *Span) {
+	var span = &Span{
+		tracer: t,
+		name:   name,
+		kind:   kind,
+		start:  time.Now(),
+	}
+
+	var parent, ok = parentContext(ctx)
+	if ok {
+		span.context = SpanContext{TraceID: parent.TraceID, SpanID: newSpanID(), Sampled: parent.Sampled}
+		span.parent = parent.SpanID
+	} else {
+		var traceID = newTraceID()
+		span.context = SpanContext{TraceID: traceID, SpanID: newSpanID(), Sampled: t.sample(traceID)}
+	}
+
+	return ContextWithSpan(ctx, span), span
+}
+
+// sample decides from the trace ID alone, so every service with the same
+// ratio makes the same decision for a trace.
+func (t *Tracer) sample(traceID TraceID) bool {
+	if t.exporter == nil || t.sampleRatio <= 0 {
+		return false
+	}
+	if t.sampleRatio >= 1 {
+		return true
+	}
+	var bound = uint64(t.sampleRatio * (1 << 63))
+	return binary.BigEndian.Uint64(traceID[8:])>>1 < bound
+}
+
+// Span is a timed operation within a trace. All methods are safe on a nil
+// Span and from multiple goroutines.
+type Span struct {
+	tracer  *Tracer
+	context SpanContext
+	parent  SpanID
+	kind    string
+	start   time.Time
+
+	mu         sync.Mutex
+	name       string
+	attributes map[string]any
+	err        string
+	ended      bool
+}
+
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.context
+}
+
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.name = name
+}
+
+func (s *Span) SetAttribute(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.attributes == nil {
+		s.attributes = map[string]any{}
+	}
+	s.attributes[key] = value
+}
+
+// RecordError marks the span as failed. A nil err is ignored.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err.Error()
+}
+
+// End finishes the span and exports it if it is sampled. Only the first call
+// has any effect.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	var end = time.Now()
+
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	var data = SpanData{
+		TraceID:    s.context.TraceID.String(),
+		SpanID:     s.context.SpanID.String(),
+		Service:    s.tracer.service,
+		Name:       s.name,
+		Kind:       s.kind,
+		Start:      s.start,
+		End:        end,
+		DurationMs: float64(end.Sub(s.start).Microseconds()) / 1000,
+		Attributes: s.attributes,
+		Status:     "ok",
+	}
+	if s.parent.IsValid() {
+		data.ParentSpanID = s.parent.String()
+	}
+	if s.err != "" {
+		data.Status = "error"
+		data.Error = s.err
+	}
+	s.mu.Unlock()
+
+	if s.context.Sampled && s.tracer.exporter != nil {
+		s.tracer.exporter.Export(data)
+	}
+}
+
+// SpanData is the exported form of a finished span.
+type SpanData struct {
+	TraceID      string         `json:"trace_id"`
+	SpanID       string         `json:"span_id"`
+	ParentSpanID string         `json:"parent_span_id,omitempty"`
+	Service      string         `json:"service"`
+	Name         string         `json:"name"`
+	Kind         string         `json:"kind"`
+	Start        time.Time      `json:"start"`
+	End          time.Time      `json:"end"`
+	DurationMs   float64        `json:"duration_ms"`
+	Attributes   map[string]any `json:"attributes,omitempty"`
+	Status       string         `json:"status"`
+	Error        string         `json:"error,omitempty"`
+}
+
+type spanKey struct{}
+
+type remoteKey struct{}
+
+// ContextWithSpan returns a context in which span is the current span.
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext returns the current span, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// ContextWithRemote stores a span context received from another process as
+// the parent of the next span started from ctx.
+func ContextWithRemote(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+func parentContext(ctx context.Context) (SpanContext, bool) {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.context, true
+	}
+	if sc, ok := ctx.Value(remoteKey{}).(SpanContext); ok && sc.IsValid() {
+		return sc, true
+	}
+	return SpanContext{}, false
+}